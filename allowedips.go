@@ -0,0 +1,45 @@
+package reversebin
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// parseAllowedIP parses one allowed_ips entry: an IPv4 or IPv6 CIDR range,
+// or a single address.
+func parseAllowedIP(entry string) (netip.Prefix, error) {
+	if prefix, err := netip.ParsePrefix(entry); err == nil {
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("allowed_ips entry %q is not an IP address or CIDR range", entry)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// clientIP returns the client IP Caddy determined for r, which honours
+// trusted_proxies, falling back to the connection's remote address.
+func clientIP(r *http.Request) (string, netip.Addr) {
+	ip, _ := caddyhttp.GetVar(r.Context(), caddyhttp.ClientIPVarKey).(string)
+	if ip == "" {
+		ip, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}
+	addr, _ := netip.ParseAddr(ip)
+	return ip, addr.Unmap()
+}
+
+// checkAllowedIP rejects r with 403 unless its client IP is in allowed_ips.
+func (c *ReverseBin) checkAllowedIP(r *http.Request) error {
+	ip, addr := clientIP(r)
+	for _, prefix := range c.allowedNets {
+		if prefix.Contains(addr) {
+			return nil
+		}
+	}
+	return caddyhttp.Error(http.StatusForbidden, fmt.Errorf("client %q is not in allowed_ips", ip))
+}
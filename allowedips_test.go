@@ -0,0 +1,60 @@
+package reversebin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestAllowedIPsRejectsBeforeLaunch verifies clients outside allowed_ips get 403 without starting a backend.
+func TestAllowedIPsRejectsBeforeLaunch(t *testing.T) {
+	f := useMockProcesses(t, filepath.Join(t.TempDir(), "app.sock"), http.NotFoundHandler())
+	c := &ReverseBin{
+		processes: map[string]*processState{},
+		logger:    zaptest.NewLogger(t),
+	}
+	for _, entry := range []string{"10.0.0.0/8", "2001:db8::/32", "192.168.1.7"} {
+		prefix, err := parseAllowedIP(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.allowedNets = append(c.allowedNets, prefix)
+	}
+
+	for _, remote := range []string{"10.1.2.3:5000", "[2001:db8::1]:5000", "[::ffff:192.168.1.7]:5000"} {
+		// This HTTP request tests a client inside allowed_ips.
+		req := httptest.NewRequest(http.MethodGet, "http://app.example/", nil)
+		req.RemoteAddr = remote
+		if err := c.checkAllowedIP(req); err != nil {
+			t.Fatalf("%s: expected allowed, got %v", remote, err)
+		}
+	}
+
+	// This HTTP request tests a client outside allowed_ips.
+	req := httptest.NewRequest(http.MethodGet, "http://app.example/", nil)
+	req.RemoteAddr = "203.0.113.9:5000"
+	err := c.ServeHTTP(httptest.NewRecorder(), req, nil)
+	var herr caddyhttp.HandlerError
+	if !errors.As(err, &herr) || herr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %v", err)
+	}
+	if n := f.starts.Load(); n != 0 {
+		t.Fatalf("expected no backend launch, got %d", n)
+	}
+}
+
+// TestParseAllowedIPRejectsGarbage verifies allowed_ips entries must be addresses or CIDR ranges.
+func TestParseAllowedIPRejectsGarbage(t *testing.T) {
+	if p, err := parseAllowedIP("10.1.2.3/8"); err != nil || p != netip.MustParsePrefix("10.0.0.0/8") {
+		t.Fatalf("expected masked 10.0.0.0/8, got %v, %v", p, err)
+	}
+	if _, err := parseAllowedIP("example.com"); err == nil {
+		t.Fatal("expected a host name to be rejected")
+	}
+}
@@ -0,0 +1,189 @@
+package reversebin
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+)
+
+func init() {
+	caddy.RegisterModule(&App{})
+	httpcaddyfile.RegisterGlobalOption("reverse_bin", parseGlobalOption)
+}
+
+// App holds settings shared by every reverse-bin handler in a config: the
+// cap on idle backends across virtual hosts, and defaults for handler
+// settings that site blocks may override.
+type App struct {
+	// Most backends, over all reverse-bin handlers, left running without
+	// in-flight requests; the least recently used are stopped beyond it.
+	// 0 means no limit.
+	MaxIdleProcesses int `json:"maxIdleProcesses,omitempty"`
+	// True to provision and validate handlers, checking that their commands
+	// exist, without ever starting a backend; requests get 503
+	DryRun bool `json:"dryRun,omitempty"`
+	// Default idle_timeout_ms for handlers that do not set it
+	IdleTimeoutMS int `json:"idleTimeoutMs,omitempty"`
+	// Default health_timeout_ms for handlers that do not set it
+	HealthTimeoutMS int `json:"healthTimeoutMs,omitempty"`
+	// Default response_header_timeout_ms for handlers that do not set it
+	ResponseHeaderTimeoutMS int `json:"responseHeaderTimeoutMs,omitempty"`
+
+	mu   sync.Mutex
+	idle map[*processState]idleBackend
+}
+
+// idleBackend is a backend waiting out its idle timeout.
+type idleBackend struct {
+	owner *ReverseBin
+	since time.Time
+}
+
+// CaddyModule returns the Caddy module information.
+func (*App) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "reverse_bin",
+		New: func() caddy.Module { return new(App) },
+	}
+}
+
+// Provision implements caddy.Provisioner.
+func (a *App) Provision(caddy.Context) error {
+	a.idle = make(map[*processState]idleBackend)
+	return nil
+}
+
+// Start implements caddy.App.
+func (a *App) Start() error { return nil }
+
+// Stop implements caddy.App; the handlers stop their own backends.
+func (a *App) Stop() error { return nil }
+
+// applyDefaults fills the settings c leaves unset from the global option,
+// before c falls back to the built-in defaults.
+func (a *App) applyDefaults(c *ReverseBin) {
+	if c.IdleTimeoutMS <= 0 {
+		c.IdleTimeoutMS = a.IdleTimeoutMS
+	}
+	if c.HealthTimeoutMS <= 0 {
+		c.HealthTimeoutMS = a.HealthTimeoutMS
+	}
+	if c.ResponseHeaderTimeoutMS <= 0 {
+		c.ResponseHeaderTimeoutMS = a.ResponseHeaderTimeoutMS
+	}
+}
+
+// dryRun reports whether the dry_run global option is set.
+func (a *App) dryRun() bool {
+	return a != nil && a.DryRun
+}
+
+// markIdle records that ps's backend has no in-flight requests. If that
+// puts the idle count over max_idle_processes, the backend idle the longest
+// is asked to stop; its supervisor ignores the request if it got busy again
+// in the meantime.
+func (a *App) markIdle(owner *ReverseBin, ps *processState) {
+	if a == nil || a.MaxIdleProcesses <= 0 {
+		return
+	}
+	a.mu.Lock()
+	a.idle[ps] = idleBackend{owner: owner, since: time.Now()}
+	var victim *processState
+	var oldest idleBackend
+	if len(a.idle) > a.MaxIdleProcesses {
+		for p, b := range a.idle {
+			if victim == nil || b.since.Before(oldest.since) {
+				victim, oldest = p, b
+			}
+		}
+		delete(a.idle, victim)
+	}
+	a.mu.Unlock()
+	if victim != nil {
+		go func() {
+			_ = oldest.owner.sendSupervisorCommand(victim, supervisorEvictIdle, "max_idle_processes")
+		}()
+	}
+}
+
+// markBusy records that ps's backend is serving requests or has stopped.
+func (a *App) markBusy(ps *processState) {
+	if a == nil || a.MaxIdleProcesses <= 0 {
+		return
+	}
+	a.mu.Lock()
+	delete(a.idle, ps)
+	a.mu.Unlock()
+}
+
+// parseGlobalOption sets up the app from the reverse_bin global option:
+//
+//	{
+//		reverse_bin {
+//			max_idle_processes <n>
+//			dry_run
+//			idle_timeout_ms <ms>
+//			health_timeout_ms <ms>
+//			response_header_timeout_ms <ms>
+//		}
+//	}
+func parseGlobalOption(d *caddyfile.Dispenser, _ any) (any, error) {
+	a := new(App)
+	d.Next() // consume option name
+	if d.NextArg() {
+		return nil, d.ArgErr()
+	}
+	for d.NextBlock(0) {
+		switch d.Val() {
+		case "max_idle_processes":
+			if !d.NextArg() {
+				return nil, d.ArgErr()
+			}
+			n, err := strconv.Atoi(d.Val())
+			if err != nil || n <= 0 {
+				return nil, d.Errf("max_idle_processes must be a positive integer, got %q", d.Val())
+			}
+			a.MaxIdleProcesses = n
+		case "dry_run":
+			if d.NextArg() {
+				return nil, d.ArgErr()
+			}
+			a.DryRun = true
+		case "idle_timeout_ms":
+			v, err := parsePositiveMilliseconds(d, "idle_timeout_ms")
+			if err != nil {
+				return nil, err
+			}
+			a.IdleTimeoutMS = v
+		case "health_timeout_ms":
+			v, err := parsePositiveMilliseconds(d, "health_timeout_ms")
+			if err != nil {
+				return nil, err
+			}
+			a.HealthTimeoutMS = v
+		case "response_header_timeout_ms":
+			v, err := parsePositiveMilliseconds(d, "response_header_timeout_ms")
+			if err != nil {
+				return nil, err
+			}
+			a.ResponseHeaderTimeoutMS = v
+		default:
+			return nil, d.Errf("unknown subdirective: %q", d.Val())
+		}
+	}
+	return httpcaddyfile.App{
+		Name:  "reverse_bin",
+		Value: caddyconfig.JSON(a, nil),
+	}, nil
+}
+
+// Interface guards
+var (
+	_ caddy.App         = (*App)(nil)
+	_ caddy.Provisioner = (*App)(nil)
+)
@@ -0,0 +1,74 @@
+package reversebin
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+)
+
+// TestAppEvictsLeastRecentlyIdle verifies exceeding max_idle_processes asks the longest-idle backend to stop.
+func TestAppEvictsLeastRecentlyIdle(t *testing.T) {
+	a := &App{MaxIdleProcesses: 1}
+	if err := a.Provision(caddy.Context{}); err != nil {
+		t.Fatal(err)
+	}
+	owner := &ReverseBin{}
+	older := &processState{key: "older", commands: make(chan supervisorCommand, 1)}
+	newer := &processState{key: "newer", commands: make(chan supervisorCommand, 1)}
+
+	a.markIdle(owner, older)
+	a.markIdle(owner, newer)
+	select {
+	case cmd := <-older.commands:
+		if cmd.kind != supervisorEvictIdle {
+			t.Fatalf("expected an evict command, got %v", cmd.kind)
+		}
+		cmd.reply <- nil
+	case <-time.After(time.Second):
+		t.Fatal("expected the older backend to be evicted")
+	}
+	if len(newer.commands) != 0 {
+		t.Fatal("expected the newer backend to be left alone")
+	}
+
+	// A backend that got busy again no longer counts.
+	a.markBusy(newer)
+	if len(a.idle) != 0 {
+		t.Fatalf("expected no idle backends, got %d", len(a.idle))
+	}
+}
+
+// TestParseGlobalOption verifies the reverse_bin global option configures the app.
+func TestParseGlobalOption(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`reverse_bin {
+	max_idle_processes 5
+	idle_timeout_ms 60000
+}`)
+	v, err := parseGlobalOption(d, nil)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	app := v.(httpcaddyfile.App)
+	var got App
+	if err := json.Unmarshal(app.Value, &got); err != nil || app.Name != "reverse_bin" || got.MaxIdleProcesses != 5 || got.IdleTimeoutMS != 60000 {
+		t.Fatalf("unexpected app %s %s, %v", app.Name, app.Value, err)
+	}
+
+	if _, err := parseGlobalOption(caddyfile.NewTestDispenser("reverse_bin {\n\tmax_idle_processes 0\n}"), nil); err == nil {
+		t.Fatal("expected max_idle_processes 0 to be rejected")
+	}
+}
+
+// TestAppDefaultsYieldToHandlerSettings verifies global defaults fill only the settings a handler leaves unset.
+func TestAppDefaultsYieldToHandlerSettings(t *testing.T) {
+	a := &App{IdleTimeoutMS: 60000, HealthTimeoutMS: 5000, ResponseHeaderTimeoutMS: 30000}
+	c := &ReverseBin{IdleTimeoutMS: 1000}
+	a.applyDefaults(c)
+	if c.IdleTimeoutMS != 1000 || c.HealthTimeoutMS != 5000 || c.ResponseHeaderTimeoutMS != 30000 {
+		t.Fatalf("unexpected settings idle=%d health=%d header=%d", c.IdleTimeoutMS, c.HealthTimeoutMS, c.ResponseHeaderTimeoutMS)
+	}
+}
@@ -0,0 +1,69 @@
+package reversebin
+
+import (
+	"github.com/caddyserver/caddy/v2/caddyconfig"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	"github.com/caddyserver/caddy/v2/modules/caddytls"
+)
+
+// defaultBackendTLSServerName is the name checked against the backend's
+// certificate when server_name is not set. Unix socket upstreams carry no
+// host name of their own.
+const defaultBackendTLSServerName = "localhost"
+
+// BackendTLS configures TLS between Caddy and an http backend, including
+// over a unix socket.
+type BackendTLS struct {
+	// PEM file of the CA that signed the backend's certificate; the system roots are used when empty
+	CA string `json:"ca,omitempty"`
+	// PEM client certificate presented to the backend
+	Cert string `json:"cert,omitempty"`
+	// PEM key for cert
+	Key string `json:"key,omitempty"`
+	// Name the backend's certificate must be valid for (default localhost)
+	ServerName string `json:"serverName,omitempty"`
+}
+
+// parseBackendTLS parses the backend_tls block.
+func parseBackendTLS(d *caddyfile.Dispenser) (*BackendTLS, error) {
+	if d.NextArg() {
+		return nil, d.ArgErr()
+	}
+	bt := &BackendTLS{}
+	for nesting := d.Nesting(); d.NextBlock(nesting); {
+		var target *string
+		switch d.Val() {
+		case "ca":
+			target = &bt.CA
+		case "cert":
+			target = &bt.Cert
+		case "key":
+			target = &bt.Key
+		case "server_name":
+			target = &bt.ServerName
+		default:
+			return nil, d.Errf("unknown backend_tls subdirective '%s'", d.Val())
+		}
+		if !d.Args(target) {
+			return nil, d.ArgErr()
+		}
+	}
+	return bt, nil
+}
+
+// transportTLS returns the equivalent reverse_proxy transport TLS settings.
+func (bt *BackendTLS) transportTLS() *reverseproxy.TLSConfig {
+	cfg := &reverseproxy.TLSConfig{
+		ClientCertificateFile:    bt.Cert,
+		ClientCertificateKeyFile: bt.Key,
+		ServerName:               bt.ServerName,
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = defaultBackendTLSServerName
+	}
+	if bt.CA != "" {
+		cfg.CARaw = caddyconfig.JSONModuleObject(caddytls.FileCAPool{TrustedCACertPEMFiles: []string{bt.CA}}, "provider", "file", nil)
+	}
+	return cfg
+}
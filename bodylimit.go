@@ -0,0 +1,50 @@
+package reversebin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// limitRequestBody enforces max_request_body_size. A declared Content-Length
+// over the limit is rejected with 413 before any backend is started; bodies
+// of unknown length are cut off once they pass the limit.
+func (c *ReverseBin) limitRequestBody(w http.ResponseWriter, r *http.Request) (*limitedBody, error) {
+	if r.ContentLength > c.MaxRequestBodySize {
+		return nil, caddyhttp.Error(http.StatusRequestEntityTooLarge,
+			fmt.Errorf("request body of %d bytes exceeds max_request_body_size %d", r.ContentLength, c.MaxRequestBodySize))
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	body := &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, c.MaxRequestBodySize)}
+	r.Body = body
+	return body, nil
+}
+
+// limitedBody remembers whether the body passed its limit, so the proxy
+// error that follows can be reported as 413 rather than 502.
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// bodyLimitError turns a proxy error caused by an oversized body into 413.
+func bodyLimitError(body *limitedBody, err error) error {
+	if err == nil || body == nil || !body.exceeded {
+		return err
+	}
+	return caddyhttp.Error(http.StatusRequestEntityTooLarge, err)
+}
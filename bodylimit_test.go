@@ -0,0 +1,54 @@
+package reversebin
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestMaxRequestBodySizeRejectsBeforeLaunch verifies a declared oversized body gets 413 without starting a backend.
+func TestMaxRequestBodySizeRejectsBeforeLaunch(t *testing.T) {
+	f := useMockProcesses(t, filepath.Join(t.TempDir(), "app.sock"), http.NotFoundHandler())
+	c := &ReverseBin{
+		MaxRequestBodySize: 10,
+		processes:          map[string]*processState{},
+		logger:             zaptest.NewLogger(t),
+	}
+
+	// This HTTP request tests an upload whose Content-Length exceeds the limit.
+	req := httptest.NewRequest(http.MethodPost, "http://app.example/upload", strings.NewReader("01234567890"))
+	err := c.ServeHTTP(httptest.NewRecorder(), req, nil)
+	var herr caddyhttp.HandlerError
+	if !errors.As(err, &herr) || herr.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %v", err)
+	}
+	if n := f.starts.Load(); n != 0 {
+		t.Fatalf("expected no backend launch, got %d", n)
+	}
+}
+
+// TestMaxRequestBodySizeCutsOffUnknownLength verifies a body of unknown length stops at the limit and maps the proxy error to 413.
+func TestMaxRequestBodySizeCutsOffUnknownLength(t *testing.T) {
+	c := &ReverseBin{MaxRequestBodySize: 10}
+	// This HTTP request tests a chunked upload larger than the limit.
+	req := httptest.NewRequest(http.MethodPost, "http://app.example/upload", io.NopCloser(strings.NewReader("01234567890")))
+	req.ContentLength = -1
+	body, err := c.limitRequestBody(httptest.NewRecorder(), req)
+	if err != nil {
+		t.Fatalf("expected unknown length to pass the upfront check, got %v", err)
+	}
+	if _, err := io.ReadAll(req.Body); err == nil {
+		t.Fatal("expected reading past the limit to fail")
+	}
+	var herr caddyhttp.HandlerError
+	if !errors.As(bodyLimitError(body, errors.New("proxy failed")), &herr) || herr.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected the proxy error to become 413, got %v", herr)
+	}
+}
@@ -0,0 +1,105 @@
+package reversebin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// response_buffer_overflow values.
+const (
+	bufferOverflowFile   = "file"
+	bufferOverflowStream = "stream"
+)
+
+// bufferingTransport reads whole backend responses of unknown length before
+// handing them to the proxy, so the client gets a Content-Length instead of
+// chunked encoding. Up to limit bytes are held in memory; larger bodies
+// spill to a temporary file, or with stream are sent on without a length.
+type bufferingTransport struct {
+	next   http.RoundTripper
+	limit  int64
+	stream bool
+}
+
+func (t *bufferingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || !shouldBufferResponse(req, resp) {
+		return resp, err
+	}
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, resp.Body, t.limit+1)
+	if err != nil && err != io.EOF {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("buffering backend response: %w", err)
+	}
+	if n <= t.limit {
+		_ = resp.Body.Close()
+		setBufferedBody(resp, io.NopCloser(&buf), n)
+		return resp, nil
+	}
+
+	if t.stream {
+		// Send what was read so far and stream the rest.
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(&buf, resp.Body), resp.Body}
+		return resp, nil
+	}
+
+	f, err := os.CreateTemp("", "reverse-bin-response-*")
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("buffering backend response: %w", err)
+	}
+	body := &tempFileBody{File: f}
+	size, err := io.Copy(f, io.MultiReader(&buf, resp.Body))
+	_ = resp.Body.Close()
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = body.Close()
+		return nil, fmt.Errorf("buffering backend response: %w", err)
+	}
+	setBufferedBody(resp, body, size)
+	return resp, nil
+}
+
+// shouldBufferResponse skips responses whose length is already known and
+// those that must stream: upgrades, bodiless responses and event streams.
+func shouldBufferResponse(req *http.Request, resp *http.Response) bool {
+	if resp.ContentLength >= 0 || req.Method == http.MethodHead {
+		return false
+	}
+	if resp.StatusCode < 200 || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType != "text/event-stream"
+}
+
+func setBufferedBody(resp *http.Response, body io.ReadCloser, size int64) {
+	resp.Body = body
+	resp.ContentLength = size
+	resp.TransferEncoding = nil
+	resp.Header.Del("Transfer-Encoding")
+	resp.Header.Set("Content-Length", strconv.FormatInt(size, 10))
+}
+
+// tempFileBody removes its backing file once the proxy is done with it.
+type tempFileBody struct {
+	*os.File
+}
+
+func (b *tempFileBody) Close() error {
+	err := b.File.Close()
+	_ = os.Remove(b.Name())
+	return err
+}
@@ -0,0 +1,80 @@
+package reversebin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestBufferingTransportSetsContentLength verifies chunked backend responses gain a Content-Length whether they fit in memory or spill to disk.
+func TestBufferingTransportSetsContentLength(t *testing.T) {
+	const payload = "hello, buffered world"
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// This HTTP request tests a backend that streams its body without a length.
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		_, _ = io.WriteString(w, payload[:5])
+		w.(http.Flusher).Flush()
+		_, _ = io.WriteString(w, payload[5:])
+	}))
+	defer backend.Close()
+	get := func(limit int64, contentType string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, backend.URL+"/?type="+contentType, nil)
+		req.RequestURI = ""
+		resp, err := (&bufferingTransport{next: http.DefaultTransport, limit: limit}).RoundTrip(req)
+		if err != nil {
+			t.Fatalf("limit %d: RoundTrip: %v", limit, err)
+		}
+		return resp
+	}
+
+	for _, limit := range []int64{1024, 4} {
+		resp := get(limit, "text/plain")
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != payload {
+			t.Fatalf("limit %d: body = %q, want %q", limit, body, payload)
+		}
+		if resp.ContentLength != int64(len(payload)) || resp.Header.Get("Content-Length") != "21" {
+			t.Fatalf("limit %d: content length = %d / %q, want 21", limit, resp.ContentLength, resp.Header.Get("Content-Length"))
+		}
+	}
+
+	// This HTTP request tests event streams are left streaming.
+	resp := get(1024, "text/event-stream")
+	resp.Body.Close()
+	if resp.ContentLength != -1 || !strings.Contains(strings.Join(resp.TransferEncoding, ","), "chunked") {
+		t.Fatalf("event stream was buffered: content length %d, transfer encoding %q", resp.ContentLength, resp.TransferEncoding)
+	}
+}
+
+// TestBufferingTransportStreamsOverflow verifies that with stream, a response over the limit is sent on whole but without a Content-Length, while smaller ones still get one.
+func TestBufferingTransportStreamsOverflow(t *testing.T) {
+	const payload = "hello, streamed world"
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// This HTTP request tests a backend that streams its body without a length.
+		_, _ = io.WriteString(w, payload[:5])
+		w.(http.Flusher).Flush()
+		_, _ = io.WriteString(w, payload[5:])
+	}))
+	defer backend.Close()
+
+	for limit, wantLength := range map[int64]int64{1024: int64(len(payload)), 4: -1} {
+		req := httptest.NewRequest(http.MethodGet, backend.URL, nil)
+		req.RequestURI = ""
+		resp, err := (&bufferingTransport{next: http.DefaultTransport, limit: limit, stream: true}).RoundTrip(req)
+		if err != nil {
+			t.Fatalf("limit %d: RoundTrip: %v", limit, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != payload {
+			t.Fatalf("limit %d: body = %q, want %q", limit, body, payload)
+		}
+		if resp.ContentLength != wantLength {
+			t.Fatalf("limit %d: content length = %d, want %d", limit, resp.ContentLength, wantLength)
+		}
+	}
+}
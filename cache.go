@@ -0,0 +1,167 @@
+package reversebin
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+const defaultCacheMaxSize = 64 << 20
+
+// responseCache is an in-process LRU of complete GET/HEAD responses keyed on
+// method and URL, bounded by the total size of the cached bodies.
+type responseCache struct {
+	ttl     time.Duration
+	maxSize int64
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type cachedResponse struct {
+	key     string
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+func newResponseCache(ttl time.Duration, maxSize int64) *responseCache {
+	return &responseCache{
+		ttl:     ttl,
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// requestCacheKey returns the key for r, or "" when r may not be served from
+// cache. Requests carrying credentials are never cached, since the response
+// may be meant for that client only.
+func requestCacheKey(r *http.Request) string {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return ""
+	}
+	if r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+		return ""
+	}
+	return r.Method + " " + r.Host + r.URL.RequestURI()
+}
+
+func (rc *responseCache) get(key string) (*cachedResponse, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	el, ok := rc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cachedResponse)
+	if time.Now().After(entry.expires) {
+		rc.remove(el)
+		return nil, false
+	}
+	rc.lru.MoveToFront(el)
+	return entry, true
+}
+
+func (rc *responseCache) put(entry *cachedResponse) {
+	size := int64(len(entry.body))
+	if size > rc.maxSize {
+		return
+	}
+	entry.expires = time.Now().Add(rc.ttl)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if el, ok := rc.entries[entry.key]; ok {
+		rc.remove(el)
+	}
+	rc.entries[entry.key] = rc.lru.PushFront(entry)
+	rc.size += size
+	for rc.size > rc.maxSize {
+		rc.remove(rc.lru.Back())
+	}
+}
+
+// remove drops el from the cache. The caller holds rc.mu.
+func (rc *responseCache) remove(el *list.Element) {
+	entry := rc.lru.Remove(el).(*cachedResponse)
+	delete(rc.entries, entry.key)
+	rc.size -= int64(len(entry.body))
+}
+
+// serve writes a cached response to w.
+func (entry *cachedResponse) serve(w http.ResponseWriter, r *http.Request) {
+	for field, values := range entry.header {
+		w.Header()[field] = append([]string(nil), values...)
+	}
+	w.WriteHeader(entry.status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(entry.body)
+	}
+}
+
+// cachingWriter passes a response through to the client while keeping a
+// copy of it, up to limit bytes, for the response cache.
+type cachingWriter struct {
+	*caddyhttp.ResponseWriterWrapper
+	limit    int64
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *cachingWriter) WriteHeader(status int) {
+	// Interim 1xx responses are relayed but not cached.
+	if w.status == 0 && status >= http.StatusOK {
+		w.status = status
+		w.header = w.Header().Clone()
+	}
+	w.ResponseWriterWrapper.WriteHeader(status)
+}
+
+func (w *cachingWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.overflow {
+		if int64(w.body.Len()+len(p)) > w.limit {
+			w.overflow = true
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(p)
+		}
+	}
+	return w.ResponseWriterWrapper.Write(p)
+}
+
+// ReadFrom keeps copies going through Write so they are cached too.
+func (w *cachingWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(struct{ io.Writer }{w}, r)
+}
+
+// cacheable returns the response to store, or nil when it must not be
+// cached: non-2xx, too large, per-client (Set-Cookie, Vary) or marked
+// no-store or private by the backend.
+func (w *cachingWriter) cacheable(key string) *cachedResponse {
+	if w.status < 200 || w.status > 299 || w.overflow {
+		return nil
+	}
+	if w.header.Get("Set-Cookie") != "" || w.header.Get("Vary") != "" {
+		return nil
+	}
+	cc := strings.ToLower(strings.Join(w.header.Values("Cache-Control"), ","))
+	if strings.Contains(cc, "no-store") || strings.Contains(cc, "private") {
+		return nil
+	}
+	return &cachedResponse{key: key, status: w.status, header: w.header, body: w.body.Bytes()}
+}
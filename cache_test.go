@@ -0,0 +1,88 @@
+package reversebin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestResponseCacheEvictsBySizeAndTTL verifies the least recently used entry is evicted to stay within maxSize and expired entries miss.
+func TestResponseCacheEvictsBySizeAndTTL(t *testing.T) {
+	rc := newResponseCache(time.Hour, 10)
+	rc.put(&cachedResponse{key: "a", status: http.StatusOK, body: []byte("aaaa")})
+	rc.put(&cachedResponse{key: "b", status: http.StatusOK, body: []byte("bbbb")})
+	if _, ok := rc.get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	rc.put(&cachedResponse{key: "c", status: http.StatusOK, body: []byte("cccc")})
+	if _, ok := rc.get("b"); ok {
+		t.Fatal("expected least recently used b to be evicted")
+	}
+	if _, ok := rc.get("a"); !ok {
+		t.Fatal("expected recently used a to survive eviction")
+	}
+
+	rc.ttl = -time.Second
+	rc.put(&cachedResponse{key: "d", status: http.StatusOK, body: []byte("d")})
+	if _, ok := rc.get("d"); ok {
+		t.Fatal("expected expired d to miss")
+	}
+}
+
+// TestCachingWriterCacheable verifies only complete, shareable 2xx responses are stored.
+func TestCachingWriterCacheable(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		body   string
+		want   bool
+	}{
+		{name: "ok", status: http.StatusOK, body: "hello", want: true},
+		{name: "not found", status: http.StatusNotFound, body: "hello"},
+		{name: "set-cookie", status: http.StatusOK, header: http.Header{"Set-Cookie": {"id=1"}}, body: "hello"},
+		{name: "no-store", status: http.StatusOK, header: http.Header{"Cache-Control": {"no-store"}}, body: "hello"},
+		{name: "too large", status: http.StatusOK, body: "this body is over the limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &cachingWriter{ResponseWriterWrapper: &caddyhttp.ResponseWriterWrapper{ResponseWriter: httptest.NewRecorder()}, limit: 16}
+			for name, values := range tt.header {
+				w.Header()[name] = values
+			}
+			w.WriteHeader(tt.status)
+			_, _ = w.Write([]byte(tt.body))
+			if got := w.cacheable("k") != nil; got != tt.want {
+				t.Fatalf("cacheable = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestServeHTTPCacheHitSkipsBackend verifies a cached response is served without starting a backend process.
+func TestServeHTTPCacheHitSkipsBackend(t *testing.T) {
+	c := &ReverseBin{cache: newResponseCache(time.Minute, defaultCacheMaxSize), logger: zaptest.NewLogger(t)}
+	c.cache.put(&cachedResponse{
+		key:    "GET app.example/page",
+		status: http.StatusOK,
+		header: http.Header{"Content-Type": {"text/plain"}},
+		body:   []byte("cached"),
+	})
+
+	// This HTTP request tests a repeat GET answered from the cache.
+	req := httptest.NewRequest(http.MethodGet, "http://app.example/page", nil)
+	rec := httptest.NewRecorder()
+	if err := c.ServeHTTP(rec, req, nil); err != nil {
+		t.Fatalf("ServeHTTP: %v", err)
+	}
+	if rec.Code != http.StatusOK || rec.Body.String() != "cached" || rec.Header().Get("Content-Type") != "text/plain" {
+		t.Fatalf("expected cached 200 text/plain response, got %d %q %q", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+	if len(c.processes) != 0 {
+		t.Fatalf("expected no backend process state, got %d", len(c.processes))
+	}
+}
@@ -0,0 +1,28 @@
+//go:build linux
+
+package reversebin
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const cgroupSupported = true
+
+// joinCgroup moves a started backend into the cgroup directory at path,
+// creating it if needed. Processes the backend forks afterwards stay there.
+func joinCgroup(path string, pid int) error {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(path, "cgroup.procs"), os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(strconv.Itoa(pid))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,21 @@
+//go:build linux
+
+package reversebin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestJoinCgroupWritesPIDToProcs verifies the cgroup directory is created and the backend pid written to cgroup.procs.
+func TestJoinCgroupWritesPIDToProcs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reversebin", "app")
+	if err := joinCgroup(path, 4242); err != nil {
+		t.Fatalf("joinCgroup: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(path, "cgroup.procs"))
+	if err != nil || string(data) != "4242" {
+		t.Fatalf("expected cgroup.procs to contain 4242, got %q (%v)", data, err)
+	}
+}
@@ -0,0 +1,86 @@
+package reversebin
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultCircuitBreakerOpenMS = 30000
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker stops requests from reaching a backend that keeps failing.
+// It opens after threshold consecutive failures, rejects requests while
+// open, then lets a single probe through: a successful probe closes it
+// again and a failed one reopens it.
+type circuitBreaker struct {
+	threshold int
+	openFor   time.Duration
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker(threshold int, openFor time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, openFor: openFor}
+}
+
+// allow reports whether a request may go to the backend. Every allowed
+// request must be followed by exactly one call to record.
+func (cb *circuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case circuitOpen:
+		if time.Since(cb.openedAt) < cb.openFor {
+			return false
+		}
+		// This request is the half-open probe; others wait for its result.
+		cb.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		return false
+	}
+	return true
+}
+
+// record feeds the outcome of an allowed request into the breaker.
+func (cb *circuitBreaker) record(ok bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case circuitHalfOpen:
+		if ok {
+			cb.state = circuitClosed
+			cb.failures = 0
+		} else {
+			cb.open()
+		}
+	case circuitClosed:
+		if ok {
+			cb.failures = 0
+			return
+		}
+		cb.failures++
+		if cb.failures >= cb.threshold {
+			cb.open()
+		}
+	}
+	// Requests finishing while open were let in before it opened; they do
+	// not change its state.
+}
+
+// open trips the breaker. The caller holds cb.mu.
+func (cb *circuitBreaker) open() {
+	cb.state = circuitOpen
+	cb.openedAt = time.Now()
+	cb.failures = 0
+}
@@ -0,0 +1,53 @@
+package reversebin
+
+import (
+	"testing"
+	"time"
+)
+
+// TestCircuitBreakerStateMachine verifies the breaker opens after threshold failures, admits one half-open probe and closes or reopens on its result.
+func TestCircuitBreakerStateMachine(t *testing.T) {
+	cb := newCircuitBreaker(2, time.Minute)
+
+	cb.record(false)
+	if !cb.allow() {
+		t.Fatal("expected closed breaker to allow after one failure")
+	}
+	cb.record(false)
+	if cb.allow() {
+		t.Fatal("expected breaker to open after 2 consecutive failures")
+	}
+
+	// Pretend the open period has passed.
+	cb.openedAt = time.Now().Add(-time.Minute)
+	if !cb.allow() {
+		t.Fatal("expected one half-open probe to be allowed")
+	}
+	if cb.allow() {
+		t.Fatal("expected requests during the probe to be rejected")
+	}
+	cb.record(false)
+	if cb.allow() {
+		t.Fatal("expected a failed probe to reopen the breaker")
+	}
+
+	cb.openedAt = time.Now().Add(-time.Minute)
+	if !cb.allow() {
+		t.Fatal("expected a second half-open probe to be allowed")
+	}
+	cb.record(true)
+	if !cb.allow() || !cb.allow() {
+		t.Fatal("expected a successful probe to close the breaker")
+	}
+}
+
+// TestCircuitBreakerResetsOnSuccess verifies only consecutive failures count toward the threshold.
+func TestCircuitBreakerResetsOnSuccess(t *testing.T) {
+	cb := newCircuitBreaker(2, time.Minute)
+	cb.record(false)
+	cb.record(true)
+	cb.record(false)
+	if !cb.allow() {
+		t.Fatal("expected a success between failures to keep the breaker closed")
+	}
+}
@@ -8,269 +8,27 @@ import (
 	"net"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
-	"sync"
 	"syscall"
 	"testing"
 	"time"
 
-	"github.com/caddyserver/caddy/v2"
-	caddycmd "github.com/caddyserver/caddy/v2/cmd"
 	_ "github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/tarasglek/caddy-reverse-bin/internal/testhelper"
 )
 
-// getRepoRoot returns the repository root directory.
-func getRepoRoot() string {
-	_, filename, _, ok := runtime.Caller(0)
-	if !ok {
-		panic("unable to determine current file path")
-	}
-	// We're in cmd/caddy/, repo root is ../../
-	return filepath.Clean(filepath.Join(filepath.Dir(filename), "..", ".."))
-}
-
-func requireIntegration(t *testing.T) {
-	t.Helper()
-	if testing.Short() {
-		t.Skip("skipping integration test in short mode")
-	}
-}
-
-// GetFreePort asks the kernel for a free open port that is ready to use.
-func GetFreePort() (port int, err error) {
-	var a *net.TCPAddr
-	if a, err = net.ResolveTCPAddr("tcp", "localhost:0"); err == nil {
-		var l *net.TCPListener
-		if l, err = net.ListenTCP("tcp", a); err == nil {
-			defer l.Close()
-			return l.Addr().(*net.TCPAddr).Port, nil
-		}
-	}
-	return
-}
-
-// createSocketPath creates a unique temp socket path.
-func createSocketPath(t *testing.T) string {
-	t.Helper()
-	if runtime.GOOS == "windows" {
-		t.Skip("Unix sockets not supported on Windows")
-	}
-	f, err := os.CreateTemp("", "reverse-bin-*.sock")
-	if err != nil {
-		t.Fatalf("failed to create temp file for socket path: %s", err)
-	}
-	socketPath := f.Name()
-	f.Close()
-	_ = os.Remove(socketPath)
-	t.Cleanup(func() {
-		_ = os.Remove(socketPath)
-	})
-	return socketPath
-}
-
-func createExecutableScript(t *testing.T, dir, name, content string) string {
-	t.Helper()
-	path := filepath.Join(dir, name)
-	if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
-		t.Fatalf("failed to write script %s: %v", path, err)
-	}
-	return path
-}
-
-type pathCheck struct {
-	Label         string
-	Path          string
-	MustBeDir     bool
-	MustBeRegular bool
-}
-
-func requirePaths(t *testing.T, checks ...pathCheck) {
-	t.Helper()
-	for _, c := range checks {
-		info, err := os.Stat(c.Path)
-		if err != nil {
-			t.Fatalf("required %s missing/unreadable at %s: %v", c.Label, c.Path, err)
-		}
-		if c.MustBeDir && !info.IsDir() {
-			t.Fatalf("required %s is not a directory: %s", c.Label, c.Path)
-		}
-		if c.MustBeRegular && !info.Mode().IsRegular() {
-			t.Fatalf("required %s is not a regular file: %s", c.Label, c.Path)
-		}
-	}
-}
-
-type fixtures struct {
-	GoEchoBin string
-	AppDir    string
-}
-
-var (
-	goEchoBuildOnce sync.Once
-	goEchoBuildPath string
-	goEchoBuildErr  error
-)
-
-func mustFixtures(t *testing.T) fixtures {
-	t.Helper()
-	repoRoot := getRepoRoot()
-	appDir := filepath.Join(repoRoot, "examples/reverse-proxy/apps/go-echo")
-	mainGo := filepath.Join(appDir, "main.go")
-	requirePaths(t,
-		pathCheck{Label: "go echo app", Path: mainGo, MustBeRegular: true},
-		pathCheck{Label: "dynamic app dir", Path: appDir, MustBeDir: true},
-	)
-
-	goEchoBuildOnce.Do(func() {
-		binDir, err := os.MkdirTemp("", "reverse-bin-go-echo-*")
-		if err != nil {
-			goEchoBuildErr = fmt.Errorf("failed to create go echo build dir: %w", err)
-			return
-		}
-		goEchoBuildPath = filepath.Join(binDir, "go-echo")
-		if runtime.GOOS == "windows" {
-			goEchoBuildPath += ".exe"
-		}
-		cmd := exec.Command("go", "build", "-o", goEchoBuildPath, ".")
-		cmd.Dir = appDir
-		out, err := cmd.CombinedOutput()
-		if err != nil {
-			goEchoBuildErr = fmt.Errorf("failed to build go echo app: %w\n%s", err, string(out))
-		}
-	})
-	if goEchoBuildErr != nil {
-		t.Fatal(goEchoBuildErr)
-	}
-	return fixtures{GoEchoBin: goEchoBuildPath, AppDir: appDir}
-}
-
-func renderTemplate(input string, values map[string]string) string {
-	replacements := make([]string, 0, len(values)*2)
-	for k, v := range values {
-		replacements = append(replacements, "{{"+k+"}}", v)
-	}
-	return strings.NewReplacer(replacements...).Replace(input)
-}
-
-func createTestingTransport() *http.Transport {
-	dialer := net.Dialer{Timeout: 5 * time.Second, KeepAlive: 5 * time.Second}
-	dialContext := func(ctx context.Context, network, addr string) (net.Conn, error) {
-		parts := strings.Split(addr, ":")
-		destAddr := fmt.Sprintf("127.0.0.1:%s", parts[len(parts)-1])
-		return dialer.DialContext(ctx, network, destAddr)
-	}
-	return &http.Transport{DialContext: dialContext}
-}
-
-func newTestHTTPClient() *http.Client {
-	return &http.Client{
-		Transport: createTestingTransport(),
-		Timeout:   10 * time.Second,
-	}
-}
-
-func assertGetResponse(t *testing.T, client *http.Client, requestURI string, expectedStatusCode int, expectedBodyContains string, invariant string) (*http.Response, string) {
-	t.Helper()
-
-	var (
-		resp *http.Response
-		err  error
-	)
-	deadline := time.Now().Add(2 * time.Second)
-	for {
-		resp, err = client.Get(requestURI)
-		if err == nil {
-			break
-		}
-		if time.Now().After(deadline) {
-			t.Fatalf("%s: failed to call server: %v", invariant, err)
-		}
-		time.Sleep(50 * time.Millisecond)
-	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		t.Fatalf("%s: unable to read response body: %v", invariant, err)
-	}
-	body := string(bodyBytes)
-
-	if resp.StatusCode != expectedStatusCode {
-		t.Fatalf("%s: requesting %q expected status %d but got %d (body: %s)", invariant, requestURI, expectedStatusCode, resp.StatusCode, body)
-	}
-	if expectedBodyContains != "" && !strings.Contains(body, expectedBodyContains) {
-		t.Fatalf("%s: requesting %q expected body to contain %q but got %q", invariant, requestURI, expectedBodyContains, body)
-	}
-	return resp, body
+// TestMain removes the built fixtures once the integration tests finish.
+func TestMain(m *testing.M) {
+	os.Exit(testhelper.Main(m))
 }
 
 func ptr(s string) *string {
 	return &s
 }
 
-func processExists(pid int) bool {
-	if pid <= 0 {
-		return false
-	}
-	if runtime.GOOS == "windows" {
-		return true
-	}
-	return syscall.Kill(pid, 0) == nil
-}
-
-type reverseProxySetup struct {
-	Port int
-}
-
-func createReverseProxySetup(t *testing.T, handleBlock string, values map[string]string) (*reverseProxySetup, func()) {
-	t.Helper()
-
-	port, err := GetFreePort()
-	if err != nil {
-		t.Fatalf("failed to get free port: %v", err)
-	}
-
-	vars := map[string]string{}
-	for k, v := range values {
-		vars[k] = v
-	}
-	resolvedHandle := renderTemplate(handleBlock, vars)
-
-	caddyfilePath := filepath.Join(t.TempDir(), "Caddyfile")
-	fixture := `
-{
-	admin off
-	http_port {{HTTP_PORT}}
-}
-
-http://localhost:{{HTTP_PORT}} {
-	{{HANDLE_BLOCK}}
-}
-`
-	rendered := renderTemplate(fixture, map[string]string{
-		"HTTP_PORT":    fmt.Sprintf("%d", port),
-		"HANDLE_BLOCK": resolvedHandle,
-	})
-	if err := os.WriteFile(caddyfilePath, []byte(rendered), 0o600); err != nil {
-		t.Fatalf("failed to write temp Caddyfile: %v", err)
-	}
-
-	prevArgs := os.Args
-	os.Args = []string{"caddy", "run", "--config", caddyfilePath, "--adapter", "caddyfile"}
-	go caddycmd.Main()
-
-	dispose := func() {
-		os.Args = prevArgs
-		_ = caddy.Stop()
-	}
-
-	return &reverseProxySetup{Port: port}, dispose
-}
-
-func createBasicReverseProxySetup(t *testing.T, f fixtures) (*reverseProxySetup, func()) {
+func createBasicReverseProxySetup(t *testing.T, f testhelper.Fixtures) (*testhelper.ReverseProxySetup, func()) {
 	t.Helper()
 
 	tmpDir := t.TempDir()
@@ -282,7 +40,7 @@ func createBasicReverseProxySetup(t *testing.T, f fixtures) (*reverseProxySetup,
 		}
 	}`
 
-	return createReverseProxySetup(t, handleBlock, map[string]string{
+	return testhelper.StartReverseProxy(t, handleBlock, map[string]string{
 		"GO_ECHO":    f.GoEchoBin,
 		"APP_SOCKET": filepath.Join(tmpDir, "app.sock"),
 	})
@@ -292,14 +50,14 @@ func createBasicReverseProxySetup(t *testing.T, f fixtures) (*reverseProxySetup,
 // Strategy: configure reverse-bin with explicit exec + reverse_proxy_to, then
 // verify one request succeeds through the Unix-socket backend.
 func TestBasicReverseProxy(t *testing.T) {
-	requireIntegration(t)
+	testhelper.RequireIntegration(t)
 
-	setup, dispose := createBasicReverseProxySetup(t, mustFixtures(t))
+	setup, dispose := createBasicReverseProxySetup(t, testhelper.MustFixtures(t))
 	defer dispose()
 
 	// Static baseline: request is routed to reverse-bin static upstream and
 	// should include echoed request path from backend response.
-	_, _ = assertGetResponse(t, newTestHTTPClient(), fmt.Sprintf("http://localhost:%d/test/path", setup.Port), 200, "echo-backend", "basic reverse proxy must route request to echo backend")
+	_, _ = testhelper.AssertGetResponse(t, testhelper.NewHTTPClient(), fmt.Sprintf("http://localhost:%d/test/path", setup.Port), 200, "echo-backend", "basic reverse proxy must route request to echo backend")
 }
 
 // TestProcessCrashAndRestart verifies reverse-bin restarts a crashed backend process.
@@ -308,11 +66,11 @@ func TestBasicReverseProxy(t *testing.T) {
 //  2. Call shared backend directly over Unix socket at /crash to force process exit.
 //  3. Second request via Caddy succeeds and returns a different PID (restarted process).
 func TestProcessCrashAndRestart(t *testing.T) {
-	requireIntegration(t)
-	f := mustFixtures(t)
+	testhelper.RequireIntegration(t)
+	f := testhelper.MustFixtures(t)
 
-	socketPath := createSocketPath(t)
-	setup, dispose := createReverseProxySetup(t, `handle /test/* {
+	socketPath := testhelper.SocketPath(t)
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /test/* {
 		reverse-bin {
 			exec {{GO_ECHO}}
 			reverse_proxy_to unix/{{APP_SOCKET}}
@@ -338,10 +96,10 @@ func TestProcessCrashAndRestart(t *testing.T) {
 		return payload.PID
 	}
 
-	client := newTestHTTPClient()
+	client := testhelper.NewHTTPClient()
 
 	// First request via Caddy proves backend starts and serves traffic.
-	_, body1 := assertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/test/first", setup.Port), 200, "\"pid\":", "first request must return backend pid before crash")
+	_, body1 := testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/test/first", setup.Port), 200, "\"pid\":", "first request must return backend pid before crash")
 	pid1 := parsePID(t, body1)
 
 	// Direct Unix-socket request to /crash intentionally terminates backend process.
@@ -372,7 +130,7 @@ func TestProcessCrashAndRestart(t *testing.T) {
 	}
 
 	// Second request via Caddy must succeed and come from a new backend PID.
-	_, body2 := assertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/test/second", setup.Port), 200, "\"pid\":", "second request must succeed with restarted backend pid")
+	_, body2 := testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/test/second", setup.Port), 200, "\"pid\":", "second request must succeed with restarted backend pid")
 	pid2 := parsePID(t, body2)
 	if pid1 == pid2 {
 		t.Fatalf("expected backend restart with different pid, got same pid=%d (first=%q second=%q)", pid1, body1, body2)
@@ -386,11 +144,11 @@ func TestProcessCrashAndRestart(t *testing.T) {
 //  3. Assert /dynamic/path is served by discovered backend, while /path is
 //     served by static route. This proves matcher scoping + discovery/proxy flow.
 func TestDynamicDiscovery(t *testing.T) {
-	requireIntegration(t)
-	f := mustFixtures(t)
+	testhelper.RequireIntegration(t)
+	f := testhelper.MustFixtures(t)
 
-	socketPath := createSocketPath(t)
-	detector := createExecutableScript(t, t.TempDir(), "detector-static.py", `#!/usr/bin/env python3
+	socketPath := testhelper.SocketPath(t)
+	detector := testhelper.WriteScript(t, t.TempDir(), "detector-static.py", `#!/usr/bin/env python3
 import json
 import sys
 from pathlib import Path
@@ -406,7 +164,7 @@ result = {
 print(json.dumps(result))
 `)
 
-	setup, dispose := createReverseProxySetup(t, `# Only /dynamic/* routes use dynamic discovery.
+	setup, dispose := testhelper.StartReverseProxy(t, `# Only /dynamic/* routes use dynamic discovery.
 	handle /dynamic/* {
 		reverse-bin {
 			dynamic_proxy_detector {{DETECTOR}} {{GO_ECHO}} {{SOCKET_PATH}}
@@ -422,29 +180,29 @@ print(json.dumps(result))
 	})
 	defer dispose()
 
-	client := newTestHTTPClient()
+	client := testhelper.NewHTTPClient()
 
 	// Positive path: /dynamic/* must go through dynamic discovery to the
 	// discovered echo backend, identified by explicit marker in body.
-	_, _ = assertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/dynamic/path", setup.Port), 200, "echo-backend", "dynamic route must be served by discovered backend")
+	_, _ = testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/dynamic/path", setup.Port), 200, "echo-backend", "dynamic route must be served by discovered backend")
 
 	// Control path: /path must NOT hit dynamic discovery; it should match the
 	// explicit static handler and return the known marker body.
-	_, _ = assertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/path", setup.Port), 200, "non-dynamic", "non-dynamic route must match static handler")
+	_, _ = testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/path", setup.Port), 200, "non-dynamic", "non-dynamic route must match static handler")
 }
 
 // TestDynamicDiscovery_DetectorFailure validates failure handling when the
 // dynamic detector exits non-zero for a dynamic route.
 func TestDynamicDiscovery_DetectorFailure(t *testing.T) {
-	requireIntegration(t)
+	testhelper.RequireIntegration(t)
 
-	failDetector := createExecutableScript(t, t.TempDir(), "detector-fail.py", `#!/usr/bin/env python3
+	failDetector := testhelper.WriteScript(t, t.TempDir(), "detector-fail.py", `#!/usr/bin/env python3
 import sys
 print("detector failed on purpose", file=sys.stderr)
 sys.exit(2)
 `)
 
-	setup, dispose := createReverseProxySetup(t, `handle /dynamic/* {
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /dynamic/* {
 		reverse-bin {
 			dynamic_proxy_detector {{DETECTOR}} {path}
 		}
@@ -454,19 +212,19 @@ sys.exit(2)
 	}`, map[string]string{"DETECTOR": failDetector})
 	defer dispose()
 
-	client := newTestHTTPClient()
+	client := testhelper.NewHTTPClient()
 
 	// Control request: non-dynamic route should remain healthy and return static body.
-	_, _ = assertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/ok", setup.Port), 200, "ok", "control route must remain healthy when detector fails")
+	_, _ = testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/ok", setup.Port), 200, "ok", "control route must remain healthy when detector fails")
 
 	// Dynamic request: failing detector must surface as service unavailable.
-	_, _ = assertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/dynamic/fail", setup.Port), 503, "", "dynamic route must return 503 when detector exits non-zero")
+	_, _ = testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/dynamic/fail", setup.Port), 503, "", "dynamic route must return 503 when detector exits non-zero")
 }
 
 // TestHealthCheck verifies Unix health behavior for GET, HEAD, and omitted health_check.
 func TestHealthCheck(t *testing.T) {
-	requireIntegration(t)
-	f := mustFixtures(t)
+	testhelper.RequireIntegration(t)
+	f := testhelper.MustFixtures(t)
 
 	testCases := []struct {
 		name            string
@@ -480,9 +238,9 @@ func TestHealthCheck(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			socketPath := createSocketPath(t)
+			socketPath := testhelper.SocketPath(t)
 
-			setup, dispose := createReverseProxySetup(t, `handle_path /ready/* {
+			setup, dispose := testhelper.StartReverseProxy(t, `handle_path /ready/* {
 			reverse-bin {
 				exec {{GO_ECHO}}
 				reverse_proxy_to unix/{{APP_SOCKET}}
@@ -496,10 +254,10 @@ func TestHealthCheck(t *testing.T) {
 			})
 			defer dispose()
 
-			client := newTestHTTPClient()
+			client := testhelper.NewHTTPClient()
 
 			// Request through Caddy to prove proxying works with the configured health mode.
-			_, pingBody := assertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/ready/ping", setup.Port), 200, "", "ready endpoint must proxy request to backend")
+			_, pingBody := testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/ready/ping", setup.Port), 200, "", "ready endpoint must proxy request to backend")
 			var pingPayload struct {
 				Backend string `json:"backend"`
 				Path    string `json:"path"`
@@ -512,7 +270,7 @@ func TestHealthCheck(t *testing.T) {
 			}
 
 			// Request backend debug endpoint to verify whether /health was probed and by which method.
-			_, healthBody := assertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/ready/health-last", setup.Port), 200, "", "health-last endpoint must return health probe metadata")
+			_, healthBody := testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/ready/health-last", setup.Port), 200, "", "health-last endpoint must return health probe metadata")
 			if !strings.Contains(healthBody, "last_health_method") {
 				t.Fatalf("/ready/health-last response must include last_health_method (body=%s)", healthBody)
 			}
@@ -539,15 +297,15 @@ func TestHealthCheck(t *testing.T) {
 // Strategy: start a long-running process that never binds reverse_proxy_to, so health
 // cannot succeed and reverse-bin must fail request with service unavailable.
 func TestHealthCheckAcceptsExplicitUnauthorizedStatus(t *testing.T) {
-	requireIntegration(t)
-	f := mustFixtures(t)
+	testhelper.RequireIntegration(t)
+	f := testhelper.MustFixtures(t)
 
-	port, err := GetFreePort()
+	port, err := testhelper.FreePort()
 	if err != nil {
 		t.Fatalf("failed to allocate backend port: %v", err)
 	}
 
-	setup, dispose := createReverseProxySetup(t, `handle /registry/* {
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /registry/* {
 		reverse-bin {
 			exec {{GO_ECHO}}
 			env REVERSE_BIN_HOST=127.0.0.1 REVERSE_BIN_PORT={{BACKEND_PORT}} HEALTH_STATUS=401
@@ -560,21 +318,21 @@ func TestHealthCheckAcceptsExplicitUnauthorizedStatus(t *testing.T) {
 	})
 	defer dispose()
 
-	client := newTestHTTPClient()
+	client := testhelper.NewHTTPClient()
 	// HTTP request verifies reverse-bin accepts an explicit 401 health status before proxying normal traffic.
-	_, _ = assertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/registry/ok", setup.Port), 200, "echo-backend", "explicit 401 health status must allow backend startup and proxying")
+	_, _ = testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/registry/ok", setup.Port), 200, "echo-backend", "explicit 401 health status must allow backend startup and proxying")
 }
 
 func TestHealthCheckAcceptsRedirectWithoutFollowing(t *testing.T) {
-	requireIntegration(t)
-	f := mustFixtures(t)
+	testhelper.RequireIntegration(t)
+	f := testhelper.MustFixtures(t)
 
-	port, err := GetFreePort()
+	port, err := testhelper.FreePort()
 	if err != nil {
 		t.Fatalf("failed to allocate backend port: %v", err)
 	}
 
-	setup, dispose := createReverseProxySetup(t, `handle /redirect-health/* {
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /redirect-health/* {
 		reverse-bin {
 			exec {{GO_ECHO}}
 			reverse_proxy_to 127.0.0.1:{{BACKEND_PORT}}
@@ -588,19 +346,19 @@ func TestHealthCheckAcceptsRedirectWithoutFollowing(t *testing.T) {
 	defer dispose()
 
 	// HTTP request verifies health treats the backend's 302 as healthy instead of following its Location.
-	_, _ = assertGetResponse(t, newTestHTTPClient(), fmt.Sprintf("http://localhost:%d/redirect-health/ok", setup.Port), 200, "echo-backend", "health probe must accept 3xx response without following redirect")
+	_, _ = testhelper.AssertGetResponse(t, testhelper.NewHTTPClient(), fmt.Sprintf("http://localhost:%d/redirect-health/ok", setup.Port), 200, "echo-backend", "health probe must accept 3xx response without following redirect")
 }
 
 func TestHealthCheckSetsForwardedHeaders(t *testing.T) {
-	requireIntegration(t)
-	f := mustFixtures(t)
+	testhelper.RequireIntegration(t)
+	f := testhelper.MustFixtures(t)
 
-	port, err := GetFreePort()
+	port, err := testhelper.FreePort()
 	if err != nil {
 		t.Fatalf("failed to allocate backend port: %v", err)
 	}
 
-	setup, dispose := createReverseProxySetup(t, `handle /fwd/* {
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /fwd/* {
 		reverse-bin {
 			exec {{GO_ECHO}}
 			reverse_proxy_to 127.0.0.1:{{BACKEND_PORT}}
@@ -615,22 +373,22 @@ func TestHealthCheckSetsForwardedHeaders(t *testing.T) {
 
 	// HTTP request triggers backend startup; the health probe must receive
 	// X-Forwarded-Host and X-Forwarded-Proto before proxying can start.
-	_, _ = assertGetResponse(t, newTestHTTPClient(), fmt.Sprintf("http://localhost:%d/fwd/ok", setup.Port), 200, "echo-backend", "health probe must include forwarded host/proto headers from triggering request")
+	_, _ = testhelper.AssertGetResponse(t, testhelper.NewHTTPClient(), fmt.Sprintf("http://localhost:%d/fwd/ok", setup.Port), 200, "echo-backend", "health probe must include forwarded host/proto headers from triggering request")
 }
 
 func TestHealthFailureTimeout(t *testing.T) {
-	requireIntegration(t)
+	testhelper.RequireIntegration(t)
 
-	port, err := GetFreePort()
+	port, err := testhelper.FreePort()
 	if err != nil {
 		t.Fatalf("failed to get free backend port: %v", err)
 	}
 
-	sleeper := createExecutableScript(t, t.TempDir(), "sleep-forever.sh", `#!/usr/bin/env sh
+	sleeper := testhelper.WriteScript(t, t.TempDir(), "sleep-forever.sh", `#!/usr/bin/env sh
 sleep 30
 `)
 
-	setup, dispose := createReverseProxySetup(t, `handle /fail/* {
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /fail/* {
 		reverse-bin {
 			exec {{SLEEPER}}
 			reverse_proxy_to 127.0.0.1:{{BACKEND_PORT}}
@@ -642,19 +400,19 @@ sleep 30
 	})
 	defer dispose()
 
-	client := &http.Client{Transport: createTestingTransport(), Timeout: 20 * time.Second}
+	client := &http.Client{Transport: testhelper.NewTransport(), Timeout: 20 * time.Second}
 	// Request a proxied route to trigger backend startup + health polling.
 	// Invariant: backend never binds the configured upstream, so health times out and reverse-bin must return 503.
-	_, _ = assertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/fail/test", setup.Port), 503, "", "request must fail with 503 when health polling times out")
+	_, _ = testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/fail/test", setup.Port), 503, "", "request must fail with 503 when health polling times out")
 }
 
 // TestLifecycleIdleTimeout verifies a backend process is terminated after configured idle_timeout_ms.
 func TestLifecycleIdleTimeout(t *testing.T) {
-	requireIntegration(t)
-	f := mustFixtures(t)
+	testhelper.RequireIntegration(t)
+	f := testhelper.MustFixtures(t)
 
-	socketPath := createSocketPath(t)
-	setup, dispose := createReverseProxySetup(t, `handle /test/* {
+	socketPath := testhelper.SocketPath(t)
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /test/* {
 		reverse-bin {
 			exec {{GO_ECHO}}
 			reverse_proxy_to unix/{{APP_SOCKET}}
@@ -681,17 +439,17 @@ func TestLifecycleIdleTimeout(t *testing.T) {
 		return payload.PID
 	}
 
-	client := newTestHTTPClient()
+	client := testhelper.NewHTTPClient()
 
 	// First request starts backend process and returns its PID.
-	_, body1 := assertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/test/first", setup.Port), 200, "", "first idle-timeout request must start backend and return pid")
+	_, body1 := testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/test/first", setup.Port), 200, "", "first idle-timeout request must start backend and return pid")
 	pid1 := parsePID(t, body1)
 
 	// Wait without traffic so idle timeout can fire naturally.
 	time.Sleep(250 * time.Millisecond)
 
 	// Next request should be served by a newly spawned process.
-	_, body2 := assertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/test/second", setup.Port), 200, "", "second idle-timeout request must succeed after respawn")
+	_, body2 := testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/test/second", setup.Port), 200, "", "second idle-timeout request must succeed after respawn")
 	pid2 := parsePID(t, body2)
 	if pid2 == pid1 {
 		t.Fatalf("expected new pid after idle timeout; got same pid=%d (first=%s second=%s)", pid1, body1, body2)
@@ -702,13 +460,13 @@ func TestLifecycleIdleTimeout(t *testing.T) {
 // with separate Unix sockets and processes.
 // TestHealthImmediateExitFailsFast verifies startup failure is reported from process exit instead of health timeout.
 func TestHealthImmediateExitFailsFast(t *testing.T) {
-	requireIntegration(t)
+	testhelper.RequireIntegration(t)
 
-	exiter := createExecutableScript(t, t.TempDir(), "exit-42.sh", `#!/usr/bin/env sh
+	exiter := testhelper.WriteScript(t, t.TempDir(), "exit-42.sh", `#!/usr/bin/env sh
 exit 42
 `)
-	socketPath := createSocketPath(t)
-	setup, dispose := createReverseProxySetup(t, `handle /failfast/* {
+	socketPath := testhelper.SocketPath(t)
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /failfast/* {
 		reverse-bin {
 			exec {{EXITER}}
 			reverse_proxy_to unix/{{APP_SOCKET}}
@@ -719,10 +477,10 @@ exit 42
 	})
 	defer dispose()
 
-	client := newTestHTTPClient()
+	client := testhelper.NewHTTPClient()
 	started := time.Now()
 	// HTTP request exercises startup path where backend exits before health can pass.
-	_, _ = assertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/failfast/test", setup.Port), 503, "", "immediate backend exit must return 503")
+	_, _ = testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/failfast/test", setup.Port), 503, "", "immediate backend exit must return 503")
 	elapsed := time.Since(started)
 	if elapsed >= 2*time.Second {
 		t.Fatalf("expected immediate backend exit to fail fast under 2s, took %s", elapsed)
@@ -731,13 +489,13 @@ exit 42
 
 // TestLifecycleIdleTimeoutKillsChildProcessGroup verifies idle cleanup terminates child processes.
 func TestLifecycleIdleTimeoutKillsChildProcessGroup(t *testing.T) {
-	requireIntegration(t)
+	testhelper.RequireIntegration(t)
 	if runtime.GOOS == "windows" {
 		t.Skip("process groups differ on Windows")
 	}
 
-	socketPath := createSocketPath(t)
-	backend := createExecutableScript(t, t.TempDir(), "parent-child.py", `#!/usr/bin/env python3
+	socketPath := testhelper.SocketPath(t)
+	backend := testhelper.WriteScript(t, t.TempDir(), "parent-child.py", `#!/usr/bin/env python3
 import http.server, json, os, signal, socket, subprocess, sys
 
 socket_path = os.environ["SOCKET_PATH"]
@@ -764,7 +522,7 @@ def stop(signum, frame):
 signal.signal(signal.SIGTERM, stop)
 server.serve_forever()
 `)
-	setup, dispose := createReverseProxySetup(t, `handle /child/* {
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /child/* {
 		reverse-bin {
 			exec {{BACKEND}}
 			reverse_proxy_to unix/{{APP_SOCKET}}
@@ -778,7 +536,7 @@ server.serve_forever()
 	defer dispose()
 
 	// HTTP request exercises backend startup and returns parent/child PIDs for idle cleanup assertion.
-	_, body := assertGetResponse(t, newTestHTTPClient(), fmt.Sprintf("http://localhost:%d/child/pids", setup.Port), 200, "child", "child cleanup request must return spawned child pid")
+	_, body := testhelper.AssertGetResponse(t, testhelper.NewHTTPClient(), fmt.Sprintf("http://localhost:%d/child/pids", setup.Port), 200, "child", "child cleanup request must return spawned child pid")
 	var payload struct {
 		Parent int `json:"parent"`
 		Child  int `json:"child"`
@@ -791,20 +549,20 @@ server.serve_forever()
 	}
 
 	time.Sleep(500 * time.Millisecond)
-	if processExists(payload.Child) {
+	if testhelper.ProcessExists(payload.Child) {
 		t.Fatalf("expected child pid %d to be gone after idle timeout process-group stop", payload.Child)
 	}
 }
 
 // TestCleanupStopsBackendProcessGroup verifies module cleanup terminates running backend descendants.
 func TestCleanupStopsBackendProcessGroup(t *testing.T) {
-	requireIntegration(t)
+	testhelper.RequireIntegration(t)
 	if runtime.GOOS == "windows" {
 		t.Skip("process groups differ on Windows")
 	}
 
-	socketPath := createSocketPath(t)
-	backend := createExecutableScript(t, t.TempDir(), "cleanup-parent-child.py", `#!/usr/bin/env python3
+	socketPath := testhelper.SocketPath(t)
+	backend := testhelper.WriteScript(t, t.TempDir(), "cleanup-parent-child.py", `#!/usr/bin/env python3
 import http.server, json, os, signal, socket, subprocess, sys
 
 socket_path = os.environ["SOCKET_PATH"]
@@ -831,7 +589,7 @@ def stop(signum, frame):
 signal.signal(signal.SIGTERM, stop)
 server.serve_forever()
 `)
-	setup, dispose := createReverseProxySetup(t, `handle /cleanup/* {
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /cleanup/* {
 		reverse-bin {
 			exec {{BACKEND}}
 			reverse_proxy_to unix/{{APP_SOCKET}}
@@ -843,7 +601,7 @@ server.serve_forever()
 	})
 
 	// HTTP request exercises backend startup and returns child PID for cleanup assertion.
-	_, body := assertGetResponse(t, newTestHTTPClient(), fmt.Sprintf("http://localhost:%d/cleanup/pids", setup.Port), 200, "child", "cleanup request must return spawned child pid")
+	_, body := testhelper.AssertGetResponse(t, testhelper.NewHTTPClient(), fmt.Sprintf("http://localhost:%d/cleanup/pids", setup.Port), 200, "child", "cleanup request must return spawned child pid")
 	var payload struct {
 		Child int `json:"child"`
 	}
@@ -856,20 +614,20 @@ server.serve_forever()
 
 	dispose()
 	time.Sleep(500 * time.Millisecond)
-	if processExists(payload.Child) {
+	if testhelper.ProcessExists(payload.Child) {
 		t.Fatalf("expected child pid %d to be gone after cleanup process-group stop", payload.Child)
 	}
 }
 
 // TestUnixSocketMissingRestartsWithoutLeakingOldProcess verifies unhealthy alive backend is stopped before replacement.
 func TestUnixSocketMissingRestartsWithoutLeakingOldProcess(t *testing.T) {
-	requireIntegration(t)
+	testhelper.RequireIntegration(t)
 	if runtime.GOOS == "windows" {
 		t.Skip("Unix sockets not supported on Windows")
 	}
 
-	socketPath := createSocketPath(t)
-	backend := createExecutableScript(t, t.TempDir(), "removable-socket.py", `#!/usr/bin/env python3
+	socketPath := testhelper.SocketPath(t)
+	backend := testhelper.WriteScript(t, t.TempDir(), "removable-socket.py", `#!/usr/bin/env python3
 import http.server, json, os, socket
 
 socket_path = os.environ["SOCKET_PATH"]
@@ -898,7 +656,7 @@ class Handler(http.server.BaseHTTPRequestHandler):
 server = UnixHTTPServer(socket_path, Handler)
 server.serve_forever()
 `)
-	setup, dispose := createReverseProxySetup(t, `handle /missing-socket/* {
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /missing-socket/* {
 		reverse-bin {
 			exec {{BACKEND}}
 			reverse_proxy_to unix/{{APP_SOCKET}}
@@ -924,9 +682,9 @@ server.serve_forever()
 		return payload.PID
 	}
 
-	client := newTestHTTPClient()
+	client := testhelper.NewHTTPClient()
 	// HTTP request exercises initial backend startup and returns original PID.
-	_, body1 := assertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/missing-socket/first", setup.Port), 200, "pid", "initial missing-socket request must return backend pid")
+	_, body1 := testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/missing-socket/first", setup.Port), 200, "pid", "initial missing-socket request must return backend pid")
 	pid1 := parsePID(t, body1)
 
 	directTransport := &http.Transport{
@@ -945,25 +703,25 @@ server.serve_forever()
 	_ = resp.Body.Close()
 
 	// HTTP request exercises supervisor restart after detecting missing Unix socket.
-	_, body2 := assertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/missing-socket/second", setup.Port), 200, "pid", "missing-socket request must restart backend")
+	_, body2 := testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/missing-socket/second", setup.Port), 200, "pid", "missing-socket request must restart backend")
 	pid2 := parsePID(t, body2)
 	if pid1 == pid2 {
 		t.Fatalf("expected restarted backend pid to differ after missing socket, got same pid=%d", pid1)
 	}
 	time.Sleep(500 * time.Millisecond)
-	if processExists(pid1) {
+	if testhelper.ProcessExists(pid1) {
 		t.Fatalf("expected old backend pid %d to be gone after missing socket restart", pid1)
 	}
 }
 
 func TestMultipleApps(t *testing.T) {
-	requireIntegration(t)
-	f := mustFixtures(t)
+	testhelper.RequireIntegration(t)
+	f := testhelper.MustFixtures(t)
 
-	socket1 := createSocketPath(t)
-	socket2 := createSocketPath(t)
+	socket1 := testhelper.SocketPath(t)
+	socket2 := testhelper.SocketPath(t)
 
-	setup, dispose := createReverseProxySetup(t, `handle_path /app1/* {
+	setup, dispose := testhelper.StartReverseProxy(t, `handle_path /app1/* {
 		reverse-bin {
 			exec {{GO_ECHO}}
 			reverse_proxy_to unix/{{APP_SOCKET_1}}
@@ -999,15 +757,15 @@ func TestMultipleApps(t *testing.T) {
 		return payload.PID, payload.Path, payload.Backend
 	}
 
-	client := newTestHTTPClient()
+	client := testhelper.NewHTTPClient()
 
-	_, body1 := assertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/app1/test", setup.Port), 200, "", "app1 route must be served by its backend")
+	_, body1 := testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/app1/test", setup.Port), 200, "", "app1 route must be served by its backend")
 	pid1, path1, backend1 := parse(t, body1)
 	if backend1 != "echo-backend" || path1 != "/test" {
 		t.Fatalf("unexpected app1 payload: %s", body1)
 	}
 
-	_, body2 := assertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/app2/test", setup.Port), 200, "", "app2 route must be served by its backend")
+	_, body2 := testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/app2/test", setup.Port), 200, "", "app2 route must be served by its backend")
 	pid2, path2, backend2 := parse(t, body2)
 	if backend2 != "echo-backend" || path2 != "/test" {
 		t.Fatalf("unexpected app2 payload: %s", body2)
@@ -1017,3 +775,378 @@ func TestMultipleApps(t *testing.T) {
 		t.Fatalf("expected distinct backend processes for app1/app2, got same pid=%d (app1=%s app2=%s)", pid1, body1, body2)
 	}
 }
+
+// TestAccessLogBackendLatency verifies access_log_backend_latency adds
+// backend and startup latency fields to the access log entry, charging
+// startup only to the request that launched the backend.
+func TestAccessLogBackendLatency(t *testing.T) {
+	testhelper.RequireIntegration(t)
+	f := testhelper.MustFixtures(t)
+
+	tmpDir := t.TempDir()
+	logPath := filepath.Join(tmpDir, "access.log")
+	setup, dispose := testhelper.StartReverseProxy(t, `log {
+		output file {{LOG}}
+		format json
+	}
+	handle /latency/* {
+		reverse-bin {
+			exec {{GO_ECHO}}
+			reverse_proxy_to unix/{{APP_SOCKET}}
+			env SOCKET_PATH={{APP_SOCKET}}
+			access_log_backend_latency on
+		}
+	}`, map[string]string{
+		"LOG":        logPath,
+		"GO_ECHO":    f.GoEchoBin,
+		"APP_SOCKET": filepath.Join(tmpDir, "app.sock"),
+	})
+	defer dispose()
+
+	client := testhelper.NewHTTPClient()
+	// HTTP request launches the backend, so its entry carries the startup time.
+	_, _ = testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/latency/first", setup.Port), 200, "echo-backend", "first request must start the backend")
+	// HTTP request reuses the running backend, so it has no startup time.
+	_, _ = testhelper.AssertGetResponse(t, client, fmt.Sprintf("http://localhost:%d/latency/second", setup.Port), 200, "echo-backend", "second request must reuse the backend")
+	dispose()
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("failed to read access log: %v", err)
+	}
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("failed to parse access log line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 access log entries, got %d: %s", len(entries), data)
+	}
+	for i, entry := range entries {
+		if _, ok := entry["reverse_bin_backend_latency_ms"].(float64); !ok {
+			t.Fatalf("entry %d missing reverse_bin_backend_latency_ms: %v", i, entry)
+		}
+	}
+	if startup, _ := entries[0]["reverse_bin_startup_latency_ms"].(float64); startup <= 0 {
+		t.Fatalf("first entry must report startup latency, got %v", entries[0]["reverse_bin_startup_latency_ms"])
+	}
+	if startup, ok := entries[1]["reverse_bin_startup_latency_ms"].(float64); !ok || startup != 0 {
+		t.Fatalf("second entry must report zero startup latency, got %v", entries[1]["reverse_bin_startup_latency_ms"])
+	}
+}
+
+// TestResponseHeaderRewriting verifies response_header_* directives rewrite
+// backend response headers before they reach the client.
+func TestResponseHeaderRewriting(t *testing.T) {
+	testhelper.RequireIntegration(t)
+	f := testhelper.MustFixtures(t)
+
+	tmpDir := t.TempDir()
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /headers/* {
+		reverse-bin {
+			exec {{GO_ECHO}}
+			reverse_proxy_to unix/{{APP_SOCKET}}
+			env SOCKET_PATH={{APP_SOCKET}} "ECHO_RESPONSE_HEADER=X-Powered-By: go-echo"
+			response_header_delete X-Powered-By
+			response_header_set Content-Type text/plain
+			response_header_add X-Served-By reverse-bin
+		}
+	}`, map[string]string{
+		"GO_ECHO":    f.GoEchoBin,
+		"APP_SOCKET": filepath.Join(tmpDir, "app.sock"),
+	})
+	defer dispose()
+
+	// HTTP request returns the backend response after header rewriting.
+	resp, _ := testhelper.AssertGetResponse(t, testhelper.NewHTTPClient(), fmt.Sprintf("http://localhost:%d/headers/x", setup.Port), 200, "echo-backend", "response header rewrite request must reach backend")
+	if got := resp.Header.Get("X-Powered-By"); got != "" {
+		t.Fatalf("X-Powered-By = %q, want deleted", got)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/plain" {
+		t.Fatalf("Content-Type = %q, want text/plain", got)
+	}
+	if got := resp.Header.Get("X-Served-By"); got != "reverse-bin" {
+		t.Fatalf("X-Served-By = %q, want reverse-bin", got)
+	}
+}
+
+// TestUpstreamHeaderInjection verifies upstream_header_add sets headers,
+// with placeholders expanded, on requests forwarded to the backend.
+func TestUpstreamHeaderInjection(t *testing.T) {
+	testhelper.RequireIntegration(t)
+	f := testhelper.MustFixtures(t)
+
+	tmpDir := t.TempDir()
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /upstream-headers/* {
+		reverse-bin {
+			exec {{GO_ECHO}}
+			reverse_proxy_to unix/{{APP_SOCKET}}
+			env SOCKET_PATH={{APP_SOCKET}}
+			upstream_header_add X-Backend-Source reverse-bin
+			upstream_header_add X-Original-Path {http.request.uri.path}
+		}
+	}`, map[string]string{
+		"GO_ECHO":    f.GoEchoBin,
+		"APP_SOCKET": filepath.Join(tmpDir, "app.sock"),
+	})
+	defer dispose()
+
+	// HTTP request is echoed back by the backend with the headers it received.
+	_, body := testhelper.AssertGetResponse(t, testhelper.NewHTTPClient(), fmt.Sprintf("http://localhost:%d/upstream-headers/x", setup.Port), 200, "echo-backend", "upstream header request must reach backend")
+	var payload struct {
+		Headers http.Header `json:"headers"`
+	}
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		t.Fatalf("failed to parse response JSON %q: %v", body, err)
+	}
+	if got := payload.Headers.Get("X-Backend-Source"); got != "reverse-bin" {
+		t.Fatalf("backend saw X-Backend-Source = %q, want reverse-bin", got)
+	}
+	if got := payload.Headers.Get("X-Original-Path"); got != "/upstream-headers/x" {
+		t.Fatalf("backend saw X-Original-Path = %q, want /upstream-headers/x", got)
+	}
+}
+
+// TestStartupCommandFailureBlocksLaunch verifies a failing startup_command
+// returns 503 without starting the backend.
+func TestStartupCommandFailureBlocksLaunch(t *testing.T) {
+	testhelper.RequireIntegration(t)
+	f := testhelper.MustFixtures(t)
+
+	tmpDir := t.TempDir()
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /startup/* {
+		reverse-bin {
+			exec {{GO_ECHO}}
+			reverse_proxy_to unix/{{APP_SOCKET}}
+			env SOCKET_PATH={{APP_SOCKET}}
+			startup_command sh -c "exit 1"
+		}
+	}`, map[string]string{
+		"GO_ECHO":    f.GoEchoBin,
+		"APP_SOCKET": filepath.Join(tmpDir, "app.sock"),
+	})
+	defer dispose()
+
+	// HTTP request triggers the startup command, which fails before launch.
+	_, _ = testhelper.AssertGetResponse(t, testhelper.NewHTTPClient(), fmt.Sprintf("http://localhost:%d/startup/x", setup.Port), 503, "", "failing startup_command must return 503")
+	if _, err := os.Stat(filepath.Join(tmpDir, "app.sock")); !os.IsNotExist(err) {
+		t.Fatalf("backend socket exists after failed startup_command (stat err %v)", err)
+	}
+}
+
+// TestShutdownCommandRunsBeforeIdleStop verifies shutdown_command runs on
+// idle timeout while the backend is still up.
+func TestShutdownCommandRunsBeforeIdleStop(t *testing.T) {
+	testhelper.RequireIntegration(t)
+	f := testhelper.MustFixtures(t)
+
+	tmpDir := t.TempDir()
+	marker := filepath.Join(tmpDir, "shutdown.log")
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /shutdown/* {
+		reverse-bin {
+			exec {{GO_ECHO}}
+			reverse_proxy_to unix/{{APP_SOCKET}}
+			env SOCKET_PATH={{APP_SOCKET}}
+			idle_timeout_ms 100
+			shutdown_command sh -c "test -S $SOCKET_PATH && echo backend-up > {{MARKER}}"
+			shutdown_command_timeout_ms 2000
+		}
+	}`, map[string]string{
+		"GO_ECHO":    f.GoEchoBin,
+		"APP_SOCKET": filepath.Join(tmpDir, "app.sock"),
+		"MARKER":     marker,
+	})
+	defer dispose()
+
+	// HTTP request starts the backend so the idle timer can stop it.
+	_, _ = testhelper.AssertGetResponse(t, testhelper.NewHTTPClient(), fmt.Sprintf("http://localhost:%d/shutdown/x", setup.Port), 200, "echo-backend", "request must start backend")
+
+	// Wait without traffic so the idle timeout fires and the backend stops.
+	time.Sleep(500 * time.Millisecond)
+
+	data, err := os.ReadFile(marker)
+	if err != nil || string(data) != "backend-up\n" {
+		t.Fatalf("expected shutdown_command to see the backend socket, got %q (%v)", data, err)
+	}
+}
+
+// Invariant: when the primary socket refuses connections, requests fall back to
+// reverse_proxy_to_secondary and the backend is not restarted.
+func TestReverseProxyToSecondaryOnRefusedPrimary(t *testing.T) {
+	testhelper.RequireIntegration(t)
+	f := testhelper.MustFixtures(t)
+
+	tmpDir := t.TempDir()
+	primary := filepath.Join(tmpDir, "primary.sock")
+	secondary := filepath.Join(tmpDir, "secondary.sock")
+	// The backend serves the secondary, then leaves a dead primary socket
+	// behind (SIGKILL skips the unlink) so dialing it is refused.
+	script := testhelper.WriteScript(t, tmpDir, "ha-pair.sh", fmt.Sprintf(`#!/bin/sh
+ECHO_RESPONSE_HEADER="X-Echo-Role: secondary" SOCKET_PATH=%[2]s %[1]s &
+SOCKET_PATH=%[3]s.tmp %[1]s &
+primary=$!
+until [ -S %[2]s ] && [ -S %[3]s.tmp ]; do sleep 0.05; done
+kill -9 $primary
+mv %[3]s.tmp %[3]s
+wait
+`, f.GoEchoBin, secondary, primary))
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /ha/* {
+		reverse-bin {
+			exec {{SCRIPT}}
+			reverse_proxy_to unix/{{PRIMARY}}
+			reverse_proxy_to_secondary unix/{{SECONDARY}}
+		}
+	}`, map[string]string{
+		"SCRIPT":    script,
+		"PRIMARY":   primary,
+		"SECONDARY": secondary,
+	})
+	defer dispose()
+
+	client := testhelper.NewHTTPClient()
+	requestURI := fmt.Sprintf("http://localhost:%d/ha/pid", setup.Port)
+	// HTTP request starts the backend; the refused primary dial is retried on the secondary.
+	resp, first := testhelper.AssertGetResponse(t, client, requestURI, 200, "pid", "request must be served by the secondary")
+	if got := resp.Header.Get("X-Echo-Role"); got != "secondary" {
+		t.Fatalf("expected X-Echo-Role secondary, got %q", got)
+	}
+	// HTTP request reuses the same backend instead of respawning it.
+	_, second := testhelper.AssertGetResponse(t, client, requestURI, 200, "pid", "second request must reuse the secondary")
+	if first != second {
+		t.Fatalf("expected the same secondary pid, got %s then %s", first, second)
+	}
+}
+
+// Invariant: retry_on_failure replaces a backend answering 503 and retries the
+// request on the fresh process.
+func TestRetryOnFailureRetriesOnFreshBackend(t *testing.T) {
+	testhelper.RequireIntegration(t)
+	f := testhelper.MustFixtures(t)
+
+	tmpDir := t.TempDir()
+	// The first launch answers /health with 503; later launches are healthy.
+	script := testhelper.WriteScript(t, tmpDir, "flaky.sh", fmt.Sprintf(`#!/bin/sh
+if [ -e %[2]s ]; then exec %[1]s; fi
+touch %[2]s
+HEALTH_STATUS=503 exec %[1]s
+`, f.GoEchoBin, filepath.Join(tmpDir, "launched")))
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /retry/* {
+		uri strip_prefix /retry
+		reverse-bin {
+			exec {{SCRIPT}}
+			reverse_proxy_to unix/{{APP_SOCKET}}
+			env SOCKET_PATH={{APP_SOCKET}}
+			retry_on_failure 1
+		}
+	}`, map[string]string{
+		"SCRIPT":     script,
+		"APP_SOCKET": filepath.Join(tmpDir, "app.sock"),
+	})
+	defer dispose()
+
+	// HTTP request hits the failing first backend and is retried on its replacement.
+	_, _ = testhelper.AssertGetResponse(t, testhelper.NewHTTPClient(), fmt.Sprintf("http://localhost:%d/retry/health", setup.Port), 200, "healthy", "retry must reach a fresh backend")
+}
+
+// Invariant: a socket file left behind by a crashed session is removed before
+// the backend launches, so the backend can bind its path.
+func TestStaleSocketRemovedBeforeLaunch(t *testing.T) {
+	testhelper.RequireIntegration(t)
+	f := testhelper.MustFixtures(t)
+
+	tmpDir := t.TempDir()
+	socketPath := filepath.Join(tmpDir, "app.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = ln.Close()
+
+	// Unlike go-echo, this backend refuses to start over an existing file.
+	script := testhelper.WriteScript(t, tmpDir, "strict.sh", fmt.Sprintf(`#!/bin/sh
+if [ -e "$SOCKET_PATH" ]; then echo "stale socket present" >&2; exit 1; fi
+exec %s
+`, f.GoEchoBin))
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /stale/* {
+		reverse-bin {
+			exec {{SCRIPT}}
+			reverse_proxy_to unix/{{APP_SOCKET}}
+			env SOCKET_PATH={{APP_SOCKET}}
+		}
+	}`, map[string]string{
+		"SCRIPT":     script,
+		"APP_SOCKET": socketPath,
+	})
+	defer dispose()
+
+	// HTTP request starts the backend over the stale socket path.
+	_, _ = testhelper.AssertGetResponse(t, testhelper.NewHTTPClient(), fmt.Sprintf("http://localhost:%d/stale/x", setup.Port), 200, "echo-backend", "backend must start after stale socket cleanup")
+}
+
+// TestPortDiscoveryProxiesToReportedPort verifies the upstream comes from the port a backend prints on stdout.
+func TestPortDiscoveryProxiesToReportedPort(t *testing.T) {
+	testhelper.RequireIntegration(t)
+	f := testhelper.MustFixtures(t)
+
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /discovered/* {
+		reverse-bin {
+			exec {{GO_ECHO}}
+			env REVERSE_BIN_PORT=0
+			port_discovery_pattern "Listening on 127\.0\.0\.1:(\d+)"
+		}
+	}`, map[string]string{
+		"GO_ECHO": f.GoEchoBin,
+	})
+	defer dispose()
+
+	// HTTP request verifies the backend listening on a kernel-chosen port is reached through the port it reported.
+	_, _ = testhelper.AssertGetResponse(t, testhelper.NewHTTPClient(), fmt.Sprintf("http://localhost:%d/discovered/ok", setup.Port), 200, "echo-backend", "backend must be proxied at its discovered port")
+}
+
+// Invariant: changing a watch_file restarts the backend, so the next request
+// is served by a new process.
+func TestWatchFileRestartsBackend(t *testing.T) {
+	testhelper.RequireIntegration(t)
+	f := testhelper.MustFixtures(t)
+
+	tmpDir := t.TempDir()
+	watched := filepath.Join(tmpDir, "config.json")
+	if err := os.WriteFile(watched, []byte("{}"), 0o644); err != nil {
+		t.Fatalf("write watched file: %v", err)
+	}
+	setup, dispose := testhelper.StartReverseProxy(t, `handle /watched/* {
+		uri strip_prefix /watched
+		reverse-bin {
+			exec {{GO_ECHO}}
+			reverse_proxy_to unix/{{APP_SOCKET}}
+			env SOCKET_PATH={{APP_SOCKET}}
+			watch_file {{WATCHED}}
+		}
+	}`, map[string]string{
+		"GO_ECHO":    f.GoEchoBin,
+		"APP_SOCKET": filepath.Join(tmpDir, "app.sock"),
+		"WATCHED":    watched,
+	})
+	defer dispose()
+
+	client := testhelper.NewHTTPClient()
+	requestURI := fmt.Sprintf("http://localhost:%d/watched/pid", setup.Port)
+	// HTTP request starts the first backend.
+	_, first := testhelper.AssertGetResponse(t, client, requestURI, 200, "pid", "request must start the backend")
+
+	if err := os.WriteFile(watched, []byte(`{"changed":true}`), 0o644); err != nil {
+		t.Fatalf("rewrite watched file: %v", err)
+	}
+	// Wait out the debounce and the idle backend's restart.
+	time.Sleep(500 * time.Millisecond)
+
+	// HTTP request must be served by a backend launched after the change.
+	_, second := testhelper.AssertGetResponse(t, client, requestURI, 200, "pid", "request after the change must reach a fresh backend")
+	if first == second {
+		t.Fatalf("expected a new backend pid after watch_file changed, got %s twice", first)
+	}
+}
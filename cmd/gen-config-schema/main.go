@@ -0,0 +1,83 @@
+// Command gen-config-schema writes the JSON Schema for the reverse-bin
+// handler's JSON configuration to stdout. Run it from the repository root so
+// field descriptions can be read from the ReverseBin doc comments.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	jsonschema "github.com/invopop/jsonschema"
+	reversebin "github.com/tarasglek/caddy-reverse-bin"
+)
+
+// oneOfPattern picks the valid values out of field comments such as
+// "Protocol spoken to the backend, one of: http, scgi, fastcgi".
+var oneOfPattern = regexp.MustCompile(`one of: ([\w-]+(?:, [\w-]+)*)`)
+
+func main() {
+	reflector := jsonschema.Reflector{
+		BaseSchemaID:               jsonschema.ID("https://github.com/tarasglek/caddy-reverse-bin/schemas/"),
+		ExpandedStruct:             true,
+		RequiredFromJSONSchemaTags: true,
+		AllowAdditionalProperties:  false,
+	}
+	if err := reflector.AddGoComments("github.com/tarasglek/caddy-reverse-bin", "./"); err != nil {
+		fail("read doc comments: %v", err)
+	}
+
+	schema := reflector.Reflect(&reversebin.ReverseBin{})
+	schema.ID = "https://github.com/tarasglek/caddy-reverse-bin/schemas/reverse-bin-config"
+	schema.Title = "reverse-bin handler"
+
+	for pair := schema.Properties.Oldest(); pair != nil; pair = pair.Next() {
+		m := oneOfPattern.FindStringSubmatch(pair.Value.Description)
+		if m == nil {
+			continue
+		}
+		target := pair.Value
+		if target.Type == "array" {
+			target = target.Items
+		}
+		for _, v := range strings.Split(m[1], ", ") {
+			target.Enum = append(target.Enum, v)
+		}
+	}
+
+	// In a Caddy JSON config the handler is selected by this property.
+	schema.Properties.Set("handler", &jsonschema.Schema{Type: "string", Const: "reverse-bin"})
+	schema.Properties.MoveToFront("handler")
+	schema.Required = []string{"handler"}
+	// Mirrors Provision: either a static command and upstream, or a detector.
+	schema.AnyOf = []*jsonschema.Schema{
+		{Required: []string{"executable", "reverse_proxy_to"}},
+		{Required: []string{"executable", "socketTemplate"}},
+		{Required: []string{"executable", "portDiscoveryPattern"}},
+		{Required: []string{"executable", "startupAddressPattern"}},
+		{Required: []string{"executable", "socketActivation"}},
+		stdioModeSchema(),
+		{Required: []string{"dynamic_proxy_detector"}},
+		{Required: []string{"dynamic_proxy_detector_http"}},
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(schema); err != nil {
+		fail("encode config schema: %v", err)
+	}
+}
+
+// stdioModeSchema matches a stdio_mode config, which needs no upstream.
+func stdioModeSchema() *jsonschema.Schema {
+	props := jsonschema.NewProperties()
+	props.Set("stdioMode", &jsonschema.Schema{Const: true})
+	return &jsonschema.Schema{Required: []string{"executable", "stdioMode"}, Properties: props}
+}
+
+func fail(format string, args ...any) {
+	_, _ = fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -18,6 +18,11 @@ func main() {
 	}
 
 	schema := reflector.Reflect(&detectorschema.DetectorOutput{})
+	// Mirrors reverse-bin's runtime check: output must say what to run or where to proxy.
+	schema.AnyOf = []*jsonschema.Schema{
+		{Required: []string{"executable"}},
+		{Required: []string{"reverse_proxy_to"}},
+	}
 
 	encoder := json.NewEncoder(os.Stdout)
 	encoder.SetIndent("", "  ")
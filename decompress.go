@@ -0,0 +1,72 @@
+package reversebin
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// decompressingTransport decodes gzip and brotli backend responses so the
+// client receives the plain representation. The decoded length is unknown
+// until the body is read; combine with response_buffer_size to send a
+// Content-Length, or with Caddy's encode handler to re-compress for clients
+// that accept it.
+type decompressingTransport struct {
+	next http.RoundTripper
+}
+
+func (t *decompressingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || !shouldDecompressResponse(req, resp) {
+		return resp, err
+	}
+
+	var decoded io.Reader
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			_ = resp.Body.Close()
+			return nil, fmt.Errorf("decompressing backend response: %w", err)
+		}
+		decoded = zr
+	case "br":
+		decoded = brotli.NewReader(resp.Body)
+	default:
+		return resp, nil
+	}
+
+	resp.Body = &decodedBody{Reader: decoded, Closer: resp.Body}
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	// The entity tag described the encoded bytes.
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		resp.Header.Set("ETag", "W/"+etag)
+	}
+	return resp, nil
+}
+
+// shouldDecompressResponse skips responses without a body and partial
+// content, whose byte ranges refer to the encoded representation.
+func shouldDecompressResponse(req *http.Request, resp *http.Response) bool {
+	if req.Method == http.MethodHead || resp.Header.Get("Content-Encoding") == "" {
+		return false
+	}
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+	return resp.StatusCode >= 200
+}
+
+// decodedBody reads through a decoder and closes the backend body.
+type decodedBody struct {
+	io.Reader
+	io.Closer
+}
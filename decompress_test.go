@@ -0,0 +1,56 @@
+package reversebin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// TestDecompressingTransportDecodesResponses verifies gzip and brotli bodies are decoded and, with buffering, sent with the decoded Content-Length.
+func TestDecompressingTransportDecodesResponses(t *testing.T) {
+	const payload = "hello, decompressed world"
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// This HTTP request tests a backend that compresses regardless of Accept-Encoding.
+		var buf bytes.Buffer
+		encoding := r.URL.Query().Get("enc")
+		var zw io.WriteCloser = gzip.NewWriter(&buf)
+		if encoding == "br" {
+			zw = brotli.NewWriter(&buf)
+		}
+		_, _ = io.WriteString(zw, payload)
+		_ = zw.Close()
+		w.Header().Set("Content-Encoding", encoding)
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write(buf.Bytes())
+	}))
+	defer backend.Close()
+
+	rt := &bufferingTransport{
+		next:  &decompressingTransport{next: &http.Transport{DisableCompression: true}},
+		limit: 1024,
+	}
+	for _, encoding := range []string{"gzip", "br"} {
+		req := httptest.NewRequest(http.MethodGet, backend.URL+"/?enc="+encoding, nil)
+		req.RequestURI = ""
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("%s: RoundTrip: %v", encoding, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != payload {
+			t.Fatalf("%s: body = %q, want %q", encoding, body, payload)
+		}
+		if resp.Header.Get("Content-Encoding") != "" || resp.Header.Get("Content-Length") != "25" {
+			t.Fatalf("%s: expected no Content-Encoding and Content-Length 25, got %q / %q", encoding, resp.Header.Get("Content-Encoding"), resp.Header.Get("Content-Length"))
+		}
+		if etag := resp.Header.Get("ETag"); etag != `W/"v1"` {
+			t.Fatalf("%s: expected weakened ETag W/\"v1\", got %q", encoding, etag)
+		}
+	}
+}
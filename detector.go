@@ -1,14 +1,320 @@
 package reversebin
 
-import "github.com/tarasglek/caddy-reverse-bin/detectorschema"
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tarasglek/caddy-reverse-bin/detectorschema"
+	"go.uber.org/zap"
+)
 
 // DetectorOutput is the JSON object a dynamic proxy detector writes to stdout.
 type DetectorOutput = detectorschema.DetectorOutput
 
 func parseDetectorOutput(data []byte) (*DetectorOutput, error) {
-	return detectorschema.Parse(data)
+	output, err := detectorschema.Parse(data)
+	if err != nil {
+		return nil, describeDetectorError(data, err)
+	}
+	if err := requireDetectorTarget(*output); err != nil {
+		return nil, fmt.Errorf("invalid detector output: %w", err)
+	}
+	return output, nil
 }
 
 func validateDetectorOutput(output DetectorOutput) error {
-	return detectorschema.Validate(output)
+	if err := detectorschema.Validate(output); err != nil {
+		return err
+	}
+	return requireDetectorTarget(output)
+}
+
+// requireDetectorTarget rejects output that overrides neither what to run
+// nor where to proxy, which is almost always a detector bug.
+func requireDetectorTarget(output DetectorOutput) error {
+	if output.Executable == nil && output.ReverseProxyTo == nil {
+		return fmt.Errorf("at least one of executable or reverse_proxy_to is required")
+	}
+	return nil
+}
+
+// describeDetectorError rewrites encoding/json type and unknown field errors
+// from detectorschema.Parse to name the offending field and quote the value
+// the detector actually sent. Other errors are returned unchanged.
+func describeDetectorError(data []byte, err error) error {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		return err
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		name, _, _ := strings.Cut(typeErr.Field, ".")
+		if raw, ok := fields[name]; ok {
+			return fmt.Errorf("invalid detector output: %s must be %s, got %s", name, detectorTypeName(name), compactJSON(raw))
+		}
+	}
+
+	// DisallowUnknownFields has no typed error, only this message.
+	if _, after, ok := strings.Cut(err.Error(), "json: unknown field "); ok {
+		name := strings.Trim(after, `"`)
+		if raw, ok := fields[name]; ok {
+			return fmt.Errorf("invalid detector output: unknown field %q with value %s", name, compactJSON(raw))
+		}
+	}
+	return err
+}
+
+func detectorTypeName(field string) string {
+	switch field {
+	case "executable", "envs":
+		return "an array of strings"
+	case "health_status":
+		return "an integer"
+	}
+	return "a string"
+}
+
+func compactJSON(raw json.RawMessage) string {
+	var buf bytes.Buffer
+	if json.Compact(&buf, raw) != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+// builtinDotenvDetector names the in-process detector that reads an app's .env.
+const builtinDotenvDetector = "builtin:dotenv"
+
+func isBuiltinDetector(args []string) bool {
+	return len(args) > 0 && strings.HasPrefix(args[0], "builtin:")
+}
+
+func validateBuiltinDetector(args []string) error {
+	if args[0] != builtinDotenvDetector {
+		return fmt.Errorf("unknown built-in detector %s", args[0])
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("%s requires exactly one app directory", builtinDotenvDetector)
+	}
+	return nil
+}
+
+// dotenvDetector builds detector output from <appdir>/.env without spawning
+// a process. EXEC is split on whitespace and REVERSE_PROXY_TO is used as is;
+// the app directory becomes the working directory. key is the
+// placeholder-expanded "builtin:dotenv <appdir>" pair.
+func dotenvDetector(key string) (*DetectorOutput, error) {
+	_, appDir, _ := strings.Cut(key, " ")
+	entries, err := readEnvFile(filepath.Join(appDir, ".env"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", builtinDotenvDetector, err)
+	}
+
+	output := &DetectorOutput{WorkingDirectory: &appDir}
+	for _, entry := range entries {
+		name, val, _ := strings.Cut(entry, "=")
+		switch name {
+		case "EXEC":
+			args := strings.Fields(val)
+			output.Executable = &args
+		case "REVERSE_PROXY_TO":
+			output.ReverseProxyTo = &val
+		}
+	}
+	if err := validateDetectorOutput(*output); err != nil {
+		return nil, fmt.Errorf("%s %s: %v", builtinDotenvDetector, appDir, err)
+	}
+	return output, nil
+}
+
+// fetchHTTPDetector asks an HTTP endpoint for detector output. key is the
+// placeholder-expanded "METHOD URL" pair from dynamic_proxy_detector_http.
+func (c *ReverseBin) fetchHTTPDetector(ctx context.Context, key string) (*DetectorOutput, error) {
+	method, target, ok := strings.Cut(key, " ")
+	if !ok || target == "" {
+		return nil, fmt.Errorf("dynamic proxy detector URL is empty")
+	}
+
+	c.logger.Debug("fetching dynamic proxy detector",
+		zap.String("method", method),
+		zap.String("url", target))
+
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("dynamic proxy detector request: %v", err)
+	}
+	if c.DetectorHTTPUser != "" || c.DetectorHTTPPassword != "" {
+		req.SetBasicAuth(c.DetectorHTTPUser, c.DetectorHTTPPassword)
+	}
+
+	client := &http.Client{Timeout: time.Duration(c.DetectorHTTPTimeoutMS) * time.Millisecond}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("dynamic proxy detector failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("dynamic proxy detector failed: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("dynamic proxy detector failed: status %d\nOutput: %s", resp.StatusCode, body)
+	}
+	output, err := parseDetectorOutput(body)
+	if err != nil {
+		return nil, fmt.Errorf("%v\nOutput: %s", err, body)
+	}
+	return output, nil
+}
+
+// streamDetector is a dynamic_proxy_detector left running in detector_mode
+// stream. It writes one JSON object per line to stdout; the most recent valid
+// line configures the next backend launch.
+type streamDetector struct {
+	ready     chan struct{} // closed on the first valid line or on exit
+	readyOnce sync.Once
+
+	mu     sync.Mutex
+	latest *DetectorOutput
+	err    error
+}
+
+func (sd *streamDetector) markReady() {
+	sd.readyOnce.Do(func() { close(sd.ready) })
+}
+
+// streamDetectorOutput returns the latest output of the stream detector for
+// key, starting it if it is not running. Only the first request after a start
+// waits for output, for up to the health timeout.
+func (c *ReverseBin) streamDetectorOutput(ctx context.Context, key string) (*DetectorOutput, error) {
+	c.mu.Lock()
+	sd := c.streamDetectors[key]
+	if sd == nil {
+		var err error
+		sd, err = c.startStreamDetector(key)
+		if err != nil {
+			c.mu.Unlock()
+			return nil, err
+		}
+		if c.streamDetectors == nil {
+			c.streamDetectors = make(map[string]*streamDetector)
+		}
+		c.streamDetectors[key] = sd
+	}
+	c.mu.Unlock()
+
+	timer := time.NewTimer(c.healthTimeout())
+	defer timer.Stop()
+	select {
+	case <-sd.ready:
+	case <-timer.C:
+		return nil, fmt.Errorf("dynamic proxy detector timed out")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	if sd.latest == nil {
+		return nil, sd.err
+	}
+	return sd.latest, nil
+}
+
+// startStreamDetector launches the detector command in key and reads its
+// output in the background. It runs until it exits or the module is unloaded.
+func (c *ReverseBin) startStreamDetector(key string) (*streamDetector, error) {
+	args := strings.Split(key, " ")
+	if len(args) == 0 || args[0] == "" {
+		return nil, fmt.Errorf("dynamic proxy detector command is empty")
+	}
+
+	c.logger.Debug("starting streaming dynamic proxy detector",
+		zap.String("command", args[0]),
+		zap.Strings("args", args[1:]))
+
+	cmd := exec.CommandContext(c.moduleContext(), args[0], args[1:]...)
+	configureDetectorProcAttrs(cmd)
+	cmd.Env = c.detectorEnv()
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("dynamic proxy detector failed: %v", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("dynamic proxy detector failed: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("dynamic proxy detector failed: %v", err)
+	}
+
+	sd := &streamDetector{ready: make(chan struct{})}
+	go c.readStreamDetector(key, sd, cmd, stdout, stderr)
+	return sd, nil
+}
+
+func (c *ReverseBin) readStreamDetector(key string, sd *streamDetector, cmd *exec.Cmd, stdout, stderr io.Reader) {
+	var stderrDone sync.WaitGroup
+	stderrDone.Add(1)
+	go func() {
+		defer stderrDone.Done()
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			c.logger.Info("dynamic proxy detector stderr", zap.String("stderr", scanner.Text()))
+		}
+	}()
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		output, err := parseDetectorOutput(line)
+		if err != nil {
+			c.logger.Warn("ignoring invalid dynamic proxy detector line",
+				zap.Error(err),
+				zap.ByteString("line", line))
+			continue
+		}
+		sd.mu.Lock()
+		sd.latest = output
+		sd.mu.Unlock()
+		sd.markReady()
+	}
+	scanErr := scanner.Err()
+	_, _ = io.Copy(io.Discard, stdout)
+	stderrDone.Wait()
+	err := cmd.Wait()
+	if err == nil {
+		err = scanErr
+	}
+
+	c.mu.Lock()
+	if c.streamDetectors[key] == sd {
+		delete(c.streamDetectors, key)
+	}
+	c.mu.Unlock()
+
+	c.logger.Info("streaming dynamic proxy detector exited", zap.Error(err))
+	sd.mu.Lock()
+	if err != nil {
+		sd.err = fmt.Errorf("dynamic proxy detector exited: %v", err)
+	} else {
+		sd.err = fmt.Errorf("dynamic proxy detector exited without output")
+	}
+	sd.mu.Unlock()
+	sd.markReady()
 }
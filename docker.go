@@ -0,0 +1,69 @@
+package reversebin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// containerSocketDir is where the directory holding the reverse_proxy_to
+// socket is mounted inside docker_image containers.
+const containerSocketDir = "/sockets"
+
+// provisionDocker turns docker_image into the docker run command line the
+// supervisor launches in place of exec. The container is named after the
+// socket so docker stop can reach it when the backend is stopped.
+func (c *ReverseBin) provisionDocker() error {
+	if c.DockerImage == "" {
+		return nil
+	}
+	if len(c.Executable) > 0 {
+		return fmt.Errorf("docker_image and exec are mutually exclusive")
+	}
+	if c.hasDetector() || c.StdioMode || c.SocketTemplate != "" || len(c.Routes) > 0 || c.SocketActivation != nil || c.PortDiscoveryPattern != "" || c.StartupAddressPattern != "" {
+		return fmt.Errorf("docker_image cannot be combined with stdio_mode, socket_template, route, socket_activation, port_discovery_pattern, startup_address_pattern or a detector")
+	}
+	if !isUnixUpstream(c.ReverseProxyTo) || c.SocketType == socketTypeAbstract {
+		return fmt.Errorf("docker_image requires a unix/ reverse_proxy_to socket path")
+	}
+	socketPath, err := filepath.Abs(strings.TrimPrefix(c.ReverseProxyTo, "unix/"))
+	if err != nil {
+		return fmt.Errorf("docker_image: %v", err)
+	}
+	sum := sha256.Sum256([]byte(socketPath))
+	c.containerName = "reverse-bin-" + hex.EncodeToString(sum[:6])
+
+	args := []string{"docker", "run", "--rm", "--name", c.containerName,
+		"-v", filepath.Dir(socketPath) + ":" + containerSocketDir}
+	for _, env := range c.Envs {
+		key, _, _ := strings.Cut(env, "=")
+		// Without a value, docker copies the variable from its own
+		// environment, where reverse-bin has already put it.
+		args = append(args, "-e", key)
+	}
+	c.Executable = append(args, c.DockerImage)
+	return nil
+}
+
+// stopContainer runs docker stop for a docker_image backend, giving the
+// container grace to exit before docker kills it. Signals sent to the docker
+// CLI afterwards only reach the container if this fails.
+func (c *ReverseBin) stopContainer(rb *runningBackend, grace time.Duration) {
+	secs := strconv.Itoa(int(math.Ceil(grace.Seconds())))
+	ctx, cancel := context.WithTimeout(c.moduleContext(), grace+c.terminationKillWait())
+	defer cancel()
+	if err := c.runHookCommand(ctx, "docker stop", []string{"docker", "stop", "--time", secs, c.containerName}, rb.config); err != nil {
+		c.logger.Warn("docker stop did not complete",
+			zap.String("container", c.containerName),
+			zap.Int("pid", rb.process.Pid()),
+			zap.Error(err))
+	}
+}
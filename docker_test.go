@@ -0,0 +1,63 @@
+package reversebin
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// TestProvisionDockerBuildsRunCommand verifies docker_image becomes a named docker run that mounts the socket directory and forwards env keys.
+func TestProvisionDockerBuildsRunCommand(t *testing.T) {
+	c := &ReverseBin{
+		DockerImage:    "my-app:latest",
+		ReverseProxyTo: "unix//run/apps/app.sock",
+		Envs:           []string{"DATABASE_URL=postgres://db"},
+	}
+	if err := c.provisionDocker(); err != nil {
+		t.Fatalf("provision: %v", err)
+	}
+	if !strings.HasPrefix(c.containerName, "reverse-bin-") {
+		t.Fatalf("unexpected container name %q", c.containerName)
+	}
+	want := []string{"docker", "run", "--rm", "--name", c.containerName,
+		"-v", "/run/apps:/sockets", "-e", "DATABASE_URL", "my-app:latest"}
+	if !slices.Equal(c.Executable, want) {
+		t.Fatalf("executable = %q, want %q", c.Executable, want)
+	}
+
+	// exec and docker_image are alternatives.
+	c = &ReverseBin{DockerImage: "my-app:latest", Executable: []string{"./app"}, ReverseProxyTo: "unix//run/apps/app.sock"}
+	if err := c.provisionDocker(); err == nil {
+		t.Fatal("expected docker_image with exec to be rejected")
+	}
+}
+
+// TestStopContainerRunsDockerStop verifies stopping a docker_image backend runs docker stop with the grace period in seconds.
+func TestStopContainerRunsDockerStop(t *testing.T) {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "docker"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	c := &ReverseBin{
+		containerName:         "reverse-bin-abc",
+		TerminationKillWaitMS: 1000,
+		logger:                zaptest.NewLogger(t),
+	}
+	c.stopContainer(&runningBackend{process: &mockProcess{pid: 4242}}, 1500*time.Millisecond)
+	got, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("docker was not run: %v", err)
+	}
+	if string(got) != "stop --time 2 reverse-bin-abc\n" {
+		t.Fatalf("unexpected docker args %q", got)
+	}
+}
@@ -0,0 +1,44 @@
+package reversebin
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// checkCommands makes sure every command this handler may run can be found,
+// for the dry_run global option. Commands whose name is a placeholder are
+// only known per request and are skipped.
+func (c *ReverseBin) checkCommands() error {
+	type command struct {
+		directive string
+		args      []string
+	}
+	commands := []command{
+		{"exec", c.Executable},
+		{"startup_command", c.StartupCommand},
+		{"shutdown_command", c.ShutdownCommand},
+		{"lifecycle_hook", c.LifecycleHook},
+	}
+	if !isBuiltinDetector(c.DynamicProxyDetector) {
+		commands = append(commands, command{"dynamic_proxy_detector", c.DynamicProxyDetector})
+	}
+	for _, rt := range c.Routes {
+		commands = append(commands, command{"route " + rt.name(), rt.Executable})
+	}
+	for _, cmd := range commands {
+		if len(cmd.args) == 0 || hasPlaceholders(cmd.args[:1]) {
+			continue
+		}
+		if err := lookExecutable(cmd.args[0], c.WorkingDirectory); err != nil {
+			return fmt.Errorf("%s: %v", cmd.directive, err)
+		}
+	}
+	return nil
+}
+
+// dryRunError answers requests under dry_run, which never starts a backend.
+func dryRunError() error {
+	return caddyhttp.Error(http.StatusServiceUnavailable, fmt.Errorf("dry_run is set; not starting a backend"))
+}
@@ -0,0 +1,46 @@
+package reversebin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestCheckCommandsReportsMissingExecutables verifies dry_run finds commands that do not exist, resolving relative paths against dir.
+func TestCheckCommandsReportsMissingExecutables(t *testing.T) {
+	c := &ReverseBin{Executable: []string{"sh", "-c", "true"}, StartupCommand: []string{"./migrate"}, WorkingDirectory: t.TempDir()}
+	err := c.checkCommands()
+	if err == nil || !strings.HasPrefix(err.Error(), "startup_command:") {
+		t.Fatalf("expected the missing startup_command to be reported, got %v", err)
+	}
+
+	c.StartupCommand = []string{"{http.request.host}"}
+	if err := c.checkCommands(); err != nil {
+		t.Fatalf("expected placeholder commands to be skipped, got %v", err)
+	}
+}
+
+// TestDryRunNeverLaunches verifies requests under dry_run get 503 without starting a backend.
+func TestDryRunNeverLaunches(t *testing.T) {
+	f := useMockProcesses(t, filepath.Join(t.TempDir(), "app.sock"), http.NotFoundHandler())
+	c := &ReverseBin{
+		app:       &App{DryRun: true},
+		processes: map[string]*processState{},
+		logger:    zaptest.NewLogger(t),
+	}
+	// This HTTP request tests a request arriving while dry_run is set.
+	err := c.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://app.example/", nil), nil)
+	var herr caddyhttp.HandlerError
+	if !errors.As(err, &herr) || herr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %v", err)
+	}
+	if n := f.starts.Load(); n != 0 {
+		t.Fatalf("expected no backend launch, got %d", n)
+	}
+}
@@ -0,0 +1,132 @@
+package reversebin
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// backendEnv builds the backend environment. Later sources override earlier
+// ones, in this order:
+//
+//  1. the parent environment (pass_all_env) or the selected keys (pass_env)
+//  2. env_file entries, file by file in directive order
+//  3. env entries (or the detector's envs), then env_from_header values
+//
+// exec.Cmd keeps the last value for a repeated key, so appending in this order
+// is enough to apply the precedence.
+func (c *ReverseBin) backendEnv(cfg resolvedConfig) ([]string, error) {
+	var env []string
+	if c.PassAll {
+		env = os.Environ()
+	} else {
+		for _, key := range c.PassEnvs {
+			if val, ok := os.LookupEnv(key); ok {
+				env = append(env, key+"="+val)
+			}
+		}
+	}
+	for _, path := range c.EnvFiles {
+		if !filepath.IsAbs(path) && cfg.WorkingDirectory != "" {
+			path = filepath.Join(cfg.WorkingDirectory, path)
+		}
+		entries, err := readEnvFile(path)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, entries...)
+	}
+	return append(env, cfg.Envs...), nil
+}
+
+// detectorEnv builds the detector environment: Caddy's own environment plus
+// detector_env entries, which never reach the backend. A nil result makes
+// exec.Cmd inherit Caddy's environment unchanged.
+func (c *ReverseBin) detectorEnv() []string {
+	if len(c.DetectorEnvs) == 0 {
+		return nil
+	}
+	return append(os.Environ(), c.DetectorEnvs...)
+}
+
+// readEnvFile reads KEY=value lines, skipping blank lines and # comments.
+// Values wrapped in matching single or double quotes are unquoted.
+func readEnvFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("env_file: %w", err)
+	}
+	defer f.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("env_file %s:%d: expected KEY=value", path, lineNo)
+		}
+		val = strings.TrimSpace(val)
+		if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0] {
+			val = val[1 : len(val)-1]
+		}
+		entries = append(entries, key+"="+val)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("env_file %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// EnvFromHeader copies a request header into the backend environment.
+type EnvFromHeader struct {
+	// Request header to read, such as X-Tenant-Id
+	Header string `json:"header"`
+	// Environment variable to set, such as TENANT_ID
+	Env string `json:"env"`
+}
+
+// headerEnvs returns the env_from_header entries for r, in directive order.
+// Missing headers are left out. Line breaks and NUL bytes are dropped from
+// the values so a client cannot smuggle in extra variables.
+func (c *ReverseBin) headerEnvs(r *http.Request) []string {
+	var envs []string
+	for _, h := range c.EnvFromHeaders {
+		vals := r.Header.Values(h.Header)
+		if len(vals) == 0 {
+			continue
+		}
+		envs = append(envs, h.Env+"="+sanitizeEnvValue(strings.Join(vals, ",")))
+	}
+	return envs
+}
+
+func sanitizeEnvValue(val string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\r', '\n', 0:
+			return -1
+		}
+		return r
+	}, val)
+}
+
+// duplicateEnvKey returns the first key assigned more than once in envs.
+func duplicateEnvKey(envs []string) (string, bool) {
+	seen := make(map[string]struct{}, len(envs))
+	for _, env := range envs {
+		key, _, _ := strings.Cut(env, "=")
+		if _, ok := seen[key]; ok {
+			return key, true
+		}
+		seen[key] = struct{}{}
+	}
+	return "", false
+}
@@ -0,0 +1,89 @@
+package reversebin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap"
+)
+
+// error_body_format values.
+const (
+	errorBodyJSON = "json"
+	errorBodyHTML = "html"
+)
+
+// errorBodyWriter notes whether a response was started, after which an
+// error can no longer be turned into an error body.
+type errorBodyWriter struct {
+	*caddyhttp.ResponseWriterWrapper
+	wroteHeader bool
+}
+
+func (w *errorBodyWriter) WriteHeader(status int) {
+	// 1xx responses are followed by the real one.
+	if status >= 200 {
+		w.wroteHeader = true
+	}
+	w.ResponseWriterWrapper.WriteHeader(status)
+}
+
+func (w *errorBodyWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriterWrapper.Write(p)
+}
+
+// writeErrorBody answers a 5xx error from serving r with an
+// error_body_format body and logs the error, which the body leaves out so
+// backend details such as socket paths are not exposed. Client errors and
+// responses that are already under way are left to Caddy.
+func (c *ReverseBin) writeErrorBody(w *errorBodyWriter, r *http.Request, err error) error {
+	if err == nil || w.wroteHeader {
+		return err
+	}
+	status := http.StatusInternalServerError
+	var he caddyhttp.HandlerError
+	if errors.As(err, &he) && he.StatusCode != 0 {
+		status = he.StatusCode
+	}
+	if status < http.StatusInternalServerError {
+		return err
+	}
+	logger := c.requestLogger(r)
+	id := requestID(r)
+	if id == "" {
+		// Without request_id_header, Caddy's request UUID ties the body to
+		// the log line.
+		if repl, ok := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer); ok {
+			id, _ = repl.GetString("http.request.uuid")
+		}
+		logger = logger.With(zap.String("request_id", id))
+	}
+	logger.Error("request failed", zap.Int("status", status), zap.Error(err))
+
+	msg := http.StatusText(status)
+	var body []byte
+	switch c.ErrorBodyFormat {
+	case errorBodyJSON:
+		body, _ = json.Marshal(struct {
+			Error     string `json:"error"`
+			RequestID string `json:"request_id"`
+		}{msg, id})
+		w.Header().Set("Content-Type", "application/json")
+	case errorBodyHTML:
+		body = fmt.Appendf(nil, "<!DOCTYPE html>\n<html><head><title>%d %s</title></head><body><h1>%d %s</h1><p>Request ID: %s</p></body></html>\n",
+			status, html.EscapeString(msg), status, html.EscapeString(msg), html.EscapeString(id))
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+	return nil
+}
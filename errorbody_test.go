@@ -0,0 +1,85 @@
+package reversebin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestErrorBodyFormat verifies 5xx errors get a JSON or HTML body carrying the request ID, while other errors and started responses are left to Caddy.
+func TestErrorBodyFormat(t *testing.T) {
+	newRequest := func() *http.Request {
+		// This HTTP request tests the error body written for a failed request.
+		r := caddyhttp.PrepareRequest(httptest.NewRequest(http.MethodGet, "http://app.example/", nil), caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+		r.Header.Set("X-Request-Id", "req-123")
+		return r
+	}
+	c := &ReverseBin{RequestIDHeader: "X-Request-Id", logger: zaptest.NewLogger(t)}
+
+	t.Run("json", func(t *testing.T) {
+		c.ErrorBodyFormat = errorBodyJSON
+		r := newRequest()
+		c.assignRequestID(r)
+		rec := httptest.NewRecorder()
+		w := &errorBodyWriter{ResponseWriterWrapper: &caddyhttp.ResponseWriterWrapper{ResponseWriter: rec}}
+		if err := c.writeErrorBody(w, r, caddyhttp.Error(http.StatusBadGateway, errors.New("dial unix /run/app.sock: refused"))); err != nil {
+			t.Fatalf("expected the error to be answered, got %v", err)
+		}
+		if rec.Code != http.StatusBadGateway || rec.Header().Get("Content-Type") != "application/json" {
+			t.Fatalf("expected 502 application/json, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		var body struct {
+			Error     string `json:"error"`
+			RequestID string `json:"request_id"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("expected a JSON body, got %q: %v", rec.Body.String(), err)
+		}
+		if body.Error != "Bad Gateway" || body.RequestID != "req-123" {
+			t.Fatalf("expected Bad Gateway for req-123, got %+v", body)
+		}
+	})
+
+	t.Run("html", func(t *testing.T) {
+		c.ErrorBodyFormat = errorBodyHTML
+		r := newRequest()
+		c.assignRequestID(r)
+		rec := httptest.NewRecorder()
+		w := &errorBodyWriter{ResponseWriterWrapper: &caddyhttp.ResponseWriterWrapper{ResponseWriter: rec}}
+		if err := c.writeErrorBody(w, r, errors.New("reverse proxy not initialized")); err != nil {
+			t.Fatalf("expected the error to be answered, got %v", err)
+		}
+		if rec.Code != http.StatusInternalServerError || rec.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+			t.Fatalf("expected 500 text/html, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if body := rec.Body.String(); !strings.Contains(body, "<h1>500 Internal Server Error</h1>") || !strings.Contains(body, "Request ID: req-123") || strings.Contains(body, "not initialized") {
+			t.Fatalf("expected an HTML 500 page with the request ID and no error details, got %q", body)
+		}
+	})
+
+	t.Run("passthrough", func(t *testing.T) {
+		c.ErrorBodyFormat = errorBodyJSON
+		clientErr := caddyhttp.Error(http.StatusForbidden, errors.New("client IP not allowed"))
+		rec := httptest.NewRecorder()
+		w := &errorBodyWriter{ResponseWriterWrapper: &caddyhttp.ResponseWriterWrapper{ResponseWriter: rec}}
+		if err := c.writeErrorBody(w, newRequest(), clientErr); !errors.Is(err, clientErr) {
+			t.Fatalf("expected a 4xx error to be returned unchanged, got %v", err)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		serverErr := caddyhttp.Error(http.StatusBadGateway, errors.New("backend went away"))
+		if err := c.writeErrorBody(w, newRequest(), serverErr); !errors.Is(err, serverErr) {
+			t.Fatalf("expected an error after the response started to be returned unchanged, got %v", err)
+		}
+		if rec.Body.Len() != 0 {
+			t.Fatalf("expected no error body, got %q", rec.Body.String())
+		}
+	})
+}
@@ -19,6 +19,7 @@ func main() {
 		log.Fatal(err)
 	}
 	defer listener.Close()
+	fmt.Printf("Listening on %s\n", listener.Addr())
 
 	server := &http.Server{Handler: http.HandlerFunc(handle)}
 	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
@@ -79,6 +80,10 @@ func handle(w http.ResponseWriter, r *http.Request) {
 	case "/pid":
 		writeJSON(w, map[string]any{"pid": os.Getpid()})
 	default:
+		if header := os.Getenv("ECHO_RESPONSE_HEADER"); header != "" {
+			name, value, _ := strings.Cut(header, ":")
+			w.Header().Set(name, strings.TrimSpace(value))
+		}
 		writeJSON(w, map[string]any{
 			"backend": "echo-backend",
 			"pid":     os.Getpid(),
@@ -0,0 +1,78 @@
+package reversebin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"go.uber.org/zap"
+)
+
+// healthPayload is the JSON body served on health_addr.
+type healthPayload struct {
+	Status         string `json:"status"`
+	PID            int    `json:"pid"`
+	UptimeSeconds  int64  `json:"uptime_seconds"`
+	RequestsServed int64  `json:"requests_served"`
+}
+
+// startHealthServer serves the health payload on health_addr, apart from
+// Caddy's own listeners, until the module is unloaded. The listener comes
+// from Caddy's pool so a config reload can take over the address.
+func (c *ReverseBin) startHealthServer() error {
+	if c.HealthAddr == "" {
+		return nil
+	}
+	addr, err := caddy.ParseNetworkAddress(c.HealthAddr)
+	if err != nil {
+		return fmt.Errorf("health_addr: %v", err)
+	}
+	l, err := addr.Listen(c.ctx, 0, net.ListenConfig{})
+	if err != nil {
+		return fmt.Errorf("health_addr: %v", err)
+	}
+	ln, ok := l.(net.Listener)
+	if !ok {
+		return fmt.Errorf("health_addr %s is not a stream listener", c.HealthAddr)
+	}
+	srv := &http.Server{
+		Handler:           http.HandlerFunc(c.serveHealth),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			c.logger.Error("health server stopped", zap.String("addr", c.HealthAddr), zap.Error(err))
+		}
+	}()
+	go func() {
+		<-c.done()
+		_ = srv.Close()
+	}()
+	c.logger.Info("health server listening", zap.String("addr", ln.Addr().String()))
+	return nil
+}
+
+// serveHealth answers any path with the module's health as JSON. pid is
+// Caddy's own process; requests_served counts requests proxied to every
+// backend since the module was loaded.
+func (c *ReverseBin) serveHealth(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	var served int64
+	for _, ps := range c.processes {
+		served += ps.status.served()
+	}
+	c.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(healthPayload{
+		Status:         "ok",
+		PID:            os.Getpid(),
+		UptimeSeconds:  int64(time.Since(c.provisionedAt).Seconds()),
+		RequestsServed: served,
+	})
+}
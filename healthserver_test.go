@@ -0,0 +1,56 @@
+package reversebin
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestHealthServerReportsPayload verifies health_addr serves the JSON payload with the request count summed over backends.
+func TestHealthServerReportsPayload(t *testing.T) {
+	probe, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := probe.Addr().String()
+	_ = probe.Close()
+
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+	c := &ReverseBin{
+		HealthAddr:    addr,
+		ctx:           ctx,
+		provisionedAt: time.Now().Add(-time.Hour),
+		processes:     map[string]*processState{"a": {}, "b": {}},
+		logger:        zaptest.NewLogger(t),
+	}
+	c.processes["a"].status.requests = 3
+	c.processes["b"].status.requests = 4
+	if err := c.startHealthServer(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	// This HTTP request tests the health endpoint on its own listener.
+	resp, err := http.Get("http://" + addr + "/healthz")
+	if err != nil {
+		t.Fatalf("health request: %v", err)
+	}
+	defer resp.Body.Close()
+	var got healthPayload
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Status != "ok" || got.PID != os.Getpid() || got.RequestsServed != 7 || got.UptimeSeconds < 3600 {
+		t.Fatalf("unexpected payload %+v", got)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected application/json, got %q", ct)
+	}
+}
@@ -0,0 +1,84 @@
+package reversebin
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// runShutdownCommand runs shutdown_command for a backend that is about to be
+// stopped. Failures are logged; the backend is stopped either way.
+func (c *ReverseBin) runShutdownCommand(rb *runningBackend) {
+	if len(c.ShutdownCommand) == 0 || rb == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.moduleContext(), c.shutdownCommandTimeout())
+	defer cancel()
+	if err := c.runHookCommand(ctx, "shutdown_command", c.ShutdownCommand, rb.config); err != nil {
+		c.logger.Warn("shutdown_command did not complete", zap.Int("pid", rb.process.Pid()), zap.Error(err))
+	}
+}
+
+// runHookCommand runs a one-shot command such as startup_command with the
+// backend's working directory, environment and user, and logs its output at
+// INFO. It fails if the command exits non-zero or ctx ends first.
+func (c *ReverseBin) runHookCommand(ctx context.Context, name string, args []string, cfg resolvedConfig) error {
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Cancel = func() error {
+		return signalProcessGroup(cmd.Process, syscall.SIGKILL)
+	}
+	configureBackendProcAttrs(cmd)
+	setRunAs(cmd, c.runAs)
+	cmd.Dir = cfg.WorkingDirectory
+	if cmd.Dir == "" {
+		cmd.Dir = "."
+	}
+	env, err := c.backendEnv(cfg)
+	if err != nil {
+		return err
+	}
+	cmd.Env = env
+
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	c.logger.Info("running "+name,
+		zap.String("executable", cmd.Path),
+		zap.Strings("args", c.sanitizeForLog(cmd.Args)))
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	var wg sync.WaitGroup
+	logPipe := func(pipe io.Reader, label string) {
+		defer wg.Done()
+		scanner := bufio.NewScanner(pipe)
+		for scanner.Scan() {
+			c.logger.Info(name, zap.Int("pid", cmd.Process.Pid), zap.String(label, scanner.Text()))
+		}
+	}
+	wg.Add(2)
+	go logPipe(stdoutPipe, "stdout")
+	go logPipe(stderrPipe, "stderr")
+	wg.Wait()
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s timed out: %v", name, ctx.Err())
+		}
+		return fmt.Errorf("%s failed: %v", name, err)
+	}
+	return nil
+}
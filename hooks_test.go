@@ -0,0 +1,63 @@
+//go:build !windows
+
+package reversebin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// TestRunHookCommandUsesBackendDirAndEnv verifies hook commands run like the backend would and report non-zero exits.
+func TestRunHookCommandUsesBackendDirAndEnv(t *testing.T) {
+	dir := t.TempDir()
+	rb := &ReverseBin{logger: zaptest.NewLogger(t)}
+	cfg := resolvedConfig{WorkingDirectory: dir, Envs: []string{"MIGRATION=v2"}}
+
+	if err := rb.runHookCommand(context.Background(), "startup_command", []string{"sh", "-c", `echo "$MIGRATION" > migrated`}, cfg); err != nil {
+		t.Fatalf("runHookCommand: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "migrated"))
+	if err != nil || string(data) != "v2\n" {
+		t.Fatalf("expected migrated file with v2 in dir, got %q (%v)", data, err)
+	}
+
+	err = rb.runHookCommand(context.Background(), "startup_command", []string{"sh", "-c", "exit 3"}, cfg)
+	if err == nil || !strings.Contains(err.Error(), "startup_command failed: exit status 3") {
+		t.Fatalf("expected startup_command exit status 3 error, got %v", err)
+	}
+}
+
+// TestRelativeExecRunsFromRelativeDir verifies a relative exec path is run from a relative dir once, not joined onto it twice.
+func TestRelativeExecRunsFromRelativeDir(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll(filepath.Join("rel", "app"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join("rel", "app", "main.sh"), []byte("#!/bin/sh\necho ran > ran\n"), 0o755); err != nil {
+		t.Fatalf("write main.sh: %v", err)
+	}
+	rb := &ReverseBin{logger: zaptest.NewLogger(t)}
+	cfg := resolvedConfig{Executable: []string{"./main.sh"}, WorkingDirectory: "rel/app"}
+
+	if err := lookExecutable(cfg.Executable[0], cfg.WorkingDirectory); err != nil {
+		t.Fatalf("lookExecutable: %v", err)
+	}
+	cmd, err := rb.backendCommand(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("backendCommand: %v", err)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("run ./main.sh in rel/app: %v: %s", err, out)
+	}
+	if _, err := os.Stat(filepath.Join("rel", "app", "ran")); err != nil {
+		t.Fatalf("expected main.sh to run in rel/app: %v", err)
+	}
+	if err := lookExecutable("./missing.sh", cfg.WorkingDirectory); err == nil {
+		t.Fatal("expected lookExecutable to report a missing relative exec")
+	}
+}
@@ -0,0 +1,151 @@
+package reversebin
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"go.uber.org/zap"
+)
+
+// parkedBackend is a backend left running by a handler instance that was
+// unloaded with hot_config_reload on, waiting for the instance from the
+// new config to adopt it.
+type parkedBackend struct {
+	rb    *runningBackend
+	timer *time.Timer
+	// stop terminates the backend with the settings of the instance that
+	// launched it.
+	stop func(reason string)
+}
+
+var (
+	parkedMu       sync.Mutex
+	parkedBackends = map[string]*parkedBackend{}
+	parkedExitOnce sync.Once
+)
+
+// parkedKey identifies a parked backend. The upstream is part of it because
+// blocks without placeholders all share the empty process key, but no two
+// backends can listen on the same address.
+func parkedKey(processKey string, cfg resolvedConfig) string {
+	return processKey + "\x00" + cfg.ReverseProxyTo
+}
+
+// backendContext is the parent context of backend processes. With
+// hot_config_reload they must outlive this handler instance.
+func (c *ReverseBin) backendContext() context.Context {
+	if c.HotConfigReload {
+		return context.Background()
+	}
+	return c.moduleContext()
+}
+
+// shouldParkBackend reports whether rb should be kept running for the next
+// config instead of being stopped with this one. Backends are never parked
+// when Caddy itself is exiting.
+func (c *ReverseBin) shouldParkBackend(rb *runningBackend) bool {
+	if !c.HotConfigReload || rb == nil || caddy.Exiting() {
+		return false
+	}
+	select {
+	case <-rb.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// parkBackend hands rb over to the next config. It is stopped if nothing
+// adopts it within the idle timeout, or when Caddy exits.
+func (c *ReverseBin) parkBackend(processKey string, rb *runningBackend) {
+	parkedExitOnce.Do(func() {
+		caddy.OnExit(func(context.Context) { stopParkedBackends("caddy exiting") })
+	})
+	cfg := rb.config
+	if rb.port != nil {
+		// Key it as the next config resolves it, before the port is known.
+		cfg.ReverseProxyTo = ""
+	}
+	key := parkedKey(processKey, cfg)
+	p := &parkedBackend{rb: rb, stop: func(reason string) {
+		_ = c.stopBackend(rb, reason, c.terminationGrace())
+	}}
+	c.logger.Info("keeping backend running for the reloaded config",
+		zap.String("key", processKey),
+		zap.Int("pid", rb.process.Pid()))
+
+	parkedMu.Lock()
+	previous := parkedBackends[key]
+	parkedBackends[key] = p
+	p.timer = time.AfterFunc(time.Duration(c.IdleTimeoutMS)*time.Millisecond, func() {
+		if takeParkedBackend(key, p) {
+			p.stop("not adopted after config reload")
+		}
+	})
+	parkedMu.Unlock()
+	if previous != nil {
+		previous.timer.Stop()
+		previous.stop("replaced by a newer parked backend")
+	}
+}
+
+// takeParkedBackend removes p from the parked set, reporting whether it was
+// still there.
+func takeParkedBackend(key string, p *parkedBackend) bool {
+	parkedMu.Lock()
+	defer parkedMu.Unlock()
+	if parkedBackends[key] != p {
+		return false
+	}
+	delete(parkedBackends, key)
+	return true
+}
+
+// adoptParkedBackend returns the backend a previous config left running for
+// processKey, if it runs the same command in the same directory as cfg.
+// Only the environment and other settings that take effect at launch may
+// differ; a backend whose command changed is stopped so cfg can be launched.
+func (c *ReverseBin) adoptParkedBackend(processKey string, cfg resolvedConfig) *runningBackend {
+	key := parkedKey(processKey, cfg)
+	parkedMu.Lock()
+	p := parkedBackends[key]
+	delete(parkedBackends, key)
+	parkedMu.Unlock()
+	if p == nil {
+		return nil
+	}
+	p.timer.Stop()
+
+	prev := p.rb.config
+	if !slices.Equal(prev.Executable, cfg.Executable) || prev.WorkingDirectory != cfg.WorkingDirectory {
+		p.stop("command changed in config reload")
+		return nil
+	}
+	select {
+	case <-p.rb.exited:
+		return nil
+	default:
+	}
+	c.logger.Info("adopted backend from the previous config",
+		zap.String("key", processKey),
+		zap.Int("pid", p.rb.process.Pid()))
+	return p.rb
+}
+
+// stopParkedBackends stops every backend still waiting to be adopted.
+func stopParkedBackends(reason string) {
+	parkedMu.Lock()
+	parked := make([]*parkedBackend, 0, len(parkedBackends))
+	for key, p := range parkedBackends {
+		p.timer.Stop()
+		parked = append(parked, p)
+		delete(parkedBackends, key)
+	}
+	parkedMu.Unlock()
+	for _, p := range parked {
+		p.stop(reason)
+	}
+}
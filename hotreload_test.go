@@ -0,0 +1,45 @@
+//go:build !windows
+
+package reversebin
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// TestAdoptParkedBackendAcrossReload verifies a parked backend is adopted when only its environment changed and stopped when its command changed.
+func TestAdoptParkedBackendAcrossReload(t *testing.T) {
+	old := &ReverseBin{HotConfigReload: true, IdleTimeoutMS: 60000, TerminationGraceMS: 1000, TerminationKillWaitMS: 1000, logger: zaptest.NewLogger(t)}
+	cfg := resolvedConfig{Executable: []string{"sleep", "60"}, Envs: []string{"MODE=old"}, ReverseProxyTo: "unix//tmp/hot.sock"}
+	launch := func() *runningBackend {
+		rb, err := old.launchBackend(old.backendContext(), cfg, "test")
+		if err != nil {
+			t.Fatalf("launchBackend: %v", err)
+		}
+		return rb
+	}
+
+	rb := launch()
+	defer func() { _ = old.stopBackend(rb, "test done", time.Second) }()
+	old.parkBackend("key", rb)
+	reloaded := &ReverseBin{HotConfigReload: true, logger: zaptest.NewLogger(t)}
+	newCfg := cfg
+	newCfg.Envs = []string{"MODE=new"}
+	if got := reloaded.adoptParkedBackend("key", newCfg); got != rb {
+		t.Fatalf("expected the parked backend to be adopted when only env changed, got %v", got)
+	}
+
+	changed := launch()
+	old.parkBackend("key", changed)
+	newCfg.Executable = []string{"sleep", "61"}
+	if got := reloaded.adoptParkedBackend("key", newCfg); got != nil {
+		t.Fatalf("expected no adoption after the command changed, got pid %d", got.process.Pid())
+	}
+	select {
+	case <-changed.exited:
+	default:
+		t.Fatal("expected the parked backend to be stopped after its command changed")
+	}
+}
@@ -0,0 +1,96 @@
+package reversebin
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// inspectPlaceholders are always listed on the inspect page, in addition to
+// any placeholder the configuration refers to.
+var inspectPlaceholders = []string{
+	"http.request.method",
+	"http.request.host",
+	"http.request.uri",
+	"http.request.uri.path",
+	"http.request.uri.query",
+	"http.request.remote",
+}
+
+// serveInspect answers r with the configuration reverse-bin would use for
+// it: the detector still runs, but no backend is started or contacted.
+// Environment values that look like secrets are redacted, but the rest of the
+// environment is shown as is, so without allowed_ips only loopback clients
+// are answered.
+func (c *ReverseBin) serveInspect(w http.ResponseWriter, r *http.Request) error {
+	if len(c.allowedNets) == 0 {
+		if ip, addr := clientIP(r); !addr.IsLoopback() {
+			return caddyhttp.Error(http.StatusForbidden, fmt.Errorf("inspect is only served to loopback clients unless allowed_ips is set, not %q", ip))
+		}
+	}
+	key := c.getProcessKey(r)
+	cfg, err := c.resolveRequestConfig(r, key)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "reverse-bin inspect\n\n")
+	fmt.Fprintf(&b, "process key: %q\n", key)
+	if err != nil {
+		fmt.Fprintf(&b, "error: %v\n", err)
+	} else {
+		fmt.Fprintf(&b, "executable: %q\n", cfg.Executable)
+		fmt.Fprintf(&b, "working directory: %s\n", cfg.WorkingDirectory)
+		fmt.Fprintf(&b, "upstream: %s\n", cfg.ReverseProxyTo)
+		if healthConfigured(cfg.HealthMethod, cfg.HealthPath) {
+			fmt.Fprintf(&b, "health check: %s %s %s\n", cfg.HealthMethod, cfg.HealthPath, healthWant(cfg.HealthStatus))
+		}
+		env, envErr := c.backendEnv(cfg)
+		if envErr != nil {
+			fmt.Fprintf(&b, "environment error: %v\n", envErr)
+		}
+		fmt.Fprintf(&b, "\nenvironment:\n")
+		for _, kv := range c.sanitizeForLog(env) {
+			fmt.Fprintf(&b, "  %s\n", kv)
+		}
+	}
+
+	fmt.Fprintf(&b, "\nplaceholders:\n")
+	repl, _ := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer)
+	for _, name := range c.inspectPlaceholderNames() {
+		val := "<unset>"
+		if repl != nil {
+			if v, ok := repl.GetString(name); ok {
+				val = v
+			}
+		}
+		fmt.Fprintf(&b, "  {%s} = %s\n", name, val)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_, err = w.Write([]byte(b.String()))
+	return err
+}
+
+// inspectPlaceholderNames returns the common request placeholders followed
+// by those referenced from exec, env, socket_template and the detector.
+func (c *ReverseBin) inspectPlaceholderNames() []string {
+	names := slices.Clone(inspectPlaceholders)
+	var args []string
+	args = append(args, c.Executable...)
+	args = append(args, c.Envs...)
+	args = append(args, c.SocketTemplate)
+	args = append(args, c.DynamicProxyDetector...)
+	args = append(args, c.DynamicProxyDetectorHTTP...)
+	for _, arg := range args {
+		for _, name := range placeholderNames(arg) {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,95 @@
+package reversebin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestServeInspectShowsResolvedConfigWithoutStarting verifies inspect mode reports the expanded command, env and placeholders and never starts a backend.
+func TestServeInspectShowsResolvedConfigWithoutStarting(t *testing.T) {
+	rb := &ReverseBin{
+		Executable:     []string{"./server", "--site={http.request.host}"},
+		Envs:           []string{"API_TOKEN=s3cret", "MODE=dev"},
+		ReverseProxyTo: "unix//tmp/app.sock",
+		Inspect:        true,
+		processes:      map[string]*processState{},
+		logger:         zaptest.NewLogger(t),
+	}
+	// This HTTP request tests the page reflects per-request placeholder expansion.
+	req := httptest.NewRequest(http.MethodGet, "http://app.example/x", nil)
+	req.RemoteAddr = "127.0.0.1:5000"
+	repl := caddy.NewReplacer()
+	repl.Set("http.request.host", "app.example")
+	req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, repl))
+	rec := httptest.NewRecorder()
+
+	if err := rb.ServeHTTP(rec, req, NoOpNextHandler{}); err != nil {
+		t.Fatalf("ServeHTTP: %v", err)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`executable: ["./server" "--site=app.example"]`,
+		"upstream: unix//tmp/app.sock",
+		"API_TOKEN=<redacted>",
+		"MODE=dev",
+		"{http.request.host} = app.example",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected inspect page to contain %q, got:\n%s", want, body)
+		}
+	}
+	if len(rb.processes) != 0 {
+		t.Fatalf("expected no backend process state, got %d", len(rb.processes))
+	}
+}
+
+// TestServeInspectRequiresAllowedClient verifies inspect is only answered for loopback clients, or clients in allowed_ips when set.
+func TestServeInspectRequiresAllowedClient(t *testing.T) {
+	rb := &ReverseBin{
+		Executable:     []string{"./server"},
+		Envs:           []string{"DATABASE_URL=postgres://u:p@db/app"},
+		ReverseProxyTo: "unix//tmp/app.sock",
+		Inspect:        true,
+		processes:      map[string]*processState{},
+		logger:         zaptest.NewLogger(t),
+	}
+	serve := func(remote string) (*httptest.ResponseRecorder, error) {
+		// This HTTP request tests inspect access from remote.
+		req := httptest.NewRequest(http.MethodGet, "http://app.example/", nil)
+		req.RemoteAddr = remote
+		req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, caddy.NewReplacer()))
+		rec := httptest.NewRecorder()
+		return rec, rb.ServeHTTP(rec, req, NoOpNextHandler{})
+	}
+	forbidden := func(remote string) {
+		t.Helper()
+		rec, err := serve(remote)
+		var herr caddyhttp.HandlerError
+		if !errors.As(err, &herr) || herr.StatusCode != http.StatusForbidden {
+			t.Fatalf("%s: expected 403, got %v", remote, err)
+		}
+		if strings.Contains(rec.Body.String(), "DATABASE_URL") {
+			t.Fatalf("%s: expected no environment in the response, got:\n%s", remote, rec.Body.String())
+		}
+	}
+
+	forbidden("203.0.113.9:5000")
+	if _, err := serve("[::1]:5000"); err != nil {
+		t.Fatalf("expected loopback client to be answered, got %v", err)
+	}
+
+	rb.allowedNets = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	forbidden("127.0.0.1:5000")
+	if _, err := serve("10.1.2.3:5000"); err != nil {
+		t.Fatalf("expected client in allowed_ips to be answered, got %v", err)
+	}
+}
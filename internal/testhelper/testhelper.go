@@ -0,0 +1,303 @@
+// Package testhelper holds helpers shared by the integration tests: fixture
+// backends, a Caddy started in-process from a Caddyfile snippet, and HTTP
+// assertions against it.
+package testhelper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	caddycmd "github.com/caddyserver/caddy/v2/cmd"
+)
+
+// RepoRoot returns the repository root directory.
+func RepoRoot() string {
+	_, filename, _, ok := runtime.Caller(0)
+	if !ok {
+		panic("unable to determine current file path")
+	}
+	// We're in internal/testhelper/, repo root is ../../
+	return filepath.Clean(filepath.Join(filepath.Dir(filename), "..", ".."))
+}
+
+// RequireIntegration skips t under go test -short.
+func RequireIntegration(t *testing.T) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+}
+
+// FreePort asks the kernel for a free open port that is ready to use.
+func FreePort() (port int, err error) {
+	var a *net.TCPAddr
+	if a, err = net.ResolveTCPAddr("tcp", "localhost:0"); err == nil {
+		var l *net.TCPListener
+		if l, err = net.ListenTCP("tcp", a); err == nil {
+			defer l.Close()
+			return l.Addr().(*net.TCPAddr).Port, nil
+		}
+	}
+	return
+}
+
+// SocketPath returns a unique temp socket path, removed when t ends.
+func SocketPath(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix sockets not supported on Windows")
+	}
+	f, err := os.CreateTemp("", "reverse-bin-*.sock")
+	if err != nil {
+		t.Fatalf("failed to create temp file for socket path: %s", err)
+	}
+	socketPath := f.Name()
+	f.Close()
+	_ = os.Remove(socketPath)
+	t.Cleanup(func() {
+		_ = os.Remove(socketPath)
+	})
+	return socketPath
+}
+
+// WriteScript writes an executable script named name into dir.
+func WriteScript(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+		t.Fatalf("failed to write script %s: %v", path, err)
+	}
+	return path
+}
+
+// PathCheck is a file or directory a test depends on.
+type PathCheck struct {
+	Label         string
+	Path          string
+	MustBeDir     bool
+	MustBeRegular bool
+}
+
+// RequirePaths fails t if any of checks is missing or of the wrong kind.
+func RequirePaths(t *testing.T, checks ...PathCheck) {
+	t.Helper()
+	for _, c := range checks {
+		info, err := os.Stat(c.Path)
+		if err != nil {
+			t.Fatalf("required %s missing/unreadable at %s: %v", c.Label, c.Path, err)
+		}
+		if c.MustBeDir && !info.IsDir() {
+			t.Fatalf("required %s is not a directory: %s", c.Label, c.Path)
+		}
+		if c.MustBeRegular && !info.Mode().IsRegular() {
+			t.Fatalf("required %s is not a regular file: %s", c.Label, c.Path)
+		}
+	}
+}
+
+// Fixtures are the prebuilt backends integration tests run.
+type Fixtures struct {
+	GoEchoBin string
+	AppDir    string
+}
+
+var (
+	goEchoBuildOnce sync.Once
+	goEchoBuildPath string
+	goEchoBuildErr  error
+)
+
+// MustFixtures builds the go-echo example app, once per test binary.
+func MustFixtures(t *testing.T) Fixtures {
+	t.Helper()
+	repoRoot := RepoRoot()
+	appDir := filepath.Join(repoRoot, "examples/reverse-proxy/apps/go-echo")
+	mainGo := filepath.Join(appDir, "main.go")
+	RequirePaths(t,
+		PathCheck{Label: "go echo app", Path: mainGo, MustBeRegular: true},
+		PathCheck{Label: "dynamic app dir", Path: appDir, MustBeDir: true},
+	)
+
+	goEchoBuildOnce.Do(func() {
+		binDir, err := os.MkdirTemp("", "reverse-bin-go-echo-*")
+		if err != nil {
+			goEchoBuildErr = fmt.Errorf("failed to create go echo build dir: %w", err)
+			return
+		}
+		goEchoBuildPath = filepath.Join(binDir, "go-echo")
+		if runtime.GOOS == "windows" {
+			goEchoBuildPath += ".exe"
+		}
+		cmd := exec.Command("go", "build", "-o", goEchoBuildPath, ".")
+		cmd.Dir = appDir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			goEchoBuildErr = fmt.Errorf("failed to build go echo app: %w\n%s", err, string(out))
+		}
+	})
+	if goEchoBuildErr != nil {
+		t.Fatal(goEchoBuildErr)
+	}
+	return Fixtures{GoEchoBin: goEchoBuildPath, AppDir: appDir}
+}
+
+// Main runs m's tests and then removes the fixtures MustFixtures built. Call
+// it from TestMain:
+//
+//	func TestMain(m *testing.M) { os.Exit(testhelper.Main(m)) }
+func Main(m *testing.M) int {
+	code := m.Run()
+	if goEchoBuildPath != "" {
+		_ = os.RemoveAll(filepath.Dir(goEchoBuildPath))
+	}
+	return code
+}
+
+// RenderTemplate replaces each {{KEY}} in input with values[KEY].
+func RenderTemplate(input string, values map[string]string) string {
+	replacements := make([]string, 0, len(values)*2)
+	for k, v := range values {
+		replacements = append(replacements, "{{"+k+"}}", v)
+	}
+	return strings.NewReplacer(replacements...).Replace(input)
+}
+
+// NewTransport dials every address on 127.0.0.1, keeping only the port, so
+// tests can use virtual host names.
+func NewTransport() *http.Transport {
+	dialer := net.Dialer{Timeout: 5 * time.Second, KeepAlive: 5 * time.Second}
+	dialContext := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		parts := strings.Split(addr, ":")
+		destAddr := fmt.Sprintf("127.0.0.1:%s", parts[len(parts)-1])
+		return dialer.DialContext(ctx, network, destAddr)
+	}
+	return &http.Transport{DialContext: dialContext}
+}
+
+// NewHTTPClient is an HTTP client using NewTransport.
+func NewHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: NewTransport(),
+		Timeout:   10 * time.Second,
+	}
+}
+
+// AssertGetResponse GETs requestURI, retrying connection errors for up to two
+// seconds while Caddy starts, and fails t unless the response has
+// expectedStatusCode and a body containing expectedBodyContains.
+func AssertGetResponse(t *testing.T, client *http.Client, requestURI string, expectedStatusCode int, expectedBodyContains string, invariant string) (*http.Response, string) {
+	t.Helper()
+
+	var (
+		resp *http.Response
+		err  error
+	)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp, err = client.Get(requestURI)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: failed to call server: %v", invariant, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("%s: unable to read response body: %v", invariant, err)
+	}
+	body := string(bodyBytes)
+
+	if resp.StatusCode != expectedStatusCode {
+		t.Fatalf("%s: requesting %q expected status %d but got %d (body: %s)", invariant, requestURI, expectedStatusCode, resp.StatusCode, body)
+	}
+	if expectedBodyContains != "" && !strings.Contains(body, expectedBodyContains) {
+		t.Fatalf("%s: requesting %q expected body to contain %q but got %q", invariant, requestURI, expectedBodyContains, body)
+	}
+	return resp, body
+}
+
+// ProcessExists reports whether pid is a running process.
+func ProcessExists(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+// ReverseProxySetup is a running Caddy started by StartReverseProxy.
+type ReverseProxySetup struct {
+	Port int
+}
+
+// StartReverseProxy runs Caddy in-process with handleBlock, after
+// RenderTemplate with values, as the only site on a free port. The caller
+// must call the returned function to stop it. The test binary must import
+// the modules handleBlock uses.
+func StartReverseProxy(t *testing.T, handleBlock string, values map[string]string) (*ReverseProxySetup, func()) {
+	t.Helper()
+
+	port, err := FreePort()
+	if err != nil {
+		t.Fatalf("failed to get free port: %v", err)
+	}
+
+	vars := map[string]string{}
+	for k, v := range values {
+		vars[k] = v
+	}
+	resolvedHandle := RenderTemplate(handleBlock, vars)
+
+	caddyfilePath := filepath.Join(t.TempDir(), "Caddyfile")
+	fixture := `
+{
+	admin off
+	http_port {{HTTP_PORT}}
+}
+
+http://localhost:{{HTTP_PORT}} {
+	{{HANDLE_BLOCK}}
+}
+`
+	rendered := RenderTemplate(fixture, map[string]string{
+		"HTTP_PORT":    fmt.Sprintf("%d", port),
+		"HANDLE_BLOCK": resolvedHandle,
+	})
+	if err := os.WriteFile(caddyfilePath, []byte(rendered), 0o600); err != nil {
+		t.Fatalf("failed to write temp Caddyfile: %v", err)
+	}
+
+	prevArgs := os.Args
+	os.Args = []string{"caddy", "run", "--config", caddyfilePath, "--adapter", "caddyfile"}
+	go caddycmd.Main()
+
+	dispose := func() {
+		os.Args = prevArgs
+		_ = caddy.Stop()
+	}
+
+	return &ReverseProxySetup{Port: port}, dispose
+}
@@ -0,0 +1,49 @@
+package reversebin
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap"
+)
+
+// requestLatency collects the per-request timings reported by
+// access_log_backend_latency. Only the request's own goroutine touches it.
+type requestLatency struct {
+	startup time.Duration
+}
+
+type requestLatencyCtxKey struct{}
+
+// withRequestLatency returns r carrying a fresh requestLatency.
+func withRequestLatency(r *http.Request) (*http.Request, *requestLatency) {
+	lat := &requestLatency{}
+	return r.WithContext(context.WithValue(r.Context(), requestLatencyCtxKey{}, lat)), lat
+}
+
+// recordStartupLatency notes time spent starting a backend for r, if r is
+// being timed.
+func recordStartupLatency(r *http.Request, d time.Duration) {
+	if lat, ok := r.Context().Value(requestLatencyCtxKey{}).(*requestLatency); ok {
+		lat.startup += d
+	}
+}
+
+// backendLatency is the part of total not spent starting a backend.
+func (lat *requestLatency) backendLatency(total time.Duration) time.Duration {
+	return max(total-lat.startup, 0)
+}
+
+// logLatency adds the latency fields to r's access log entry. total covers
+// the whole proxied exchange; startup time is subtracted from it so the
+// backend latency reflects only the backend's response time.
+func (lat *requestLatency) logLatency(r *http.Request, total time.Duration) {
+	extra, ok := r.Context().Value(caddyhttp.ExtraLogFieldsCtxKey).(*caddyhttp.ExtraLogFields)
+	if !ok {
+		return
+	}
+	extra.Set(zap.Int64("reverse_bin_backend_latency_ms", lat.backendLatency(total).Milliseconds()))
+	extra.Set(zap.Int64("reverse_bin_startup_latency_ms", lat.startup.Milliseconds()))
+}
@@ -0,0 +1,46 @@
+package reversebin
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// lifecycle_hook events, passed to the hook as its last argument.
+const (
+	lifecycleStart       = "start"
+	lifecycleReady       = "ready"
+	lifecycleCrash       = "crash"
+	lifecycleIdleTimeout = "idle_timeout"
+)
+
+// lifecycleHookTimeout bounds each lifecycle_hook run.
+const lifecycleHookTimeout = 30 * time.Second
+
+// runLifecycleHook runs lifecycle_hook for event in the background, with
+// the backend's environment plus REVERSE_BIN_PID, REVERSE_BIN_EXECUTABLE and
+// REVERSE_BIN_SOCKET. Failures are only logged.
+func (c *ReverseBin) runLifecycleHook(event string, pid int, cfg resolvedConfig) {
+	if len(c.LifecycleHook) == 0 {
+		return
+	}
+	cfg.Envs = append(slices.Clone(cfg.Envs),
+		"REVERSE_BIN_PID="+strconv.Itoa(pid),
+		"REVERSE_BIN_EXECUTABLE="+strings.Join(cfg.Executable, " "),
+		socketEnv+"="+strings.TrimPrefix(cfg.ReverseProxyTo, "unix/"))
+	args := append(slices.Clone(c.LifecycleHook), event)
+	go func() {
+		ctx, cancel := context.WithTimeout(c.moduleContext(), lifecycleHookTimeout)
+		defer cancel()
+		if err := c.runHookCommand(ctx, "lifecycle_hook", args, cfg); err != nil {
+			c.logger.Warn("lifecycle_hook failed",
+				zap.String("event", event),
+				zap.Int("pid", pid),
+				zap.Error(err))
+		}
+	}()
+}
@@ -0,0 +1,79 @@
+//go:build !windows
+
+package reversebin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestLifecycleHookReportsEvents verifies lifecycle_hook runs for start, ready, crash and idle_timeout with the backend's PID and socket.
+func TestLifecycleHookReportsEvents(t *testing.T) {
+	dir := t.TempDir()
+	sock := filepath.Join(dir, "app.sock")
+	events := filepath.Join(dir, "events")
+	f := useMockProcesses(t, sock, http.NotFoundHandler())
+	c := &ReverseBin{
+		Executable:            []string{"./app"},
+		ReverseProxyTo:        "unix/" + sock,
+		LifecycleHook:         []string{"sh", "-c", `echo "$1 $REVERSE_BIN_PID $REVERSE_BIN_SOCKET" >> ` + events, "hook"},
+		IdleTimeoutMS:         200,
+		HealthTimeoutMS:       2000,
+		TerminationGraceMS:    1000,
+		TerminationKillWaitMS: 1000,
+		processes:             map[string]*processState{},
+		logger:                zaptest.NewLogger(t),
+	}
+	t.Cleanup(func() { _ = c.Cleanup() })
+	serve := func() {
+		t.Helper()
+		// This HTTP request tests one request's trip through the supervisor, as ServeHTTP drives it.
+		req := caddyhttp.PrepareRequest(httptest.NewRequest(http.MethodGet, "http://app.example/", nil), caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+		ps := c.getOrCreateProcessState(c.getProcessKey(req))
+		if err := c.sendSupervisorCommand(ps, supervisorRequestStarted, "request started"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.GetUpstreams(req); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.sendSupervisorCommand(ps, supervisorRequestDone, "request done"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first backend crashes before its idle timeout; the second one
+	// reaches it.
+	serve()
+	f.started[0].exit()
+	time.Sleep(100 * time.Millisecond)
+	serve()
+	time.Sleep(800 * time.Millisecond)
+
+	data, err := os.ReadFile(events)
+	if err != nil {
+		t.Fatalf("expected hook output: %v", err)
+	}
+	got := strings.Split(strings.TrimSpace(string(data)), "\n")
+	slices.Sort(got)
+	want := []string{
+		"crash 100001 " + sock,
+		"idle_timeout 100002 " + sock,
+		"ready 100001 " + sock,
+		"ready 100002 " + sock,
+		"start 100001 " + sock,
+		"start 100002 " + sock,
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected events %q, got %q", want, got)
+	}
+}
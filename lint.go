@@ -0,0 +1,160 @@
+package reversebin
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	caddycmd "github.com/caddyserver/caddy/v2/cmd"
+	"github.com/spf13/cobra"
+)
+
+// Diagnostic is one problem ValidateCaddyfile found in a reverse-bin block.
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d: %s", d.File, d.Line, d.Message)
+}
+
+// ValidateCaddyfile checks every reverse-bin block in a Caddyfile for
+// mistakes that would otherwise only show up when Caddy provisions the
+// handler or launches a backend:
+//
+//   - directives reverse-bin does not accept, or accepts with other arguments
+//   - no reverse_proxy_to, or other way to find the upstream
+//   - an exec command that is missing or not executable
+//   - env setting a key that pass_env or pass_all_env already passes
+//   - stdio_mode with directives that only apply to proxied requests
+//   - exec, env or env_from_header values that start a backend per request
+//     but share one socket because socket_template does not include them
+//
+// The error is only for a Caddyfile that does not parse at all.
+func ValidateCaddyfile(filename string, body []byte) ([]Diagnostic, error) {
+	blocks, err := caddyfile.Parse(filename, body)
+	if err != nil {
+		return nil, err
+	}
+	var diags []Diagnostic
+	for _, sb := range blocks {
+		for _, seg := range sb.Segments {
+			for i, tok := range seg {
+				if tok.Text != "reverse-bin" || (i > 0 && seg[i-1].Line == tok.Line) {
+					continue
+				}
+				diags = append(diags, lintReverseBin(seg[i:])...)
+			}
+		}
+	}
+	return diags, nil
+}
+
+// lintReverseBin checks the reverse-bin directive at the start of tokens.
+func lintReverseBin(tokens []caddyfile.Token) []Diagnostic {
+	d := caddyfile.NewDispenser(tokens)
+	d.Next()
+	block := d.NewFromNextSegment()
+	n := 0
+	for block.Next() {
+		n++
+	}
+	block.Reset()
+	tokens = tokens[:n]
+
+	at := func(tok caddyfile.Token, format string, args ...any) Diagnostic {
+		return Diagnostic{File: tok.File, Line: tok.Line, Message: fmt.Sprintf(format, args...)}
+	}
+	// directive finds the first line of the block starting with name,
+	// falling back to the reverse-bin line.
+	directive := func(name string) caddyfile.Token {
+		for i, tok := range tokens {
+			if i > 0 && tok.Text == name && tokens[i-1].Line != tok.Line {
+				return tok
+			}
+		}
+		return tokens[0]
+	}
+
+	c := new(ReverseBin)
+	if err := c.UnmarshalCaddyfile(block); err != nil {
+		// The dispenser is left on the token the error is about.
+		msg := strings.TrimSuffix(err.Error(), fmt.Sprintf(", at %s:%d", block.File(), block.Line()))
+		return []Diagnostic{{File: block.File(), Line: block.Line(), Message: msg}}
+	}
+
+	var diags []Diagnostic
+	if !c.hasDetector() && !c.StdioMode && c.ReverseProxyTo == "" && c.SocketTemplate == "" && c.PortDiscoveryPattern == "" && c.StartupAddressPattern == "" && c.SocketActivation == nil {
+		diags = append(diags, at(tokens[0], "reverse_proxy_to is required when dynamic_proxy_detector is not set"))
+	}
+	if c.StdioMode {
+		if names := c.stdioProxyOnlyDirectives(); len(names) > 0 {
+			diags = append(diags, at(directive("stdio_mode"), "stdio_mode cannot be combined with %s, which only apply to proxied requests", strings.Join(names, ", ")))
+		}
+	}
+	if name, err := c.checkSharedSocket(); err != nil {
+		diags = append(diags, at(directive(name), "%v", err))
+	}
+	if len(c.Executable) > 0 && !hasPlaceholders(c.Executable[:1]) {
+		if err := lookExecutable(c.Executable[0], c.WorkingDirectory); err != nil {
+			// The error reads "exec: <name>: ...".
+			diags = append(diags, at(directive("exec"), "%v", err))
+		}
+	}
+	for _, env := range c.Envs {
+		key, _, _ := strings.Cut(env, "=")
+		switch {
+		case slices.Contains(c.PassEnvs, key):
+			diags = append(diags, at(directive("env"), "env %s overrides the %s passed by pass_env", key, key))
+		case c.PassAll:
+			if _, ok := os.LookupEnv(key); ok {
+				diags = append(diags, at(directive("env"), "env %s overrides the %s passed by pass_all_env", key, key))
+			}
+		}
+	}
+	return diags
+}
+
+func init() {
+	caddycmd.RegisterCommand(caddycmd.Command{
+		Name:  "reverse-bin-lint",
+		Usage: "[--config <path>]",
+		Short: "Checks the reverse-bin blocks in a Caddyfile for common mistakes",
+		Long: `
+Checks every reverse-bin block in a Caddyfile for unknown or malformed
+subdirectives, a missing reverse_proxy_to, an exec command that is missing or
+not executable, env overriding keys passed by pass_env or pass_all_env,
+stdio_mode combined with directives that only apply to proxied requests, and
+per-request exec, env or env_from_header values missing from socket_template.
+Each problem is printed as file:line: message, and the exit status is non-zero
+if any are found.`,
+		CobraFunc: func(cmd *cobra.Command) {
+			cmd.Flags().StringP("config", "c", "Caddyfile", "Caddyfile to check")
+			cmd.RunE = caddycmd.WrapCommandFuncForCobra(cmdLint)
+		},
+	})
+}
+
+func cmdLint(fl caddycmd.Flags) (int, error) {
+	path := fl.String("config")
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return caddy.ExitCodeFailedStartup, err
+	}
+	diags, err := ValidateCaddyfile(path, body)
+	if err != nil {
+		return caddy.ExitCodeFailedStartup, err
+	}
+	for _, d := range diags {
+		fmt.Println(d)
+	}
+	if len(diags) > 0 {
+		return caddy.ExitCodeFailedStartup, fmt.Errorf("found %d problem(s) in %s", len(diags), path)
+	}
+	return caddy.ExitCodeSuccess, nil
+}
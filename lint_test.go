@@ -0,0 +1,85 @@
+package reversebin
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestValidateCaddyfile verifies each common mistake is reported on the line that causes it, and a correct block passes.
+func TestValidateCaddyfile(t *testing.T) {
+	dir := t.TempDir()
+	app := filepath.Join(dir, "app")
+	if err := os.WriteFile(app, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	notExecutable := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(notExecutable, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("REVERSE_BIN_LINT_TEST", "1")
+
+	input := `example.com {
+	handle /ok/* {
+		reverse-bin {
+			exec ` + app + `
+			reverse_proxy_to unix//run/ok.sock
+		}
+	}
+	handle /missing-upstream/* {
+		reverse-bin {
+			exec ` + app + `
+		}
+	}
+	reverse-bin /not-executable/* {
+		exec ` + notExecutable + `
+		reverse_proxy_to unix//run/a.sock
+	}
+	reverse-bin /env/* {
+		exec ` + app + `
+		reverse_proxy_to unix//run/b.sock
+		pass_env HOME
+		pass_all_env
+		env HOME=/srv REVERSE_BIN_LINT_TEST=2
+	}
+	reverse-bin /typo/* {
+		exce ` + app + `
+	}
+	reverse-bin /activated/* {
+		exec ` + app + `
+		socket_activation
+	}
+	reverse-bin /shared/* {
+		reverse_proxy_to unix//run/c.sock
+		exec ` + app + ` {http.request.host}
+	}
+}
+`
+	diags, err := ValidateCaddyfile("Caddyfile", []byte(input))
+	if err != nil {
+		t.Fatalf("ValidateCaddyfile: %v", err)
+	}
+	want := []struct {
+		line     int
+		contains string
+	}{
+		{9, "reverse_proxy_to is required"},
+		{14, "exec: \"" + notExecutable + "\": permission denied"},
+		{22, "env HOME overrides the HOME passed by pass_env"},
+		{22, "env REVERSE_BIN_LINT_TEST overrides the REVERSE_BIN_LINT_TEST passed by pass_all_env"},
+		{25, `unknown subdirective: "exce"`},
+		{33, "exec placeholder {http.request.host} starts a backend per value"},
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %d: %v", len(want), len(diags), diags)
+	}
+	for i, w := range want {
+		if diags[i].File != "Caddyfile" || diags[i].Line != w.line || !strings.Contains(diags[i].Message, w.contains) {
+			t.Errorf("diagnostic %d: expected Caddyfile:%d containing %q, got %s", i, w.line, w.contains, diags[i])
+		}
+		if strings.Contains(diags[i].Message, ", at ") {
+			t.Errorf("diagnostic %d: expected the location only once, got %s", i, diags[i])
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package reversebin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLivenessIntervalMS       = 30000
+	defaultLivenessFailureThreshold = 3
+)
+
+func (c *ReverseBin) livenessInterval() time.Duration {
+	return time.Duration(c.LivenessIntervalMS) * time.Millisecond
+}
+
+// provisionLiveness applies the liveness_check defaults.
+func (c *ReverseBin) provisionLiveness() {
+	if c.LivenessMethod == "" {
+		return
+	}
+	c.LivenessMethod = strings.ToUpper(c.LivenessMethod)
+	if c.LivenessIntervalMS <= 0 {
+		c.LivenessIntervalMS = defaultLivenessIntervalMS
+	}
+	if c.LivenessFailureThreshold <= 0 {
+		c.LivenessFailureThreshold = defaultLivenessFailureThreshold
+	}
+}
+
+// monitorLiveness probes rb with liveness_check until it exits. After
+// liveness_failure_threshold failures in a row it asks the supervisor to
+// stop rb; the next request launches a fresh backend.
+func (c *ReverseBin) monitorLiveness(ps *processState, rb *runningBackend) {
+	ticker := time.NewTicker(c.livenessInterval())
+	defer ticker.Stop()
+	cfg := rb.config
+	cfg.HealthMethod, cfg.HealthPath, cfg.HealthStatus = c.LivenessMethod, c.LivenessPath, c.LivenessStatus
+	failures := 0
+	for {
+		select {
+		case <-ticker.C:
+		case <-rb.exited:
+			return
+		case <-c.done():
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.moduleContext(), c.livenessInterval())
+		healthy, result := c.probeHealth(ctx, cfg, nil)
+		cancel()
+		if healthy {
+			failures = 0
+			continue
+		}
+		failures++
+		c.logger.Warn("liveness check failed",
+			zap.String("key", ps.key),
+			zap.Int("pid", rb.process.Pid()),
+			zap.Int("failures", failures),
+			zap.Int("last_status", result.status),
+			zap.String("want", result.want),
+			zap.Error(result.err))
+		if failures < c.LivenessFailureThreshold {
+			continue
+		}
+		cmd := supervisorCommand{
+			kind:    supervisorLivenessFailed,
+			reason:  fmt.Sprintf("liveness check failed %d times", failures),
+			backend: rb,
+		}
+		select {
+		case ps.commands <- cmd:
+		case <-rb.exited:
+		case <-c.done():
+		}
+		return
+	}
+}
@@ -0,0 +1,50 @@
+package reversebin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestLivenessCheckStopsUnhealthyBackend verifies a backend failing liveness_check failure_threshold times in a row is stopped.
+func TestLivenessCheckStopsUnhealthyBackend(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	f := useMockProcesses(t, sock, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	c := &ReverseBin{
+		Executable:               []string{"/nonexistent/backend"},
+		ReverseProxyTo:           "unix/" + sock,
+		LivenessMethod:           http.MethodGet,
+		LivenessPath:             "/healthz",
+		LivenessIntervalMS:       20,
+		LivenessFailureThreshold: 2,
+		IdleTimeoutMS:            60000,
+		HealthTimeoutMS:          2000,
+		TerminationGraceMS:       1000,
+		TerminationKillWaitMS:    1000,
+		processes:                map[string]*processState{},
+		logger:                   zaptest.NewLogger(t),
+	}
+	defer c.Cleanup()
+
+	// This HTTP request tests that the first request launches the backend being monitored.
+	req := caddyhttp.PrepareRequest(httptest.NewRequest(http.MethodGet, "http://app.example/", nil), caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+	if _, err := c.GetUpstreams(req); err != nil {
+		t.Fatalf("GetUpstreams: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	select {
+	case <-f.started[0].exited:
+	default:
+		t.Fatal("expected the failing backend to be stopped")
+	}
+}
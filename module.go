@@ -17,17 +17,26 @@
 package reversebin
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/caddyserver/caddy/v2"
 	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
 	"github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
 	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/headers"
 	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	"github.com/dustin/go-humanize"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 )
 
 func init() {
@@ -41,6 +50,8 @@ func init() {
 	httpcaddyfile.RegisterDirectiveOrder("reverse-bin", httpcaddyfile.Before, "respond")
 }
 
+//go:generate sh -c "go run ./cmd/gen-config-schema > schemas/reverse-bin-config.schema.json"
+
 // ReverseBin supervises executable backends and proxies HTTP traffic to them.
 type ReverseBin struct {
 	// Name of executable script or binary and its arguments
@@ -49,36 +60,235 @@ type ReverseBin struct {
 	WorkingDirectory string `json:"workingDirectory,omitempty"`
 	// Environment key value pairs (key=value) for this particular app
 	Envs []string `json:"envs,omitempty"`
+	// Files of KEY=value lines added to the environment (relative to the working directory)
+	EnvFiles []string `json:"envFiles,omitempty"`
+	// Request headers copied into the backend environment, in order; they override env
+	EnvFromHeaders []EnvFromHeader `json:"envFromHeaders,omitempty"`
 	// Environment keys to pass through for all apps
 	PassEnvs []string `json:"passEnvs,omitempty"`
 	// True to pass all environment variables to the executable
 	PassAll bool `json:"passAllEnvs,omitempty"`
+	// Regular expression for environment keys whose values are hidden in logs and the inspect page; replaces the built-in secret/token/password/key pattern
+	RedactEnvPattern string `json:"redactEnvPattern,omitempty"`
+
+	// Container image run with docker run in place of exec; the reverse_proxy_to socket's directory is mounted at /sockets
+	DockerImage string `json:"dockerImage,omitempty"`
+	// True to run exec once per request, with the request body on stdin and stdout as the response body, instead of proxying to a server
+	StdioMode bool `json:"stdioMode,omitempty"`
+	// Content-Type of stdio_mode responses (default application/octet-stream)
+	StdioContentType string `json:"stdioContentType,omitempty"`
 
 	// Address to proxy to (for proxy mode)
 	ReverseProxyTo string `json:"reverse_proxy_to,omitempty"`
+	// Regular expression matched against the backend's stdout whose first capture group is the port it listens on; the upstream becomes 127.0.0.1:<port>
+	PortDiscoveryPattern string `json:"portDiscoveryPattern,omitempty"`
+	// Bytes of stdout scanned for port_discovery_pattern (default 4KB)
+	PortDiscoveryMaxBytes int64 `json:"portDiscoveryMaxBytes,omitempty"`
+	// Regular expression matched against backend stdout at startup; group 1 is the unix socket path to proxy to, replacing reverse_proxy_to
+	StartupAddressPattern string `json:"startupAddressPattern,omitempty"`
+	// Bytes of stdout scanned for startup_address_pattern (default 4KB)
+	StartupAddressMaxBytes int64 `json:"startupAddressMaxBytes,omitempty"`
+	// Standby unix socket tried when dialing the primary upstream fails; the backend is not restarted
+	ReverseProxyToSecondary string `json:"reverse_proxy_to_secondary,omitempty"`
+	// Unix socket address with Caddy placeholders, expanded per request in place of reverse_proxy_to
+	SocketTemplate string `json:"socketTemplate,omitempty"`
+	// Kind of unix socket the backend listens on (default filesystem), one of: filesystem, abstract
+	SocketType string `json:"socketType,omitempty"`
+	// Permission bits applied to the backend's unix socket once it appears, such as 0660
+	SocketMode string `json:"socketMode,omitempty"`
+	// Group name or gid applied to the backend's unix socket once it appears
+	SocketGroup string `json:"socketGroup,omitempty"`
+	// Index N of the listening socket systemd passed to Caddy (file descriptor 3+N), handed to the backend in place of reverse_proxy_to
+	SocketActivation *int `json:"socketActivation,omitempty"`
+	// False to leave a pre-existing unix socket file in place before launching the backend (default true)
+	SocketCleanupOnStart *bool `json:"socketCleanupOnStart,omitempty"`
+	// Milliseconds to wait for the backend to bind its unix socket; 0 leaves it to health_timeout_ms
+	SocketWaitTimeoutMS int `json:"socketWaitTimeoutMs,omitempty"`
+	// Milliseconds between checks for the backend's unix socket while it starts (default 50)
+	SocketPollIntervalMS int `json:"socketPollIntervalMs,omitempty"`
 	// Health check method (GET or HEAD)
 	HealthMethod string `json:"healthMethod,omitempty"`
 	// Health check path
 	HealthPath string `json:"healthPath,omitempty"`
 	// Exact health check status; zero accepts any 2xx/3xx response
 	HealthStatus int `json:"healthStatus,omitempty"`
+	// Liveness check method (GET or HEAD), polled while the backend runs; empty disables liveness checks
+	LivenessMethod string `json:"livenessMethod,omitempty"`
+	// Liveness check path
+	LivenessPath string `json:"livenessPath,omitempty"`
+	// Exact liveness check status; zero accepts any 2xx/3xx response
+	LivenessStatus int `json:"livenessStatus,omitempty"`
+	// Milliseconds between liveness checks (default 30000)
+	LivenessIntervalMS int `json:"livenessIntervalMs,omitempty"`
+	// Consecutive failed liveness checks after which the backend is stopped (default 3)
+	LivenessFailureThreshold int `json:"livenessFailureThreshold,omitempty"`
 	// Binary and arguments to run to determine proxy parameters dynamically
 	DynamicProxyDetector []string `json:"dynamic_proxy_detector,omitempty"`
+	// How the detector binary is run (default once), one of: once, stream
+	DetectorMode string `json:"detectorMode,omitempty"`
+	// Environment variables (KEY=value) added for the detector binary only, never the backend
+	DetectorEnvs []string `json:"detectorEnvs,omitempty"`
+	// HTTP method and URL to fetch proxy parameters from instead of running a detector binary
+	DynamicProxyDetectorHTTP []string `json:"dynamic_proxy_detector_http,omitempty"`
+	// Timeout in milliseconds for the HTTP detector (default, health timeout)
+	DetectorHTTPTimeoutMS int `json:"detectorHttpTimeoutMs,omitempty"`
+	// Basic auth username sent to the HTTP detector
+	DetectorHTTPUser string `json:"detectorHttpUser,omitempty"`
+	// Basic auth password sent to the HTTP detector
+	DetectorHTTPPassword string `json:"detectorHttpPassword,omitempty"`
 	// Idle timeout in milliseconds before stopping backend process after last request
 	IdleTimeoutMS int `json:"idleTimeoutMs,omitempty"`
 	// Health timeout in milliseconds before startup fails
 	HealthTimeoutMS int `json:"healthTimeoutMs,omitempty"`
+	// What a request does when its backend fails to start (503 fails it, the default; queue relaunches the backend until the client gives up; retry does the same with a pause between launches), one of: 503, queue, retry
+	StartupTimeoutAction string `json:"startupTimeoutAction,omitempty"`
+	// Pause in milliseconds between launches for startup_timeout_action retry (default 1000)
+	StartupRetryDelayMS int `json:"startupRetryDelayMs,omitempty"`
 	// Termination grace in milliseconds before SIGKILL
 	TerminationGraceMS int `json:"terminationGraceMs,omitempty"`
 	// Kill wait in milliseconds after SIGKILL before reporting failure
 	TerminationKillWaitMS int `json:"terminationKillWaitMs,omitempty"`
+	// Protocol spoken to the backend (default http), one of: http, scgi, fastcgi
+	BackendProto string `json:"backendProto,omitempty"`
+	// PROXY protocol header version sent at the start of each backend connection (http backends only), one of: v1, v2
+	ProxyProtocol string `json:"proxyProtocol,omitempty"`
+	// Milliseconds to wait for a connection to the backend before answering 502 (default 3000)
+	BackendConnectTimeoutMS int `json:"backendConnectTimeoutMs,omitempty"`
+	// False to open a new connection to an http backend for every request (default true)
+	Keepalive *bool `json:"keepalive,omitempty"`
+	// Milliseconds an idle pooled backend connection is kept open (default 120000)
+	KeepaliveIdleMS int `json:"keepaliveIdleMs,omitempty"`
+	// Milliseconds between TCP keep-alive probes on backend connections (default 30000); unix sockets have none
+	KeepaliveIntervalMS int `json:"keepaliveIntervalMs,omitempty"`
+	// Idle connections kept per backend (default 32)
+	KeepalivePoolSize int `json:"keepalivePoolSize,omitempty"`
+	// Most connections, idle or busy, open to the backend at once; further requests wait for one to free up (default no limit, http backends only)
+	BackendMaxConns int `json:"backendMaxConns,omitempty"`
+	// TLS settings for talking to an http backend, also over unix sockets
+	BackendTLS *BackendTLS `json:"backendTls,omitempty"`
+	// True to follow backend redirects to its own paths instead of passing them to the client
+	BackendFollowRedirects bool `json:"backendFollowRedirects,omitempty"`
+	// Maximum redirects followed per request when following is enabled
+	BackendMaxRedirects int `json:"backendMaxRedirects,omitempty"`
+	// True to decode gzip and brotli backend responses before they reach the client
+	DecompressResponse bool `json:"decompressResponse,omitempty"`
+	// Bytes of a backend response of unknown length buffered in memory (spilling to a temp file) so it can be sent with Content-Length
+	ResponseBufferSize int64 `json:"responseBufferSize,omitempty"`
+	// What happens to a response larger than response_buffer_size (file spills it to a temp file, the default; stream sends it on without Content-Length), one of: file, stream
+	ResponseBufferOverflow string `json:"responseBufferOverflow,omitempty"`
+	// Milliseconds to wait for the backend's response headers after sending the request; 0 waits indefinitely
+	ResponseHeaderTimeoutMS int `json:"responseHeaderTimeoutMs,omitempty"`
+	// Milliseconds the backend may go silent between response body reads, for slow streaming responses; 0 waits indefinitely
+	ResponseIdleTimeoutMS int `json:"responseIdleTimeoutMs,omitempty"`
+	// Largest request body accepted, in bytes; larger declared bodies get 413 before a backend is started
+	MaxRequestBodySize int64 `json:"maxRequestBodySize,omitempty"`
+	// True to hold the request body until the backend answers Expect: 100-continue
+	RelayExpectContinue bool `json:"relayExpectContinue,omitempty"`
+	// Times a request is retried on a fresh backend after a 502/503 response or a failed exchange
+	RetryOnFailure int `json:"retryOnFailure,omitempty"`
+	// True to let retry_on_failure retry methods that are not idempotent, such as POST
+	RetryUnsafeMethods bool `json:"retryUnsafeMethods,omitempty"`
+	// True to add backend and startup latency fields to the access log entry
+	AccessLogBackendLatency bool `json:"accessLogBackendLatency,omitempty"`
+	// Milliseconds complete 2xx GET/HEAD responses are cached in memory and served without the backend; 0 disables caching
+	CacheTTLMS int `json:"cacheTtlMs,omitempty"`
+	// Bytes of response bodies the cache may hold (default 64MB)
+	CacheMaxSize int64 `json:"cacheMaxSize,omitempty"`
+	// Client IP addresses and CIDR ranges, IPv4 or IPv6, allowed to use this handler; others get 403 before any backend is started
+	AllowedIPs []string `json:"allowedIps,omitempty"`
+	// Requests forwarded to the backend per period, such as 100/s, 10/m or 1000/h; excess requests get 429
+	RateLimit string `json:"rateLimit,omitempty"`
+	// Consecutive failed requests (5xx or proxy errors) that open the circuit breaker; 0 disables it
+	CircuitBreakerThreshold int `json:"circuitBreakerThreshold,omitempty"`
+	// Milliseconds the open circuit breaker answers 503 before letting a probe request through (default 30000)
+	CircuitBreakerOpenDurationMS int `json:"circuitBreakerOpenDurationMs,omitempty"`
+	// Files whose changes restart the backend after in-flight requests finish (relative to the working directory)
+	WatchFiles []string `json:"watchFiles,omitempty"`
+	// Cron expression, such as "0 3 * * *", at which the backend is restarted after in-flight requests finish
+	RestartSchedule string `json:"restartSchedule,omitempty"`
+	// Milliseconds a restart waits for in-flight requests before stopping the backend anyway (default 30000); requests arriving meanwhile wait for the fresh backend
+	RestartDrainTimeoutMS int `json:"restartDrainTimeoutMs,omitempty"`
+	// File, with Caddy placeholders, that one JSON line per proxied request is appended to
+	RequestLog string `json:"requestLog,omitempty"`
+	// False to keep W3C traceparent and tracestate headers from the backend; when on (default) and Caddy tracing is enabled, the backend call gets a span of its own
+	TracingHeaders *bool `json:"tracingHeaders,omitempty"`
+	// Request header carrying a per-request ID to the backend; a client-sent value is forwarded, otherwise a UUID v4 is generated
+	RequestIDHeader string `json:"requestIdHeader,omitempty"`
+	// Request headers passed to the backend exactly as the client sent them, replacing what reverse_proxy would set, such as X-Forwarded-For
+	ProxyHeadersFromRequest []string `json:"proxyHeadersFromRequest,omitempty"`
+	// Header operations applied to requests before they are forwarded to the backend
+	UpstreamHeaders *headers.HeaderOps `json:"upstreamHeaders,omitempty"`
+	// Header operations applied to backend responses before they reach the client
+	ResponseHeaders *headers.HeaderOps `json:"responseHeaders,omitempty"`
+	// Backend response status codes mapped to the status sent to the client
+	StatusOverrides map[int]int `json:"statusOverrides,omitempty"`
+	// Body for 5xx errors reverse-bin itself returns, such as a backend that fails to start (the default leaves them to Caddy), one of: json, html
+	ErrorBodyFormat string `json:"errorBodyFormat,omitempty"`
+	// Regular expression substitutions applied in order to text/* and application/json backend response bodies
+	ResponseRewrites []ResponseRewrite `json:"responseRewrites,omitempty"`
+	// User name or uid to start the backend as (requires Caddy to run as root)
+	RunAs string `json:"runAs,omitempty"`
+	// Address space limit in bytes applied to the backend (best effort, Linux only)
+	MaxMemory int64 `json:"maxMemory,omitempty"`
+	// Relative CPU weight for the backend, 1024 being normal, applied as a nice value (best effort)
+	CPUShares int `json:"cpuShares,omitempty"`
+	// Request path answered with an HTML page describing the backend processes
+	StatusPagePath string `json:"statusPagePath,omitempty"`
+	// Value the status page requires in the X-Reverse-Bin-Status-Secret request header
+	StatusPageSecret string `json:"statusPageSecret,omitempty"`
+	// Network address, such as :9099, of a separate HTTP listener answering with a JSON health payload
+	HealthAddr string `json:"healthAddr,omitempty"`
+	// True to keep backends running across a Caddy config reload when their command, directory and upstream are unchanged
+	HotConfigReload bool `json:"hotConfigReload,omitempty"`
+	// True to launch a fresh backend for every request and stop it once the request is done; requires a socket_template containing {http.request.uuid}
+	PerRequestProcess bool `json:"perRequestProcess,omitempty"`
+	// True to give every launch a new unix socket, app.1.sock, app.2.sock and so on, passed to the backend in REVERSE_BIN_SOCKET
+	SocketPathRotate bool `json:"socketPathRotate,omitempty"`
+	// True to answer requests with the resolved backend configuration instead of proxying (debugging only)
+	Inspect bool `json:"inspect,omitempty"`
+	// Command run to completion before each backend launch; a non-zero exit fails the launch
+	StartupCommand []string `json:"startupCommand,omitempty"`
+	// Command run before an idle backend is sent SIGTERM, e.g. to flush caches
+	ShutdownCommand []string `json:"shutdownCommand,omitempty"`
+	// Timeout in milliseconds for shutdown_command (default 10000)
+	ShutdownCommandTimeoutMS int `json:"shutdownCommandTimeoutMs,omitempty"`
+	// Command run in the background on backend lifecycle events, with the event (start, ready, crash or idle_timeout) appended as its last argument
+	LifecycleHook []string `json:"lifecycleHook,omitempty"`
+	// Path prefixes and request content types served by their own backend; other requests use executable and reverse_proxy_to
+	Routes []PathRoute `json:"routes,omitempty"`
+	// Linux cgroup directory the backend is moved into after it starts (ignored on other platforms)
+	CgroupPath string `json:"cgroupPath,omitempty"`
+	// Linux namespaces to create for the backend (requires root), each one of: pid, net, mnt, ipc, uts
+	ProcessNamespaces []string `json:"processNamespaces,omitempty"`
 
 	// Internal state for proxy mode
-	processes map[string]*processState
-	mu        sync.Mutex
+	processes       map[string]*processState
+	streamDetectors map[string]*streamDetector
+	mu              sync.Mutex
 
 	reverseProxy *reverseproxy.Handler
-	ctx          caddy.Context
+	transport    http.RoundTripper
+	// protoTransport is the innermost transport, which speaks backend_proto.
+	protoTransport http.RoundTripper
+	cloneflags     uintptr
+	routeSockets   []string
+	activation     *activationSocket
+	portPattern    *regexp.Regexp
+	redactPattern  *regexp.Regexp
+	containerName  string
+	requestLogs    *requestLogFiles
+	cache          *responseCache
+	rateLimiter    *rate.Limiter
+	allowedNets    []netip.Prefix
+	breaker        *circuitBreaker
+	runAs          *runAsCredential
+	socketMode     os.FileMode
+	socketGID      int
+	ctx            caddy.Context
+	provisionedAt  time.Time
+	// app holds the settings shared by every handler in the config; nil
+	// when the config has no reverse_bin app.
+	app *App
 
 	logger *zap.Logger
 }
@@ -87,12 +297,26 @@ type processState struct {
 	key      string
 	requests chan supervisorRequest
 	commands chan supervisorCommand
+	status   processStatus
+	// generation counts launches under socket_path_rotate; only the
+	// supervisor touches it.
+	generation int
+}
+
+func (c *ReverseBin) hasDetector() bool {
+	return len(c.DynamicProxyDetector) > 0 || len(c.DynamicProxyDetectorHTTP) > 0
 }
 
 func isUnixUpstream(addr string) bool {
 	return strings.HasPrefix(addr, "unix/")
 }
 
+// isURLUpstream reports whether addr is an http:// or https:// URL rather
+// than a bare host:port or unix socket.
+func isURLUpstream(addr string) bool {
+	return strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://")
+}
+
 func healthConfigured(method, path string) bool {
 	return strings.TrimSpace(method) != "" && strings.TrimSpace(path) != ""
 }
@@ -108,11 +332,25 @@ func parsePositiveMilliseconds(d *caddyfile.Dispenser, name string) (int, error)
 	return v, nil
 }
 
+func parseOnOff(d *caddyfile.Dispenser, name string) (bool, error) {
+	if !d.NextArg() {
+		return false, d.ArgErr()
+	}
+	switch d.Val() {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return false, d.Errf("%s must be on or off", name)
+}
+
 // Interface guards
 var (
 	_ caddyhttp.MiddlewareHandler = (*ReverseBin)(nil)
 	_ caddyfile.Unmarshaler       = (*ReverseBin)(nil)
 	_ caddy.Provisioner           = (*ReverseBin)(nil)
+	_ caddy.Validator             = (*ReverseBin)(nil)
 	_ caddy.CleanerUpper          = (*ReverseBin)(nil)
 )
 
@@ -142,21 +380,93 @@ func (c *ReverseBin) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 					return d.ArgErr()
 				}
 			case "env":
-				c.Envs = d.RemainingArgs()
-				if len(c.Envs) == 0 {
+				envs := d.RemainingArgs()
+				if len(envs) == 0 {
 					return d.ArgErr()
 				}
+				c.Envs = append(c.Envs, envs...)
+			case "env_file":
+				var path string
+				if !d.Args(&path) {
+					return d.ArgErr()
+				}
+				c.EnvFiles = append(c.EnvFiles, path)
+			case "env_from_header":
+				var h EnvFromHeader
+				if !d.Args(&h.Header, &h.Env) || d.NextArg() {
+					return d.ArgErr()
+				}
+				if strings.Contains(h.Env, "=") {
+					return d.Errf("env_from_header: invalid environment variable name %q", h.Env)
+				}
+				c.EnvFromHeaders = append(c.EnvFromHeaders, h)
 			case "pass_env":
-				c.PassEnvs = d.RemainingArgs()
-				if len(c.PassEnvs) == 0 {
+				keys := d.RemainingArgs()
+				if len(keys) == 0 {
 					return d.ArgErr()
 				}
+				c.PassEnvs = append(c.PassEnvs, keys...)
 			case "pass_all_env":
 				c.PassAll = true
+			case "docker_image":
+				if !d.Args(&c.DockerImage) {
+					return d.ArgErr()
+				}
+			case "stdio_mode":
+				v, err := parseOnOff(d, "stdio_mode")
+				if err != nil {
+					return err
+				}
+				c.StdioMode = v
+			case "stdio_content_type":
+				if !d.Args(&c.StdioContentType) {
+					return d.ArgErr()
+				}
+			case "redact_env_pattern":
+				if !d.Args(&c.RedactEnvPattern) {
+					return d.ArgErr()
+				}
+				if _, err := regexp.Compile(c.RedactEnvPattern); err != nil {
+					return d.Errf("redact_env_pattern: %v", err)
+				}
 			case "reverse_proxy_to":
 				if !d.Args(&c.ReverseProxyTo) {
 					return d.ArgErr()
 				}
+			case "port_discovery_pattern":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				c.PortDiscoveryPattern = d.Val()
+				if d.NextArg() {
+					size, err := humanize.ParseBytes(d.Val())
+					if err != nil || size == 0 {
+						return d.Errf("invalid port_discovery_pattern byte limit '%s'", d.Val())
+					}
+					c.PortDiscoveryMaxBytes = int64(size)
+				}
+				if d.NextArg() {
+					return d.ArgErr()
+				}
+			case "startup_address_pattern":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				c.StartupAddressPattern = d.Val()
+				if d.NextArg() {
+					size, err := humanize.ParseBytes(d.Val())
+					if err != nil || size == 0 {
+						return d.Errf("invalid startup_address_pattern byte limit '%s'", d.Val())
+					}
+					c.StartupAddressMaxBytes = int64(size)
+				}
+				if d.NextArg() {
+					return d.ArgErr()
+				}
+			case "reverse_proxy_to_secondary":
+				if !d.Args(&c.ReverseProxyToSecondary) {
+					return d.ArgErr()
+				}
 			case "health_check":
 				args := d.RemainingArgs()
 				if len(args) != 2 && len(args) != 3 {
@@ -171,11 +481,73 @@ func (c *ReverseBin) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 					}
 					c.HealthStatus = status
 				}
+			case "liveness_check":
+				args := d.RemainingArgs()
+				if len(args) != 2 && len(args) != 3 {
+					return d.ArgErr()
+				}
+				c.LivenessMethod = strings.ToUpper(args[0])
+				c.LivenessPath = args[1]
+				if len(args) == 3 {
+					status, err := strconv.Atoi(args[2])
+					if err != nil || status < 100 || status > 599 {
+						return d.Errf("liveness_check status must be an integer from 100 through 599")
+					}
+					c.LivenessStatus = status
+				}
+			case "liveness_interval_ms":
+				v, err := parsePositiveMilliseconds(d, "liveness_interval_ms")
+				if err != nil {
+					return err
+				}
+				c.LivenessIntervalMS = v
+			case "liveness_failure_threshold":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				v, err := strconv.Atoi(d.Val())
+				if err != nil || v <= 0 {
+					return d.Errf("liveness_failure_threshold must be a positive integer")
+				}
+				c.LivenessFailureThreshold = v
 			case "dynamic_proxy_detector":
 				c.DynamicProxyDetector = d.RemainingArgs()
 				if len(c.DynamicProxyDetector) == 0 {
 					return d.ArgErr()
 				}
+			case "detector_env":
+				envs := d.RemainingArgs()
+				if len(envs) == 0 {
+					return d.ArgErr()
+				}
+				c.DetectorEnvs = append(c.DetectorEnvs, envs...)
+			case "detector_mode":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				c.DetectorMode = d.Val()
+				if d.NextArg() {
+					return d.ArgErr()
+				}
+				if c.DetectorMode != "once" && c.DetectorMode != "stream" {
+					return d.Errf("detector_mode must be once or stream")
+				}
+			case "dynamic_proxy_detector_http":
+				args := d.RemainingArgs()
+				if len(args) != 2 {
+					return d.ArgErr()
+				}
+				c.DynamicProxyDetectorHTTP = []string{strings.ToUpper(args[0]), args[1]}
+			case "detector_http_timeout_ms":
+				v, err := parsePositiveMilliseconds(d, "detector_http_timeout_ms")
+				if err != nil {
+					return err
+				}
+				c.DetectorHTTPTimeoutMS = v
+			case "detector_http_basic_auth":
+				if !d.Args(&c.DetectorHTTPUser, &c.DetectorHTTPPassword) {
+					return d.ArgErr()
+				}
 			case "idle_timeout_ms":
 				v, err := parsePositiveMilliseconds(d, "idle_timeout_ms")
 				if err != nil {
@@ -188,6 +560,25 @@ func (c *ReverseBin) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 					return err
 				}
 				c.HealthTimeoutMS = v
+			case "startup_timeout_action":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				c.StartupTimeoutAction = d.Val()
+				if d.NextArg() {
+					return d.ArgErr()
+				}
+				switch c.StartupTimeoutAction {
+				case startupActionFail, startupActionQueue, startupActionRetry:
+				default:
+					return d.Errf("startup_timeout_action must be 503, queue or retry")
+				}
+			case "startup_retry_delay_ms":
+				v, err := parsePositiveMilliseconds(d, "startup_retry_delay_ms")
+				if err != nil {
+					return err
+				}
+				c.StartupRetryDelayMS = v
 			case "termination_grace_ms":
 				v, err := parsePositiveMilliseconds(d, "termination_grace_ms")
 				if err != nil {
@@ -200,6 +591,456 @@ func (c *ReverseBin) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 					return err
 				}
 				c.TerminationKillWaitMS = v
+			case "response_header_timeout_ms":
+				v, err := parsePositiveMilliseconds(d, "response_header_timeout_ms")
+				if err != nil {
+					return err
+				}
+				c.ResponseHeaderTimeoutMS = v
+			case "response_idle_timeout_ms":
+				v, err := parsePositiveMilliseconds(d, "response_idle_timeout_ms")
+				if err != nil {
+					return err
+				}
+				c.ResponseIdleTimeoutMS = v
+			case "response_buffer_size":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				size, err := humanize.ParseBytes(d.Val())
+				if err != nil || size == 0 {
+					return d.Errf("invalid response_buffer_size '%s'", d.Val())
+				}
+				c.ResponseBufferSize = int64(size)
+			case "response_buffer_overflow":
+				if !d.Args(&c.ResponseBufferOverflow) || d.NextArg() {
+					return d.ArgErr()
+				}
+				if c.ResponseBufferOverflow != bufferOverflowFile && c.ResponseBufferOverflow != bufferOverflowStream {
+					return d.Errf("response_buffer_overflow must be file or stream")
+				}
+			case "max_request_body_size":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				size, err := humanize.ParseBytes(d.Val())
+				if err != nil || size == 0 {
+					return d.Errf("invalid max_request_body_size '%s'", d.Val())
+				}
+				c.MaxRequestBodySize = int64(size)
+			case "relay_expect_continue":
+				v, err := parseOnOff(d, "relay_expect_continue")
+				if err != nil {
+					return err
+				}
+				c.RelayExpectContinue = v
+			case "access_log_backend_latency":
+				v, err := parseOnOff(d, "access_log_backend_latency")
+				if err != nil {
+					return err
+				}
+				c.AccessLogBackendLatency = v
+			case "cache_ttl_ms":
+				v, err := parsePositiveMilliseconds(d, "cache_ttl_ms")
+				if err != nil {
+					return err
+				}
+				c.CacheTTLMS = v
+			case "cache_max_size":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				size, err := humanize.ParseBytes(d.Val())
+				if err != nil || size == 0 {
+					return d.Errf("invalid cache_max_size '%s'", d.Val())
+				}
+				c.CacheMaxSize = int64(size)
+			case "allowed_ips":
+				entries := d.RemainingArgs()
+				if len(entries) == 0 {
+					return d.ArgErr()
+				}
+				for _, entry := range entries {
+					if _, err := parseAllowedIP(entry); err != nil {
+						return d.Err(err.Error())
+					}
+				}
+				c.AllowedIPs = append(c.AllowedIPs, entries...)
+			case "rate_limit":
+				if !d.Args(&c.RateLimit) {
+					return d.ArgErr()
+				}
+				if _, err := parseRateLimit(c.RateLimit); err != nil {
+					return d.Err(err.Error())
+				}
+			case "circuit_breaker_threshold":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				v, err := strconv.Atoi(d.Val())
+				if err != nil || v <= 0 {
+					return d.Errf("circuit_breaker_threshold must be a positive integer")
+				}
+				c.CircuitBreakerThreshold = v
+			case "circuit_breaker_open_duration_ms":
+				v, err := parsePositiveMilliseconds(d, "circuit_breaker_open_duration_ms")
+				if err != nil {
+					return err
+				}
+				c.CircuitBreakerOpenDurationMS = v
+			case "watch_file":
+				var path string
+				if !d.Args(&path) {
+					return d.ArgErr()
+				}
+				c.WatchFiles = append(c.WatchFiles, path)
+			case "restart_schedule":
+				if !d.Args(&c.RestartSchedule) {
+					return d.ArgErr()
+				}
+				if _, err := parseRestartSchedule(c.RestartSchedule); err != nil {
+					return d.Err(err.Error())
+				}
+			case "restart_drain_timeout_ms":
+				v, err := parsePositiveMilliseconds(d, "restart_drain_timeout_ms")
+				if err != nil {
+					return err
+				}
+				c.RestartDrainTimeoutMS = v
+			case "tracing_headers":
+				v, err := parseOnOff(d, "tracing_headers")
+				if err != nil {
+					return err
+				}
+				c.TracingHeaders = &v
+			case "request_id_header":
+				if !d.Args(&c.RequestIDHeader) {
+					return d.ArgErr()
+				}
+			case "request_log":
+				if !d.Args(&c.RequestLog) {
+					return d.ArgErr()
+				}
+			case "decompress_response":
+				v, err := parseOnOff(d, "decompress_response")
+				if err != nil {
+					return err
+				}
+				c.DecompressResponse = v
+			case "startup_command":
+				c.StartupCommand = d.RemainingArgs()
+				if len(c.StartupCommand) == 0 {
+					return d.ArgErr()
+				}
+			case "shutdown_command":
+				c.ShutdownCommand = d.RemainingArgs()
+				if len(c.ShutdownCommand) == 0 {
+					return d.ArgErr()
+				}
+			case "shutdown_command_timeout_ms":
+				v, err := parsePositiveMilliseconds(d, "shutdown_command_timeout_ms")
+				if err != nil {
+					return err
+				}
+				c.ShutdownCommandTimeoutMS = v
+			case "lifecycle_hook":
+				c.LifecycleHook = d.RemainingArgs()
+				if len(c.LifecycleHook) == 0 {
+					return d.ArgErr()
+				}
+			case "cgroup_path":
+				if !d.Args(&c.CgroupPath) {
+					return d.ArgErr()
+				}
+			case "route":
+				args := d.RemainingArgs()
+				if len(args) < 3 || args[1] != "exec" {
+					return d.Errf("route requires a path followed by exec and a command")
+				}
+				c.Routes = append(c.Routes, PathRoute{Path: args[0], Executable: args[2:]})
+			case "content_type_route":
+				args := d.RemainingArgs()
+				if len(args) < 3 || args[1] != "exec" {
+					return d.Errf("content_type_route requires a content type followed by exec and a command")
+				}
+				c.Routes = append(c.Routes, PathRoute{ContentType: args[0], Executable: args[2:]})
+			case "status_page_path":
+				if !d.Args(&c.StatusPagePath) {
+					return d.ArgErr()
+				}
+			case "status_page_secret":
+				if !d.Args(&c.StatusPageSecret) {
+					return d.ArgErr()
+				}
+			case "health_addr":
+				if !d.Args(&c.HealthAddr) {
+					return d.ArgErr()
+				}
+			case "per_request_process":
+				v, err := parseOnOff(d, "per_request_process")
+				if err != nil {
+					return err
+				}
+				c.PerRequestProcess = v
+			case "socket_path_rotate":
+				v, err := parseOnOff(d, "socket_path_rotate")
+				if err != nil {
+					return err
+				}
+				c.SocketPathRotate = v
+			case "hot_config_reload":
+				v, err := parseOnOff(d, "hot_config_reload")
+				if err != nil {
+					return err
+				}
+				c.HotConfigReload = v
+			case "inspect":
+				v, err := parseOnOff(d, "inspect")
+				if err != nil {
+					return err
+				}
+				c.Inspect = v
+			case "socket_type":
+				if !d.Args(&c.SocketType) {
+					return d.ArgErr()
+				}
+				if c.SocketType != socketTypeFilesystem && c.SocketType != socketTypeAbstract {
+					return d.Errf("socket_type must be filesystem or abstract")
+				}
+			case "socket_template":
+				if !d.Args(&c.SocketTemplate) {
+					return d.ArgErr()
+				}
+			case "socket_mode":
+				if !d.Args(&c.SocketMode) {
+					return d.ArgErr()
+				}
+			case "socket_group":
+				if !d.Args(&c.SocketGroup) {
+					return d.ArgErr()
+				}
+			case "socket_activation":
+				index := 0
+				if d.NextArg() {
+					v, err := strconv.Atoi(d.Val())
+					if err != nil || v < 0 {
+						return d.Errf("socket_activation index must be a non-negative integer")
+					}
+					index = v
+				}
+				if d.NextArg() {
+					return d.ArgErr()
+				}
+				c.SocketActivation = &index
+			case "socket_cleanup_on_start":
+				v, err := parseOnOff(d, "socket_cleanup_on_start")
+				if err != nil {
+					return err
+				}
+				c.SocketCleanupOnStart = &v
+			case "socket_wait_timeout_ms":
+				v, err := parsePositiveMilliseconds(d, "socket_wait_timeout_ms")
+				if err != nil {
+					return err
+				}
+				c.SocketWaitTimeoutMS = v
+			case "socket_poll_interval_ms":
+				v, err := parsePositiveMilliseconds(d, "socket_poll_interval_ms")
+				if err != nil {
+					return err
+				}
+				c.SocketPollIntervalMS = v
+			case "proxy_headers_from_request":
+				names := d.RemainingArgs()
+				if len(names) == 0 {
+					return d.ArgErr()
+				}
+				c.ProxyHeadersFromRequest = append(c.ProxyHeadersFromRequest, names...)
+			case "upstream_header_add":
+				var name, value string
+				if !d.Args(&name, &value) {
+					return d.ArgErr()
+				}
+				if c.UpstreamHeaders == nil {
+					c.UpstreamHeaders = &headers.HeaderOps{Add: http.Header{}}
+				}
+				c.UpstreamHeaders.Add.Add(name, value)
+			case "response_header_add", "response_header_set":
+				op := d.Val()
+				var name, value string
+				if !d.Args(&name, &value) {
+					return d.ArgErr()
+				}
+				if c.ResponseHeaders == nil {
+					c.ResponseHeaders = &headers.HeaderOps{}
+				}
+				if op == "response_header_add" {
+					if c.ResponseHeaders.Add == nil {
+						c.ResponseHeaders.Add = http.Header{}
+					}
+					c.ResponseHeaders.Add.Add(name, value)
+				} else {
+					if c.ResponseHeaders.Set == nil {
+						c.ResponseHeaders.Set = http.Header{}
+					}
+					c.ResponseHeaders.Set.Set(name, value)
+				}
+			case "response_header_delete":
+				var name string
+				if !d.Args(&name) {
+					return d.ArgErr()
+				}
+				if c.ResponseHeaders == nil {
+					c.ResponseHeaders = &headers.HeaderOps{}
+				}
+				c.ResponseHeaders.Delete = append(c.ResponseHeaders.Delete, name)
+			case "backend_status_override":
+				mappings := d.RemainingArgs()
+				if len(mappings) == 0 {
+					return d.ArgErr()
+				}
+				for _, m := range mappings {
+					from, to, err := parseStatusOverride(m)
+					if err != nil {
+						return d.Errf("backend_status_override: %v", err)
+					}
+					if c.StatusOverrides == nil {
+						c.StatusOverrides = map[int]int{}
+					}
+					c.StatusOverrides[from] = to
+				}
+			case "error_body_format":
+				if !d.Args(&c.ErrorBodyFormat) || d.NextArg() {
+					return d.ArgErr()
+				}
+				if c.ErrorBodyFormat != errorBodyJSON && c.ErrorBodyFormat != errorBodyHTML {
+					return d.Errf("error_body_format must be json or html")
+				}
+			case "response_rewrite":
+				var rw ResponseRewrite
+				if !d.Args(&rw.Find, &rw.Replace) || d.NextArg() {
+					return d.ArgErr()
+				}
+				if _, err := regexp.Compile(rw.Find); err != nil {
+					return d.Errf("response_rewrite: %v", err)
+				}
+				c.ResponseRewrites = append(c.ResponseRewrites, rw)
+			case "run_as":
+				if !d.Args(&c.RunAs) {
+					return d.ArgErr()
+				}
+			case "max_memory":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				size, err := humanize.ParseBytes(d.Val())
+				if err != nil || size == 0 {
+					return d.Errf("invalid max_memory '%s'", d.Val())
+				}
+				c.MaxMemory = int64(size)
+			case "cpu_shares":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				v, err := strconv.Atoi(d.Val())
+				if err != nil || v < 2 || v > 262144 {
+					return d.Errf("cpu_shares must be an integer between 2 and 262144")
+				}
+				c.CPUShares = v
+			case "process_namespace":
+				var list string
+				if !d.Args(&list) {
+					return d.ArgErr()
+				}
+				c.ProcessNamespaces = strings.Split(list, ",")
+			case "backend_proto":
+				if !d.Args(&c.BackendProto) {
+					return d.ArgErr()
+				}
+				if !validBackendProto(c.BackendProto) {
+					return d.Errf("backend_proto must be one of: %s", strings.Join(backendProtos, ", "))
+				}
+			case "proxy_protocol":
+				if !d.Args(&c.ProxyProtocol) {
+					return d.ArgErr()
+				}
+				if !validProxyProtocol(c.ProxyProtocol) {
+					return d.Errf("proxy_protocol must be v1 or v2")
+				}
+			case "backend_connect_timeout_ms":
+				v, err := parsePositiveMilliseconds(d, "backend_connect_timeout_ms")
+				if err != nil {
+					return err
+				}
+				c.BackendConnectTimeoutMS = v
+			case "keepalive":
+				v, err := parseOnOff(d, "keepalive")
+				if err != nil {
+					return err
+				}
+				c.Keepalive = &v
+			case "keepalive_idle_ms":
+				v, err := parsePositiveMilliseconds(d, "keepalive_idle_ms")
+				if err != nil {
+					return err
+				}
+				c.KeepaliveIdleMS = v
+			case "keepalive_interval_ms":
+				v, err := parsePositiveMilliseconds(d, "keepalive_interval_ms")
+				if err != nil {
+					return err
+				}
+				c.KeepaliveIntervalMS = v
+			case "keepalive_pool_size":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				v, err := strconv.Atoi(d.Val())
+				if err != nil || v <= 0 {
+					return d.Errf("keepalive_pool_size must be a positive integer")
+				}
+				c.KeepalivePoolSize = v
+			case "backend_max_conns":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				v, err := strconv.Atoi(d.Val())
+				if err != nil || v <= 0 {
+					return d.Errf("backend_max_conns must be a positive integer")
+				}
+				c.BackendMaxConns = v
+			case "backend_tls":
+				bt, err := parseBackendTLS(d)
+				if err != nil {
+					return err
+				}
+				c.BackendTLS = bt
+			case "backend_follow_redirects":
+				v, err := parseOnOff(d, "backend_follow_redirects")
+				if err != nil {
+					return err
+				}
+				c.BackendFollowRedirects = v
+			case "max_redirects":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				v, err := strconv.Atoi(d.Val())
+				if err != nil || v <= 0 {
+					return d.Errf("max_redirects must be a positive integer")
+				}
+				c.BackendMaxRedirects = v
+			case "retry_on_failure":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				v, err := strconv.Atoi(d.Val())
+				if err != nil || v <= 0 {
+					return d.Errf("retry_on_failure must be a positive integer")
+				}
+				c.RetryOnFailure = v
+			case "retry_unsafe_methods":
+				c.RetryUnsafeMethods = true
 			default:
 				return d.Errf("unknown subdirective: %q", d.Val())
 			}
@@ -213,51 +1054,297 @@ func (c *ReverseBin) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 func (c *ReverseBin) Provision(ctx caddy.Context) error {
 	c.ctx = ctx
 	c.logger = ctx.Logger(c)
+	c.provisionedAt = time.Now()
+	if app, err := ctx.AppIfConfigured("reverse_bin"); err == nil {
+		c.app = app.(*App)
+		c.app.applyDefaults(c)
+	} else if !errors.Is(err, caddy.ErrNotConfigured) {
+		return err
+	}
 	c.processes = make(map[string]*processState)
+	c.streamDetectors = make(map[string]*streamDetector)
 
 	c.logger.Info("reverse-bin module provisioned",
 		zap.String("version", Version),
 		zap.String("commit", Commit),
 		zap.String("build_date", BuildDate))
 
-	if len(c.DynamicProxyDetector) == 0 {
+	if len(c.DynamicProxyDetector) > 0 && len(c.DynamicProxyDetectorHTTP) > 0 {
+		return fmt.Errorf("dynamic_proxy_detector and dynamic_proxy_detector_http are mutually exclusive")
+	}
+	if len(c.DynamicProxyDetectorHTTP) != 0 && len(c.DynamicProxyDetectorHTTP) != 2 {
+		return fmt.Errorf("dynamic_proxy_detector_http requires a method and a URL")
+	}
+	if isBuiltinDetector(c.DynamicProxyDetector) {
+		if err := validateBuiltinDetector(c.DynamicProxyDetector); err != nil {
+			return err
+		}
+	}
+	switch c.DetectorMode {
+	case "", "once":
+	case "stream":
+		if len(c.DynamicProxyDetector) == 0 || isBuiltinDetector(c.DynamicProxyDetector) {
+			return fmt.Errorf("detector_mode stream requires a dynamic_proxy_detector command")
+		}
+	default:
+		return fmt.Errorf("detector_mode must be once or stream, got %q", c.DetectorMode)
+	}
+	if c.SocketActivation != nil {
+		if c.hasDetector() || c.ReverseProxyTo != "" || c.SocketTemplate != "" || len(c.Routes) > 0 {
+			return fmt.Errorf("socket_activation cannot be combined with reverse_proxy_to, socket_template, route or a detector")
+		}
+		activation, err := inheritActivationSocket(*c.SocketActivation)
+		if err != nil {
+			return err
+		}
+		c.activation = activation
+		c.ReverseProxyTo = activation.upstream
+	}
+	if err := c.provisionStdio(); err != nil {
+		return err
+	}
+	if err := c.provisionDocker(); err != nil {
+		return err
+	}
+	if !c.hasDetector() && !c.StdioMode {
 		if len(c.Executable) == 0 {
 			return fmt.Errorf("exec (executable) is required when dynamic_proxy_detector is not set")
 		}
 
-		if c.ReverseProxyTo == "" {
+		if c.ReverseProxyTo == "" && c.SocketTemplate == "" && c.PortDiscoveryPattern == "" && c.StartupAddressPattern == "" {
 			return fmt.Errorf("reverse_proxy_to is required when dynamic_proxy_detector is not set")
 		}
 	}
+	if _, err := c.checkSharedSocket(); err != nil {
+		return err
+	}
+	if err := c.provisionRoutes(); err != nil {
+		return err
+	}
+	if err := c.provisionPerRequest(); err != nil {
+		return err
+	}
+	if err := c.provisionSocketRotate(); err != nil {
+		return err
+	}
+	if err := c.provisionStartupAction(); err != nil {
+		return err
+	}
+	switch c.ResponseBufferOverflow {
+	case "":
+	case bufferOverflowFile, bufferOverflowStream:
+		if c.ResponseBufferSize <= 0 {
+			return fmt.Errorf("response_buffer_overflow requires response_buffer_size")
+		}
+	default:
+		return fmt.Errorf("response_buffer_overflow must be file or stream, got %q", c.ResponseBufferOverflow)
+	}
+	switch c.ErrorBodyFormat {
+	case "", errorBodyJSON, errorBodyHTML:
+	default:
+		return fmt.Errorf("error_body_format must be json or html, got %q", c.ErrorBodyFormat)
+	}
+	if c.RedactEnvPattern != "" {
+		re, err := regexp.Compile(c.RedactEnvPattern)
+		if err != nil {
+			return fmt.Errorf("redact_env_pattern: %v", err)
+		}
+		c.redactPattern = re
+	}
+	if err := c.provisionPortDiscovery(); err != nil {
+		return err
+	}
+	if c.SocketTemplate != "" {
+		if c.ReverseProxyTo != "" {
+			return fmt.Errorf("socket_template and reverse_proxy_to are mutually exclusive")
+		}
+		if !isUnixUpstream(c.SocketTemplate) {
+			return fmt.Errorf("socket_template must be a unix/ address")
+		}
+	}
+	if c.ReverseProxyToSecondary != "" && !isUnixUpstream(c.ReverseProxyToSecondary) {
+		return fmt.Errorf("reverse_proxy_to_secondary must be a unix/ address")
+	}
+
+	if c.WorkingDirectory != "" {
+		info, err := os.Stat(c.WorkingDirectory)
+		if err != nil {
+			return fmt.Errorf("dir: %v", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("dir %s is not a directory", c.WorkingDirectory)
+		}
+	}
 
+	if c.BackendProto == "" {
+		c.BackendProto = backendProtoHTTP
+	}
+	if !validBackendProto(c.BackendProto) {
+		return fmt.Errorf("backend_proto must be one of: %s", strings.Join(backendProtos, ", "))
+	}
+	if c.BackendTLS != nil {
+		if c.BackendProto != backendProtoHTTP {
+			return fmt.Errorf("backend_tls requires backend_proto http")
+		}
+		if (c.BackendTLS.Cert == "") != (c.BackendTLS.Key == "") {
+			return fmt.Errorf("backend_tls cert and key must be set together")
+		}
+	}
+	if (c.Keepalive != nil || c.KeepaliveIdleMS > 0 || c.KeepaliveIntervalMS > 0 || c.KeepalivePoolSize > 0) && c.BackendProto != backendProtoHTTP {
+		return fmt.Errorf("keepalive settings require backend_proto http")
+	}
+	if c.BackendMaxConns > 0 && c.BackendProto != backendProtoHTTP {
+		return fmt.Errorf("backend_max_conns requires backend_proto http")
+	}
+	if c.ProxyProtocol != "" {
+		if !validProxyProtocol(c.ProxyProtocol) {
+			return fmt.Errorf("proxy_protocol must be v1 or v2")
+		}
+		if c.BackendProto != backendProtoHTTP {
+			return fmt.Errorf("proxy_protocol requires backend_proto http")
+		}
+	}
+	if err := c.provisionSocketPermissions(); err != nil {
+		return err
+	}
+	if c.RunAs != "" {
+		runAs, err := lookupRunAs(c.RunAs)
+		if err != nil {
+			return err
+		}
+		c.runAs = runAs
+	}
+	if err := checkResourceLimits(c.MaxMemory, c.CPUShares); err != nil {
+		return err
+	}
+	if c.CgroupPath != "" && !cgroupSupported {
+		c.logger.Warn("cgroup_path is only supported on Linux; ignoring it", zap.String("cgroup_path", c.CgroupPath))
+	}
+	cloneflags, err := processNamespaceFlags(c.ProcessNamespaces)
+	if err != nil {
+		return err
+	}
+	c.cloneflags = cloneflags
 	if c.HealthMethod != "" {
 		c.HealthMethod = strings.ToUpper(c.HealthMethod)
 	}
+	c.provisionLiveness()
 	if c.IdleTimeoutMS <= 0 {
 		c.IdleTimeoutMS = defaultIdleTimeoutMS
 	}
 	if c.HealthTimeoutMS <= 0 {
 		c.HealthTimeoutMS = defaultHealthTimeoutMS
 	}
+	if c.BackendMaxRedirects <= 0 {
+		c.BackendMaxRedirects = defaultBackendMaxRedirects
+	}
+	if c.DetectorHTTPTimeoutMS <= 0 {
+		c.DetectorHTTPTimeoutMS = c.HealthTimeoutMS
+	}
 	if c.TerminationGraceMS <= 0 {
 		c.TerminationGraceMS = defaultTerminationGraceMS
 	}
 	if c.TerminationKillWaitMS <= 0 {
 		c.TerminationKillWaitMS = defaultTerminationKillWaitMS
 	}
+	if c.ShutdownCommandTimeoutMS <= 0 {
+		c.ShutdownCommandTimeoutMS = defaultShutdownCommandMS
+	}
+	if c.StatusPagePath != "" && c.StatusPageSecret == "" {
+		return fmt.Errorf("status_page_path requires status_page_secret")
+	}
+	for _, entry := range c.AllowedIPs {
+		prefix, err := parseAllowedIP(entry)
+		if err != nil {
+			return err
+		}
+		c.allowedNets = append(c.allowedNets, prefix)
+	}
+	if c.RateLimit != "" {
+		limiter, err := parseRateLimit(c.RateLimit)
+		if err != nil {
+			return err
+		}
+		c.rateLimiter = limiter
+	}
+	if c.CircuitBreakerThreshold > 0 {
+		if c.CircuitBreakerOpenDurationMS <= 0 {
+			c.CircuitBreakerOpenDurationMS = defaultCircuitBreakerOpenMS
+		}
+		c.breaker = newCircuitBreaker(c.CircuitBreakerThreshold, c.circuitBreakerOpenDuration())
+	}
+	if c.CacheTTLMS > 0 {
+		if c.CacheMaxSize <= 0 {
+			c.CacheMaxSize = defaultCacheMaxSize
+		}
+		c.cache = newResponseCache(c.cacheTTL(), c.CacheMaxSize)
+	}
 
 	if !isUnixUpstream(c.ReverseProxyTo) && c.ReverseProxyTo != "" && !healthConfigured(c.HealthMethod, c.HealthPath) {
 		return fmt.Errorf("health_check is required for non-unix reverse_proxy_to targets")
 	}
 
+	transport, err := c.newTransport(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to provision backend transport: %v", err)
+	}
+	c.transport = transport
 	rp := &reverseproxy.Handler{
 		DynamicUpstreams: c,
+		Transport:        transport,
+	}
+	if c.UpstreamHeaders != nil || c.ResponseHeaders != nil {
+		rp.Headers = &headers.Handler{Request: c.UpstreamHeaders}
+		if c.ResponseHeaders != nil {
+			rp.Headers.Response = &headers.RespHeaderOps{HeaderOps: c.ResponseHeaders}
+		}
+	}
+	if c.ReverseProxyToSecondary != "" {
+		// Try the primary first and retry a failed dial once; the
+		// passive failure count steers the retry to the secondary.
+		rp.LoadBalancing = &reverseproxy.LoadBalancing{
+			SelectionPolicy: reverseproxy.FirstSelection{},
+			Retries:         1,
+		}
+		rp.HealthChecks = &reverseproxy.HealthChecks{
+			Passive: &reverseproxy.PassiveHealthChecks{FailDuration: caddy.Duration(secondaryFailDuration)},
+		}
+	}
+	if c.RetryOnFailure > 0 {
+		if rp.LoadBalancing == nil {
+			rp.LoadBalancing = &reverseproxy.LoadBalancing{}
+		}
+		rp.LoadBalancing.Retries += c.RetryOnFailure
+		rp.LoadBalancing.RetryMatch = c.retryMatch()
 	}
 	if err := rp.Provision(ctx); err != nil {
 		return fmt.Errorf("failed to provision reverse proxy: %v", err)
 	}
 	c.reverseProxy = rp
 
+	if err := c.startFileWatcher(); err != nil {
+		return err
+	}
+	if err := c.startRestartSchedule(); err != nil {
+		return err
+	}
+	if c.app.dryRun() {
+		if err := c.checkCommands(); err != nil {
+			return err
+		}
+	}
+	return c.startHealthServer()
+}
+
+// Validate implements caddy.Validator; it rejects configurations that
+// would silently drop settings.
+func (c *ReverseBin) Validate() error {
+	if key, dup := duplicateEnvKey(c.Envs); dup {
+		return fmt.Errorf("env %s is set more than once", key)
+	}
+	if key, dup := duplicateEnvKey(c.DetectorEnvs); dup {
+		return fmt.Errorf("detector_env %s is set more than once", key)
+	}
 	return nil
 }
 
@@ -294,6 +1381,7 @@ func (c *ReverseBin) Cleanup() error {
 			firstErr = err
 		}
 	}
+	c.closeRequestLogs()
 	return firstErr
 }
 
@@ -0,0 +1,41 @@
+package reversebin
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// requestUUIDPlaceholder gives every request its own socket_template
+// expansion, and so its own process key, under per_request_process.
+const requestUUIDPlaceholder = "{http.request.uuid}"
+
+// provisionPerRequest checks per_request_process, which launches a fresh
+// backend for every request on a socket of its own.
+func (c *ReverseBin) provisionPerRequest() error {
+	if !c.PerRequestProcess {
+		return nil
+	}
+	if !strings.Contains(c.SocketTemplate, requestUUIDPlaceholder) {
+		return fmt.Errorf("per_request_process requires a socket_template containing %s", requestUUIDPlaceholder)
+	}
+	if c.hasDetector() || len(c.Routes) > 0 || c.HotConfigReload {
+		return fmt.Errorf("per_request_process cannot be combined with a detector, route or hot_config_reload")
+	}
+	return nil
+}
+
+// finishPerRequest stops the backend that served ps's only request, removes
+// its socket and forgets ps, whose supervisor then exits.
+func (c *ReverseBin) finishPerRequest(ps *processState, rb *runningBackend) {
+	if rb != nil {
+		_ = c.stopBackend(rb, "per-request process done", c.terminationGrace())
+		if err := removeStaleSocket(rb.config); err != nil {
+			c.logger.Warn("failed to remove per-request socket", zap.String("key", ps.key), zap.Error(err))
+		}
+	}
+	c.mu.Lock()
+	delete(c.processes, ps.key)
+	c.mu.Unlock()
+}
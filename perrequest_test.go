@@ -0,0 +1,78 @@
+package reversebin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestPerRequestProcessLaunchesAndCleansUpPerRequest verifies each request gets a fresh backend that is stopped, and its socket removed, once the request is done.
+func TestPerRequestProcessLaunchesAndCleansUpPerRequest(t *testing.T) {
+	f := useMockProcesses(t, "", http.NotFoundHandler())
+	// t.TempDir is too long for a socket path with a UUID in it.
+	dir, err := os.MkdirTemp("", "rb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	sock := filepath.Join(dir, "app-"+requestUUIDPlaceholder+".sock")
+	c := &ReverseBin{
+		Executable:            []string{"./app", sock},
+		SocketTemplate:        "unix/" + sock,
+		PerRequestProcess:     true,
+		IdleTimeoutMS:         60000,
+		HealthTimeoutMS:       2000,
+		TerminationGraceMS:    1000,
+		TerminationKillWaitMS: 1000,
+		processes:             map[string]*processState{},
+		logger:                zaptest.NewLogger(t),
+	}
+	if err := c.provisionPerRequest(); err != nil {
+		t.Fatalf("provision: %v", err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		// This HTTP request tests one request's trip through the supervisor, as ServeHTTP drives it.
+		req := caddyhttp.PrepareRequest(httptest.NewRequest(http.MethodGet, "http://app.example/", nil), caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+		ps := c.getOrCreateProcessState(c.getProcessKey(req))
+		if err := c.sendSupervisorCommand(ps, supervisorRequestStarted, "request started"); err != nil {
+			t.Fatal(err)
+		}
+		upstreams, err := c.GetUpstreams(req)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		path := strings.TrimPrefix(upstreams[0].Dial, "unix/")
+		if err := c.sendSupervisorCommand(ps, supervisorRequestDone, "request done"); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := f.starts.Load(); n != int32(i) {
+			t.Fatalf("request %d: expected %d launches, got %d", i, i, n)
+		}
+		select {
+		case <-f.started[i-1].exited:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("request %d: expected its backend to be stopped", i)
+		}
+		// The supervisor finishes up after replying; give it a moment.
+		time.Sleep(50 * time.Millisecond)
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("request %d: expected socket %s to be removed, got %v", i, path, err)
+		}
+		c.mu.Lock()
+		n := len(c.processes)
+		c.mu.Unlock()
+		if n != 0 {
+			t.Fatalf("request %d: expected no process state left, got %d", i, n)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package reversebin
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// defaultPortDiscoveryBytes is how much stdout is scanned for the port when
+// port_discovery_pattern sets no limit.
+const defaultPortDiscoveryBytes = 4096
+
+// provisionPortDiscovery compiles port_discovery_pattern or
+// startup_address_pattern.
+func (c *ReverseBin) provisionPortDiscovery() error {
+	if c.PortDiscoveryPattern != "" && c.StartupAddressPattern != "" {
+		return fmt.Errorf("port_discovery_pattern and startup_address_pattern are mutually exclusive")
+	}
+	name, pattern, maxBytes := c.discoveryPattern()
+	if pattern == "" {
+		return nil
+	}
+	if c.ReverseProxyTo != "" || c.SocketTemplate != "" || c.SocketActivation != nil {
+		return fmt.Errorf("%s cannot be combined with reverse_proxy_to, socket_template or socket_activation", name)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	if re.NumSubexp() < 1 {
+		return fmt.Errorf("%s needs a capture group for the %s", name, c.discoveredWhat())
+	}
+	if *maxBytes <= 0 {
+		*maxBytes = defaultPortDiscoveryBytes
+	}
+	c.portPattern = re
+	return nil
+}
+
+// discoveryPattern returns whichever of port_discovery_pattern and
+// startup_address_pattern is set, with its byte limit.
+func (c *ReverseBin) discoveryPattern() (name, pattern string, maxBytes *int64) {
+	if c.StartupAddressPattern != "" {
+		return "startup_address_pattern", c.StartupAddressPattern, &c.StartupAddressMaxBytes
+	}
+	return "port_discovery_pattern", c.PortDiscoveryPattern, &c.PortDiscoveryMaxBytes
+}
+
+// discoveredWhat names what the pattern's capture group holds.
+func (c *ReverseBin) discoveredWhat() string {
+	if c.StartupAddressPattern != "" {
+		return "socket path"
+	}
+	return "port"
+}
+
+// discoversPort reports whether cfg's upstream is found by scanning the
+// backend's stdout: routes and detectors that name an upstream keep it.
+func (c *ReverseBin) discoversPort(cfg resolvedConfig) bool {
+	return c.portPattern != nil && cfg.ReverseProxyTo == ""
+}
+
+// portScanner looks for the port in the first lines of a backend's stdout.
+// Only the stdout goroutine calls scan and finish.
+type portScanner struct {
+	pattern   *regexp.Regexp
+	remaining int64
+	// port receives the port, or "" once the scanner gives up
+	port chan string
+	done bool
+}
+
+func newPortScanner(pattern *regexp.Regexp, maxBytes int64) *portScanner {
+	return &portScanner{pattern: pattern, remaining: maxBytes, port: make(chan string, 1)}
+}
+
+// scan checks one line of stdout for the port.
+func (s *portScanner) scan(line string) {
+	if s == nil || s.done {
+		return
+	}
+	if m := s.pattern.FindStringSubmatch(line); m != nil {
+		s.port <- m[1]
+		s.done = true
+		return
+	}
+	s.remaining -= int64(len(line)) + 1
+	if s.remaining <= 0 {
+		s.finish()
+	}
+}
+
+// finish gives up on a port that has not been found yet.
+func (s *portScanner) finish() {
+	if s == nil || s.done {
+		return
+	}
+	s.port <- ""
+	s.done = true
+}
+
+// awaitPort waits for rb to report its port, or with startup_address_pattern
+// its unix socket, on stdout and returns the upstream to proxy to.
+func (c *ReverseBin) awaitPort(ctx context.Context, rb *runningBackend) (string, error) {
+	name, pattern, maxBytes := c.discoveryPattern()
+	select {
+	case found := <-rb.port:
+		if found == "" {
+			return "", fmt.Errorf("backend stdout did not match %s %q in its first %d bytes", name, pattern, *maxBytes)
+		}
+		if c.StartupAddressPattern != "" {
+			// A relative path is relative to the backend's dir.
+			if !filepath.IsAbs(found) && !isAbstractSocket(found) && rb.config.WorkingDirectory != "" {
+				found = filepath.Join(rb.config.WorkingDirectory, found)
+			}
+			return "unix/" + found, nil
+		}
+		n, err := strconv.Atoi(found)
+		if err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("port_discovery_pattern matched %q, which is not a port", found)
+		}
+		return net.JoinHostPort("127.0.0.1", found), nil
+	case err := <-rb.done:
+		rb.done <- err
+		return "", fmt.Errorf("reverse proxy process exited before reporting its %s: %v", c.discoveredWhat(), err)
+	case <-ctx.Done():
+		return "", fmt.Errorf("backend did not report its %s before the health timeout: %w", c.discoveredWhat(), ctx.Err())
+	}
+}
+
+// isTCPPortOpen is the readiness check for a discovered port when no
+// health_check is configured.
+func isTCPPortOpen(addr string) bool {
+	conn, err := net.DialTimeout("tcp", addr, 500*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
@@ -0,0 +1,49 @@
+package reversebin
+
+import (
+	"context"
+	"regexp"
+	"testing"
+)
+
+// TestPortScannerStopsAfterByteLimit verifies the port is only taken from the first bytes of stdout.
+func TestPortScannerStopsAfterByteLimit(t *testing.T) {
+	pattern := regexp.MustCompile(`Listening on :(\d+)`)
+
+	s := newPortScanner(pattern, 64)
+	s.scan("starting up")
+	s.scan("Listening on :34521")
+	if got := <-s.port; got != "34521" {
+		t.Fatalf("expected port 34521, got %q", got)
+	}
+
+	s = newPortScanner(pattern, 16)
+	s.scan("a long banner line past the limit")
+	s.scan("Listening on :34521")
+	if got := <-s.port; got != "" {
+		t.Fatalf("expected the scanner to give up, got %q", got)
+	}
+}
+
+// TestAwaitPortStartupAddressPattern verifies a socket path printed by the backend becomes a unix upstream, relative to the backend's dir.
+func TestAwaitPortStartupAddressPattern(t *testing.T) {
+	c := &ReverseBin{StartupAddressPattern: `Listening on unix:(.+)`}
+	if err := c.provisionPortDiscovery(); err != nil {
+		t.Fatalf("provision: %v", err)
+	}
+	for _, tc := range []struct{ printed, want string }{
+		{"/tmp/app-123.sock", "unix//tmp/app-123.sock"},
+		{"run/app.sock", "unix//srv/app/run/app.sock"},
+	} {
+		s := newPortScanner(c.portPattern, c.StartupAddressMaxBytes)
+		s.scan("Listening on unix:" + tc.printed)
+		rb := &runningBackend{port: s.port, done: make(chan error, 1), config: resolvedConfig{WorkingDirectory: "/srv/app"}}
+		got, err := c.awaitPort(context.Background(), rb)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.printed, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: expected upstream %s, got %s", tc.printed, tc.want, got)
+		}
+	}
+}
@@ -3,10 +3,19 @@
 package reversebin
 
 import (
+	"fmt"
 	"os/exec"
 	"syscall"
 )
 
+var namespaceCloneflags = map[string]uintptr{
+	"pid": syscall.CLONE_NEWPID,
+	"net": syscall.CLONE_NEWNET,
+	"mnt": syscall.CLONE_NEWNS,
+	"ipc": syscall.CLONE_NEWIPC,
+	"uts": syscall.CLONE_NEWUTS,
+}
+
 func configureDetectorProcAttrs(cmd *exec.Cmd) {
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Pdeathsig: syscall.SIGTERM,
@@ -20,3 +29,31 @@ func configureBackendProcAttrs(cmd *exec.Cmd) {
 		Pdeathsig: syscall.SIGTERM,
 	}
 }
+
+// processNamespaceFlags maps process_namespace names to clone flags.
+func processNamespaceFlags(names []string) (uintptr, error) {
+	var flags uintptr
+	for _, name := range names {
+		flag, ok := namespaceCloneflags[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown process_namespace %q (want pid, net, mnt, ipc, or uts)", name)
+		}
+		flags |= flag
+	}
+	return flags, nil
+}
+
+// setProcessNamespaces makes the backend start in new namespaces. Creating
+// them requires root (CAP_SYS_ADMIN). In a new pid namespace the backend is
+// PID 1, which ignores SIGTERM unless it handles it.
+func setProcessNamespaces(cmd *exec.Cmd, flags uintptr) {
+	cmd.SysProcAttr.Cloneflags = flags
+}
+
+// setRunAs starts the backend as cred's user and groups.
+func setRunAs(cmd *exec.Cmd, cred *runAsCredential) {
+	if cred == nil {
+		return
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: cred.uid, Gid: cred.gid, Groups: cred.groups}
+}
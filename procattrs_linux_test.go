@@ -0,0 +1,22 @@
+//go:build linux
+
+package reversebin
+
+import (
+	"syscall"
+	"testing"
+)
+
+// TestProcessNamespaceFlags verifies process_namespace names map to clone flags and unknown names are rejected.
+func TestProcessNamespaceFlags(t *testing.T) {
+	flags, err := processNamespaceFlags([]string{"pid", "net", "mnt"})
+	if err != nil {
+		t.Fatalf("processNamespaceFlags: %v", err)
+	}
+	if want := uintptr(syscall.CLONE_NEWPID | syscall.CLONE_NEWNET | syscall.CLONE_NEWNS); flags != want {
+		t.Fatalf("flags = %#x, want %#x", flags, want)
+	}
+	if _, err := processNamespaceFlags([]string{"user"}); err == nil {
+		t.Fatal("expected unknown namespace to be rejected")
+	}
+}
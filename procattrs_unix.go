@@ -3,6 +3,7 @@
 package reversebin
 
 import (
+	"fmt"
 	"os/exec"
 	"syscall"
 )
@@ -14,3 +15,20 @@ func configureDetectorProcAttrs(cmd *exec.Cmd) {
 func configureBackendProcAttrs(cmd *exec.Cmd) {
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 }
+
+func processNamespaceFlags(names []string) (uintptr, error) {
+	if len(names) > 0 {
+		return 0, fmt.Errorf("process_namespace is only supported on Linux")
+	}
+	return 0, nil
+}
+
+func setProcessNamespaces(cmd *exec.Cmd, flags uintptr) {}
+
+// setRunAs starts the backend as cred's user and groups.
+func setRunAs(cmd *exec.Cmd, cred *runAsCredential) {
+	if cred == nil {
+		return
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: cred.uid, Gid: cred.gid, Groups: cred.groups}
+}
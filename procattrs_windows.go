@@ -2,7 +2,10 @@
 
 package reversebin
 
-import "os/exec"
+import (
+	"fmt"
+	"os/exec"
+)
 
 func configureDetectorProcAttrs(cmd *exec.Cmd) {
 	if cmd == nil {
@@ -15,3 +18,14 @@ func configureBackendProcAttrs(cmd *exec.Cmd) {
 		return
 	}
 }
+
+func processNamespaceFlags(names []string) (uintptr, error) {
+	if len(names) > 0 {
+		return 0, fmt.Errorf("process_namespace is only supported on Linux")
+	}
+	return 0, nil
+}
+
+func setProcessNamespaces(cmd *exec.Cmd, flags uintptr) {}
+
+func setRunAs(cmd *exec.Cmd, cred *runAsCredential) {}
@@ -0,0 +1,61 @@
+package reversebin
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"syscall"
+)
+
+// backendProcess is a started backend as the supervisor sees it.
+type backendProcess interface {
+	Pid() int
+	// Signal sends sig to the backend's whole process group.
+	Signal(sig syscall.Signal) error
+	// Wait blocks until the backend has exited, then closes Stdout and
+	// Stderr.
+	Wait() error
+	Stdout() io.Reader
+	Stderr() io.Reader
+}
+
+// processFactory starts backends from their fully configured command. The
+// backend must stop when ctx is canceled.
+type processFactory interface {
+	Start(ctx context.Context, cmd *exec.Cmd) (backendProcess, error)
+}
+
+// backendProcesses starts every backend. Tests swap in a factory that
+// serves canned responses without spawning OS processes.
+var backendProcesses processFactory = execProcessFactory{}
+
+// execProcessFactory runs backends as OS processes. cmd is built with
+// exec.CommandContext, which already stops it when ctx is canceled.
+type execProcessFactory struct{}
+
+func (execProcessFactory) Start(_ context.Context, cmd *exec.Cmd) (backendProcess, error) {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &execProcess{cmd: cmd, stdout: stdout, stderr: stderr}, nil
+}
+
+type execProcess struct {
+	cmd    *exec.Cmd
+	stdout io.Reader
+	stderr io.Reader
+}
+
+func (p *execProcess) Pid() int                        { return p.cmd.Process.Pid }
+func (p *execProcess) Signal(sig syscall.Signal) error { return signalProcessGroup(p.cmd.Process, sig) }
+func (p *execProcess) Wait() error                     { return p.cmd.Wait() }
+func (p *execProcess) Stdout() io.Reader               { return p.stdout }
+func (p *execProcess) Stderr() io.Reader               { return p.stderr }
@@ -0,0 +1,157 @@
+package reversebin
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// mockProcessFactory starts in-process backends that serve handler on the
+// unix socket at path instead of running the configured command. With an
+// empty path, REVERSE_BIN_SOCKET or else the command's last argument names
+// the socket.
+type mockProcessFactory struct {
+	path    string
+	handler http.Handler
+	starts  atomic.Int32
+	mu      sync.Mutex
+	started []*mockProcess
+}
+
+func (f *mockProcessFactory) Start(ctx context.Context, cmd *exec.Cmd) (backendProcess, error) {
+	path := f.path
+	if path == "" {
+		path = cmd.Args[len(cmd.Args)-1]
+		for _, env := range cmd.Env {
+			if v, ok := strings.CutPrefix(env, socketEnv+"="); ok {
+				path = v
+			}
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	p := &mockProcess{
+		pid:    100000 + int(f.starts.Add(1)),
+		srv:    &http.Server{Handler: f.handler},
+		exited: make(chan struct{}),
+	}
+	go func() { _ = p.srv.Serve(ln) }()
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.exit()
+		case <-p.exited:
+		}
+	}()
+	f.mu.Lock()
+	f.started = append(f.started, p)
+	f.mu.Unlock()
+	return p, nil
+}
+
+// useMockProcesses routes backend launches to a mock factory for the rest of the test.
+func useMockProcesses(t *testing.T, path string, handler http.Handler) *mockProcessFactory {
+	f := &mockProcessFactory{path: path, handler: handler}
+	prev := backendProcesses
+	backendProcesses = f
+	t.Cleanup(func() { backendProcesses = prev })
+	return f
+}
+
+type mockProcess struct {
+	pid    int
+	srv    *http.Server
+	once   sync.Once
+	exited chan struct{}
+}
+
+func (p *mockProcess) exit() {
+	p.once.Do(func() {
+		_ = p.srv.Close()
+		close(p.exited)
+	})
+}
+
+func (p *mockProcess) Pid() int { return p.pid }
+
+// Signal treats any signal as fatal, like a backend without handlers.
+func (p *mockProcess) Signal(syscall.Signal) error {
+	p.exit()
+	return nil
+}
+
+func (p *mockProcess) Wait() error {
+	<-p.exited
+	return nil
+}
+
+func (p *mockProcess) Stdout() io.Reader { return strings.NewReader("") }
+func (p *mockProcess) Stderr() io.Reader { return strings.NewReader("") }
+
+// TestMockProcessFactoryServesAndStops verifies the supervisor launches backends through backendProcesses and stops them on cleanup.
+func TestMockProcessFactoryServesAndStops(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	f := useMockProcesses(t, sock, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "mock backend")
+	}))
+	c := &ReverseBin{
+		Executable:            []string{"/nonexistent/backend"},
+		ReverseProxyTo:        "unix/" + sock,
+		IdleTimeoutMS:         60000,
+		HealthTimeoutMS:       2000,
+		TerminationGraceMS:    1000,
+		TerminationKillWaitMS: 1000,
+		processes:             map[string]*processState{},
+		logger:                zaptest.NewLogger(t),
+	}
+
+	// This HTTP request tests that the first request launches the mock backend.
+	req := caddyhttp.PrepareRequest(httptest.NewRequest(http.MethodGet, "http://app.example/", nil), caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+	upstreams, err := c.GetUpstreams(req)
+	if err != nil || len(upstreams) != 1 || upstreams[0].Dial != "unix/"+sock {
+		t.Fatalf("expected the unix upstream, got %v, %v", upstreams, err)
+	}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	// This HTTP request tests that the launched mock answers on the socket.
+	resp, err := client.Get("http://app.example/")
+	if err != nil {
+		t.Fatalf("request to mock backend: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "mock backend" {
+		t.Fatalf("expected mock response, got %q", body)
+	}
+
+	if err := c.Cleanup(); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if n := f.starts.Load(); n != 1 {
+		t.Fatalf("expected one launch, got %d", n)
+	}
+	select {
+	case <-f.started[0].exited:
+	case <-time.After(time.Second):
+		t.Fatal("expected cleanup to stop the mock backend")
+	}
+}
@@ -0,0 +1,47 @@
+package reversebin
+
+import (
+	"net/http"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// requestHeadersVar holds the client's values of the
+// proxy_headers_from_request headers, taken before reverse_proxy rewrites
+// headers such as X-Forwarded-For on its copy of the request.
+const requestHeadersVar = "reverse_bin.request_headers"
+
+// stashRequestHeaders records r's values of the proxy_headers_from_request
+// headers for requestHeaderTransport.
+func (c *ReverseBin) stashRequestHeaders(r *http.Request) {
+	hdr := make(http.Header, len(c.ProxyHeadersFromRequest))
+	for _, name := range c.ProxyHeadersFromRequest {
+		if vals := r.Header.Values(name); len(vals) > 0 {
+			hdr[http.CanonicalHeaderKey(name)] = append([]string(nil), vals...)
+		}
+	}
+	caddyhttp.SetVar(r.Context(), requestHeadersVar, hdr)
+}
+
+// requestHeaderTransport puts the stashed client headers on every upstream
+// request, replacing whatever reverse_proxy set for them. Headers the client
+// did not send are removed.
+type requestHeaderTransport struct {
+	next  http.RoundTripper
+	names []string
+}
+
+func (t *requestHeaderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	hdr, ok := caddyhttp.GetVar(req.Context(), requestHeadersVar).(http.Header)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	for _, name := range t.names {
+		req.Header.Del(name)
+		for _, v := range hdr.Values(name) {
+			req.Header.Add(name, v)
+		}
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,40 @@
+package reversebin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// TestProxyHeadersFromRequestRestoresClientValues verifies the client's headers replace reverse_proxy's on the upstream request.
+func TestProxyHeadersFromRequestRestoresClientValues(t *testing.T) {
+	c := &ReverseBin{ProxyHeadersFromRequest: []string{"X-Forwarded-For", "x-real-ip"}}
+	// This HTTP request tests a client that sends its own X-Forwarded-For but no X-Real-Ip.
+	req := httptest.NewRequest(http.MethodGet, "http://app.example/", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.5, 198.51.100.2")
+	req = caddyhttp.PrepareRequest(req, caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+	c.stashRequestHeaders(req)
+
+	// reverse_proxy's copy of the request, with the headers it sets.
+	upstream := req.Clone(req.Context())
+	upstream.Header.Set("X-Forwarded-For", "192.0.2.1")
+	upstream.Header.Set("X-Real-Ip", "192.0.2.1")
+
+	var got http.Header
+	rt := &requestHeaderTransport{next: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		got = r.Header
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}), names: c.ProxyHeadersFromRequest}
+	if _, err := rt.RoundTrip(upstream); err != nil {
+		t.Fatal(err)
+	}
+	if xff := got.Values("X-Forwarded-For"); len(xff) != 1 || xff[0] != "203.0.113.5, 198.51.100.2" {
+		t.Fatalf("expected the client's X-Forwarded-For, got %q", xff)
+	}
+	if _, ok := got["X-Real-Ip"]; ok {
+		t.Fatalf("expected X-Real-Ip to be removed, got %q", got.Get("X-Real-Ip"))
+	}
+}
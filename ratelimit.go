@@ -0,0 +1,45 @@
+package reversebin
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"golang.org/x/time/rate"
+)
+
+// rateLimitUnits are the periods accepted after the slash in rate_limit.
+var rateLimitUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+}
+
+// parseRateLimit parses a rate_limit value such as 100/s into a token
+// bucket that refills at that rate and holds one period's worth of tokens.
+func parseRateLimit(spec string) (*rate.Limiter, error) {
+	countStr, unit, ok := strings.Cut(spec, "/")
+	count, err := strconv.Atoi(countStr)
+	per, known := rateLimitUnits[unit]
+	if !ok || err != nil || count <= 0 || !known {
+		return nil, fmt.Errorf("rate_limit must look like 100/s, 10/m or 1000/h, got %q", spec)
+	}
+	return rate.NewLimiter(rate.Limit(float64(count)/per.Seconds()), count), nil
+}
+
+// checkRateLimit takes a token for one request. When none is left it sets
+// Retry-After to the seconds until the next token and returns a 429 error.
+func (c *ReverseBin) checkRateLimit(w http.ResponseWriter) error {
+	res := c.rateLimiter.Reserve()
+	delay := res.Delay()
+	if delay == 0 {
+		return nil
+	}
+	res.Cancel()
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+	return caddyhttp.Error(http.StatusTooManyRequests, fmt.Errorf("rate_limit %s exceeded", c.RateLimit))
+}
@@ -0,0 +1,46 @@
+package reversebin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// TestParseRateLimit verifies rate_limit accepts count/unit and rejects anything else.
+func TestParseRateLimit(t *testing.T) {
+	for _, spec := range []string{"100/s", "10/m", "1000/h"} {
+		if _, err := parseRateLimit(spec); err != nil {
+			t.Fatalf("parseRateLimit(%q): %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"100", "0/s", "-1/s", "10/d", "x/s"} {
+		if _, err := parseRateLimit(spec); err == nil {
+			t.Fatalf("parseRateLimit(%q): expected an error", spec)
+		}
+	}
+}
+
+// TestCheckRateLimitRejectsWithRetryAfter verifies requests beyond the bucket get 429 and a Retry-After in whole seconds.
+func TestCheckRateLimitRejectsWithRetryAfter(t *testing.T) {
+	limiter, err := parseRateLimit("1/m")
+	if err != nil {
+		t.Fatalf("parseRateLimit: %v", err)
+	}
+	c := &ReverseBin{RateLimit: "1/m", rateLimiter: limiter}
+
+	if err := c.checkRateLimit(httptest.NewRecorder()); err != nil {
+		t.Fatalf("first request: expected to pass, got %v", err)
+	}
+	rec := httptest.NewRecorder()
+	err = c.checkRateLimit(rec)
+	var herr caddyhttp.HandlerError
+	if !errors.As(err, &herr) || herr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("second request: expected 429 handler error, got %v", err)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "60" {
+		t.Fatalf("expected Retry-After 60, got %q", got)
+	}
+}
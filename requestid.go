@@ -0,0 +1,42 @@
+package reversebin
+
+import (
+	"net/http"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// requestIDVar holds the request's ID under request_id_header, so it can
+// also be used as the {http.vars.reverse_bin.request_id} placeholder.
+const requestIDVar = "reverse_bin.request_id"
+
+// assignRequestID forwards the client's request_id_header value, or sets
+// a new UUID v4 when the client sent none.
+func (c *ReverseBin) assignRequestID(r *http.Request) {
+	id := r.Header.Get(c.RequestIDHeader)
+	if id == "" {
+		id = uuid.NewString()
+		r.Header.Set(c.RequestIDHeader, id)
+	}
+	caddyhttp.SetVar(r.Context(), requestIDVar, id)
+}
+
+// requestID returns the ID assigned to r, or "" without request_id_header.
+func requestID(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	id, _ := caddyhttp.GetVar(r.Context(), requestIDVar).(string)
+	return id
+}
+
+// requestLogger returns the logger for lines about r, which carry its
+// request ID when one was assigned.
+func (c *ReverseBin) requestLogger(r *http.Request) *zap.Logger {
+	if id := requestID(r); id != "" {
+		return c.logger.With(zap.String("request_id", id))
+	}
+	return c.logger
+}
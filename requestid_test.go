@@ -0,0 +1,31 @@
+package reversebin
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/google/uuid"
+)
+
+// TestAssignRequestID verifies a client-sent ID is forwarded unchanged and a missing one becomes a UUID v4.
+func TestAssignRequestID(t *testing.T) {
+	c := &ReverseBin{RequestIDHeader: "X-Request-Id"}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r = caddyhttp.PrepareRequest(r, caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+	r.Header.Set("X-Request-Id", "client-id")
+	c.assignRequestID(r)
+	if got := r.Header.Get("X-Request-Id"); got != "client-id" || requestID(r) != "client-id" {
+		t.Fatalf("expected client-id forwarded, got header %q and var %q", got, requestID(r))
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r = caddyhttp.PrepareRequest(r, caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+	c.assignRequestID(r)
+	id, err := uuid.Parse(r.Header.Get("X-Request-Id"))
+	if err != nil || id.Version() != 4 || requestID(r) != id.String() {
+		t.Fatalf("expected a generated UUID v4, got header %q (%v) and var %q", r.Header.Get("X-Request-Id"), err, requestID(r))
+	}
+}
@@ -0,0 +1,123 @@
+package reversebin
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap"
+)
+
+// maxOpenRequestLogs bounds how many request_log files stay open at once.
+// A path built from client-controlled placeholders such as {host} can
+// expand to any number of files; beyond this the least recently written
+// one is closed and reopened on its next use.
+const maxOpenRequestLogs = 64
+
+// requestLogFiles is an LRU of open request_log files keyed on path.
+type requestLogFiles struct {
+	lru   *list.List
+	files map[string]*list.Element
+}
+
+type requestLogFile struct {
+	path string
+	file *os.File
+}
+
+// requestLogEntry is one line of request_log.
+type requestLogEntry struct {
+	Timestamp        string `json:"ts"`
+	Method           string `json:"method"`
+	Path             string `json:"path"`
+	Status           int    `json:"status"`
+	Size             int    `json:"size"`
+	BackendLatencyMS int64  `json:"backend_latency_ms"`
+	RequestID        string `json:"request_id,omitempty"`
+}
+
+// logRequest appends r's entry to the request_log file it expands to. When
+// the proxy failed before a response was written, the status is the one
+// Caddy's error handling will send.
+func (c *ReverseBin) logRequest(r *http.Request, rec caddyhttp.ResponseRecorder, proxyErr error, backend time.Duration) {
+	status := rec.Status()
+	if status == 0 && proxyErr != nil {
+		status = http.StatusInternalServerError
+		var herr caddyhttp.HandlerError
+		if errors.As(proxyErr, &herr) && herr.StatusCode != 0 {
+			status = herr.StatusCode
+		}
+	}
+	line, err := json.Marshal(requestLogEntry{
+		Timestamp:        time.Now().UTC().Format(time.RFC3339Nano),
+		Method:           r.Method,
+		Path:             r.URL.Path,
+		Status:           status,
+		Size:             rec.Size(),
+		BackendLatencyMS: backend.Milliseconds(),
+		RequestID:        requestID(r),
+	})
+	if err != nil {
+		return
+	}
+	repl := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer)
+	path := repl.ReplaceAll(c.RequestLog, "")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, err := c.openRequestLog(path)
+	if err == nil {
+		_, err = f.Write(append(line, '\n'))
+	}
+	if err != nil {
+		c.requestLogger(r).Warn("failed to write request_log", zap.String("path", path), zap.Error(err))
+	}
+}
+
+// openRequestLog returns the open file for path, creating it and its
+// directory when it is not open already. The caller holds c.mu.
+func (c *ReverseBin) openRequestLog(path string) (*os.File, error) {
+	if c.requestLogs == nil {
+		c.requestLogs = &requestLogFiles{lru: list.New(), files: make(map[string]*list.Element)}
+	}
+	logs := c.requestLogs
+	if el, ok := logs.files[path]; ok {
+		logs.lru.MoveToFront(el)
+		return el.Value.(*requestLogFile).file, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	for logs.lru.Len() >= maxOpenRequestLogs {
+		logs.close(logs.lru.Back())
+	}
+	logs.files[path] = logs.lru.PushFront(&requestLogFile{path: path, file: f})
+	return f, nil
+}
+
+func (l *requestLogFiles) close(el *list.Element) {
+	entry := l.lru.Remove(el).(*requestLogFile)
+	delete(l.files, entry.path)
+	_ = entry.file.Close()
+}
+
+func (c *ReverseBin) closeRequestLogs() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.requestLogs == nil {
+		return
+	}
+	for c.requestLogs.lru.Len() > 0 {
+		c.requestLogs.close(c.requestLogs.lru.Back())
+	}
+}
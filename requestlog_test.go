@@ -0,0 +1,79 @@
+package reversebin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestLogRequestAppendsJSONLinesPerHost verifies request_log expands placeholders and appends one JSON line per request.
+func TestLogRequestAppendsJSONLinesPerHost(t *testing.T) {
+	dir := t.TempDir()
+	c := &ReverseBin{RequestLog: filepath.Join(dir, "{http.request.host}.log"), logger: zaptest.NewLogger(t)}
+	defer c.closeRequestLogs()
+
+	for _, status := range []int{http.StatusOK, http.StatusNotFound} {
+		req := httptest.NewRequest(http.MethodPost, "http://app.example/submit?x=1", nil)
+		req = caddyhttp.PrepareRequest(req, caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+		rec := caddyhttp.NewResponseRecorder(httptest.NewRecorder(), nil, nil)
+		rec.WriteHeader(status)
+		_, _ = rec.Write([]byte("hello"))
+		c.logRequest(req, rec, nil, 25*time.Millisecond)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "app.example.log"))
+	if err != nil {
+		t.Fatalf("read request log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", data)
+	}
+	var entry requestLogEntry
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("unmarshal %q: %v", lines[1], err)
+	}
+	if entry.Method != http.MethodPost || entry.Path != "/submit" || entry.Status != http.StatusNotFound || entry.Size != 5 || entry.BackendLatencyMS != 25 || entry.Timestamp == "" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+}
+
+// TestLogRequestBoundsOpenFiles verifies request_log keeps at most maxOpenRequestLogs files open when a placeholder expands to many hosts, and still writes every line.
+func TestLogRequestBoundsOpenFiles(t *testing.T) {
+	dir := t.TempDir()
+	c := &ReverseBin{RequestLog: filepath.Join(dir, "{http.request.host}.log"), logger: zaptest.NewLogger(t)}
+	defer c.closeRequestLogs()
+
+	hosts := maxOpenRequestLogs * 3
+	for i := 0; i < hosts*2; i++ {
+		// This HTTP request tests a client choosing a new Host for every request.
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://tenant%d.example/", i%hosts), nil)
+		req = caddyhttp.PrepareRequest(req, caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+		rec := caddyhttp.NewResponseRecorder(httptest.NewRecorder(), nil, nil)
+		rec.WriteHeader(http.StatusOK)
+		c.logRequest(req, rec, nil, 0)
+		if n := c.requestLogs.lru.Len(); n > maxOpenRequestLogs {
+			t.Fatalf("request %d: expected at most %d open files, got %d", i+1, maxOpenRequestLogs, n)
+		}
+	}
+
+	for _, host := range []int{0, hosts - 1} {
+		data, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("tenant%d.example.log", host)))
+		if err != nil {
+			t.Fatalf("read request log: %v", err)
+		}
+		if n := strings.Count(string(data), "\n"); n != 2 {
+			t.Fatalf("tenant%d: expected 2 lines after its file was closed and reopened, got %d", host, n)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package reversebin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// responseTimeoutError reports a backend that went silent for too long. It
+// is a net.Error with Timeout true, which reverse_proxy answers with 504.
+type responseTimeoutError struct {
+	what  string
+	limit time.Duration
+}
+
+func (e *responseTimeoutError) Error() string {
+	return fmt.Sprintf("backend %s timeout after %v", e.what, e.limit)
+}
+
+func (e *responseTimeoutError) Timeout() bool   { return true }
+func (e *responseTimeoutError) Temporary() bool { return false }
+
+// responseTimeoutTransport enforces response_header_timeout_ms for
+// protocols whose Caddy transport has no equivalent, and
+// response_idle_timeout_ms for every protocol: Caddy's HTTP read_timeout
+// only covers TCP connections, and FastCGI's is a deadline for the whole
+// response rather than for each read.
+type responseTimeoutTransport struct {
+	next   http.RoundTripper
+	header time.Duration
+	idle   time.Duration
+}
+
+func (t *responseTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	var headerTimer *time.Timer
+	if t.header > 0 {
+		headerTimer = time.AfterFunc(t.header, cancel)
+	}
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if headerTimer != nil && !headerTimer.Stop() {
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		cancel()
+		return nil, &responseTimeoutError{what: "response header", limit: t.header}
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if t.idle > 0 {
+		resp.Body = newIdleTimeoutBody(resp.Body, t.idle, cancel)
+	} else {
+		resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	}
+	return resp, nil
+}
+
+// cancelOnCloseBody releases the response's context once it is closed.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// idleTimeoutBody aborts the response when a single read waits longer than
+// idle for the backend. Time spent between reads, such as writing to a slow
+// client, does not count.
+type idleTimeoutBody struct {
+	io.ReadCloser
+	idle    time.Duration
+	timer   *time.Timer
+	expired atomic.Bool
+	cancel  context.CancelFunc
+}
+
+func newIdleTimeoutBody(body io.ReadCloser, idle time.Duration, cancel context.CancelFunc) *idleTimeoutBody {
+	b := &idleTimeoutBody{ReadCloser: body, idle: idle, cancel: cancel}
+	b.timer = time.AfterFunc(idle, b.expire)
+	b.timer.Stop()
+	return b
+}
+
+// expire unblocks the pending read; canceling the context is enough for
+// HTTP, closing the body for the other transports.
+func (b *idleTimeoutBody) expire() {
+	b.expired.Store(true)
+	b.cancel()
+	_ = b.ReadCloser.Close()
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	b.timer.Reset(b.idle)
+	n, err := b.ReadCloser.Read(p)
+	b.timer.Stop()
+	if b.expired.Load() {
+		return n, &responseTimeoutError{what: "response idle", limit: b.idle}
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
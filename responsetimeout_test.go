@@ -0,0 +1,53 @@
+package reversebin
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// TestResponseHeaderTimeoutIsGatewayTimeout verifies a backend that never answers fails with a timeout net.Error.
+func TestResponseHeaderTimeoutIsGatewayTimeout(t *testing.T) {
+	rt := &responseTimeoutTransport{header: 20 * time.Millisecond, next: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})}
+	_, err := rt.RoundTrip(httptest.NewRequest("GET", "/", nil))
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("expected a timeout net.Error, got %v", err)
+	}
+}
+
+// TestResponseIdleTimeoutAbortsStalledStream verifies a stream that stops sending is cut off, while steady bytes pass.
+func TestResponseIdleTimeoutAbortsStalledStream(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	rt := &responseTimeoutTransport{idle: 50 * time.Millisecond, next: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: pr}, nil
+	})}
+	resp, err := rt.RoundTrip(httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatalf("round trip: %v", err)
+	}
+	defer resp.Body.Close()
+
+	go func() { _, _ = pw.Write([]byte("tick")) }()
+	buf := make([]byte, 8)
+	if n, err := resp.Body.Read(buf); err != nil || string(buf[:n]) != "tick" {
+		t.Fatalf("expected first chunk tick, got %q, %v", buf[:n], err)
+	}
+	_, err = resp.Body.Read(buf)
+	var timeoutErr *responseTimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.what != "response idle" {
+		t.Fatalf("expected response idle timeout, got %v", err)
+	}
+}
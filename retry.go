@@ -0,0 +1,97 @@
+package reversebin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	"go.uber.org/zap"
+)
+
+// retryAttemptsVar counts the retry_on_failure attempts already spent on a
+// request; reverse_proxy reuses the request context across its retries.
+const retryAttemptsVar = "reverse_bin.retry_attempts"
+
+// retryBackendVar holds the backend GetUpstreams last picked for a request,
+// so a retry stops the process that failed rather than its replacement.
+const retryBackendVar = "reverse_bin.retry_backend"
+
+// idempotentMethods are retried by retry_on_failure without
+// retry_unsafe_methods (RFC 9110 section 9.2.2).
+var idempotentMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodOptions,
+	http.MethodTrace,
+	http.MethodPut,
+	http.MethodDelete,
+}
+
+// retryingTransport turns a 502/503 response or a failed exchange into an
+// error after restarting the backend, so reverse_proxy's retry loop sends
+// the request again to a fresh process. Dial errors are left alone: the
+// proxy retries those itself and the supervisor replaces exited backends.
+type retryingTransport struct {
+	next     http.RoundTripper
+	attempts int
+	unsafe   bool
+	restart  func(*http.Request)
+}
+
+func (t *retryingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if !t.shouldRetry(req, resp, err) {
+		return resp, err
+	}
+	attempt, _ := caddyhttp.GetVar(req.Context(), retryAttemptsVar).(int)
+	if attempt >= t.attempts {
+		return resp, err
+	}
+	caddyhttp.SetVar(req.Context(), retryAttemptsVar, attempt+1)
+	if resp != nil {
+		_ = resp.Body.Close()
+		err = fmt.Errorf("backend responded %d", resp.StatusCode)
+	}
+	t.restart(req)
+	return nil, err
+}
+
+func (t *retryingTransport) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if !t.unsafe && !slices.Contains(idempotentMethods, req.Method) {
+		return false
+	}
+	if err != nil {
+		var dialErr reverseproxy.DialError
+		return !errors.As(err, &dialErr) && req.Context().Err() == nil
+	}
+	return resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusServiceUnavailable
+}
+
+// retryMatch limits reverse_proxy's retries of failed exchanges to the
+// methods retryingTransport would retry.
+func (c *ReverseBin) retryMatch() caddyhttp.MatcherSets {
+	if c.RetryUnsafeMethods {
+		// An empty matcher set matches every request.
+		return caddyhttp.MatcherSets{{}}
+	}
+	return caddyhttp.MatcherSets{{caddyhttp.MatchMethod(idempotentMethods)}}
+}
+
+// restartBackend stops the backend that failed r so the next attempt
+// launches a fresh one. The stop is not drained: r itself is still in
+// flight on that backend. A backend another failed request has already
+// replaced is left alone.
+func (c *ReverseBin) restartBackend(r *http.Request) {
+	backend, _ := caddyhttp.GetVar(r.Context(), retryBackendVar).(*runningBackend)
+	if backend == nil {
+		return
+	}
+	ps := c.getOrCreateProcessState(c.getProcessKey(r))
+	cmd := supervisorCommand{kind: supervisorStop, reason: "retry on failure", backend: backend}
+	if err := c.sendCommand(ps, cmd); err != nil {
+		c.requestLogger(r).Warn("failed to stop backend for retry", zap.String("key", ps.key), zap.Error(err))
+	}
+}
@@ -0,0 +1,85 @@
+package reversebin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// TestRetryingTransportRestartsOnUnavailable verifies 503s become retryable errors until the attempt budget runs out, and unsafe methods pass through.
+func TestRetryingTransportRestartsOnUnavailable(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// This HTTP request tests a backend that is always unavailable.
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer backend.Close()
+
+	restarts := 0
+	rt := &retryingTransport{next: http.DefaultTransport, attempts: 1, restart: func(*http.Request) { restarts++ }}
+	newRequest := func(method string) *http.Request {
+		req := httptest.NewRequest(method, backend.URL, nil)
+		req.RequestURI = ""
+		return caddyhttp.PrepareRequest(req, caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+	}
+
+	get := newRequest(http.MethodGet)
+	if resp, err := rt.RoundTrip(get); err == nil {
+		resp.Body.Close()
+		t.Fatalf("first GET: expected a retryable error, got status %d", resp.StatusCode)
+	}
+	resp, err := rt.RoundTrip(get)
+	if err != nil {
+		t.Fatalf("second GET: RoundTrip: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || restarts != 1 {
+		t.Fatalf("expected the 503 once attempts are spent after 1 restart, got %d after %d", resp.StatusCode, restarts)
+	}
+
+	resp, err = rt.RoundTrip(newRequest(http.MethodPost))
+	if err != nil {
+		t.Fatalf("POST: RoundTrip: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || restarts != 1 {
+		t.Fatalf("expected POST to pass through without a restart, got %d after %d restarts", resp.StatusCode, restarts)
+	}
+}
+
+// TestRestartBackendLeavesReplacementRunning verifies a retry stops only the backend its request failed on, not one another retry already replaced it with.
+func TestRestartBackendLeavesReplacementRunning(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	f := useMockProcesses(t, sock, http.NotFoundHandler())
+	c, _ := drainTestHandler(t, sock)
+	newRequest := func() *http.Request {
+		// This HTTP request tests a retry that failed on the backend it was given.
+		req := caddyhttp.PrepareRequest(httptest.NewRequest(http.MethodGet, "http://app.example/", nil), caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+		if _, err := c.GetUpstreams(req); err != nil {
+			t.Fatalf("GetUpstreams: %v", err)
+		}
+		return req
+	}
+
+	first, second := newRequest(), newRequest()
+	c.restartBackend(first)
+	select {
+	case <-f.started[0].exited:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the failed backend to be stopped")
+	}
+	newRequest()
+	c.restartBackend(second)
+	select {
+	case <-f.started[1].exited:
+		t.Fatal("expected a stale retry to leave the replacement backend running")
+	case <-time.After(200 * time.Millisecond):
+	}
+	if n := f.starts.Load(); n != 2 {
+		t.Fatalf("expected 2 launches, got %d", n)
+	}
+}
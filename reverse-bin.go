@@ -29,17 +29,21 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/caddyserver/caddy/v2"
 	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
 	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -48,6 +52,9 @@ const (
 	defaultHealthTimeoutMS       = 15000
 	defaultTerminationGraceMS    = 5000
 	defaultTerminationKillWaitMS = 1000
+	defaultShutdownCommandMS     = 10000
+	defaultSocketPollIntervalMS  = 50
+	secondaryFailDuration        = 5 * time.Second
 	healthCheckDocsURL           = "https://github.com/tarasglek/caddy-reverse-bin#health-checks"
 )
 
@@ -70,10 +77,48 @@ func (c *ReverseBin) healthTimeout() time.Duration {
 	return time.Duration(c.HealthTimeoutMS) * time.Millisecond
 }
 
+func (c *ReverseBin) cacheTTL() time.Duration {
+	return time.Duration(c.CacheTTLMS) * time.Millisecond
+}
+
+func (c *ReverseBin) circuitBreakerOpenDuration() time.Duration {
+	return time.Duration(c.CircuitBreakerOpenDurationMS) * time.Millisecond
+}
+
+func (c *ReverseBin) responseHeaderTimeout() time.Duration {
+	return time.Duration(c.ResponseHeaderTimeoutMS) * time.Millisecond
+}
+
+func (c *ReverseBin) responseIdleTimeout() time.Duration {
+	return time.Duration(c.ResponseIdleTimeoutMS) * time.Millisecond
+}
+
+func (c *ReverseBin) socketWaitTimeout() time.Duration {
+	return time.Duration(c.SocketWaitTimeoutMS) * time.Millisecond
+}
+
+func (c *ReverseBin) socketPollInterval() time.Duration {
+	if c.SocketPollIntervalMS <= 0 {
+		return defaultSocketPollIntervalMS * time.Millisecond
+	}
+	return time.Duration(c.SocketPollIntervalMS) * time.Millisecond
+}
+
+func (c *ReverseBin) backendConnectTimeout() time.Duration {
+	if c.BackendConnectTimeoutMS <= 0 {
+		return defaultBackendConnectTimeoutMS * time.Millisecond
+	}
+	return time.Duration(c.BackendConnectTimeoutMS) * time.Millisecond
+}
+
 func (c *ReverseBin) terminationGrace() time.Duration {
 	return time.Duration(c.TerminationGraceMS) * time.Millisecond
 }
 
+func (c *ReverseBin) shutdownCommandTimeout() time.Duration {
+	return time.Duration(c.ShutdownCommandTimeoutMS) * time.Millisecond
+}
+
 func (c *ReverseBin) terminationKillWait() time.Duration {
 	return time.Duration(c.TerminationKillWaitMS) * time.Millisecond
 }
@@ -81,35 +126,223 @@ func (c *ReverseBin) terminationKillWait() time.Duration {
 // ServeHTTP implements caddyhttp.MiddlewareHandler; it handles the HTTP request
 // manages idle process killing
 func (c *ReverseBin) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
-	c.logger.Debug("ServeHTTP", zap.String("uri", r.RequestURI))
-	key := c.getProcessKey(r)
-	ps := c.getOrCreateProcessState(key)
+	if c.ErrorBodyFormat == "" {
+		return c.serve(w, r, next)
+	}
+	ew := &errorBodyWriter{ResponseWriterWrapper: &caddyhttp.ResponseWriterWrapper{ResponseWriter: w}}
+	return c.writeErrorBody(ew, r, c.serve(ew, r, next))
+}
 
-	if err := c.sendSupervisorCommand(ps, supervisorRequestStarted, "request started"); err != nil {
-		return err
+func (c *ReverseBin) serve(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	if c.RequestIDHeader != "" {
+		c.assignRequestID(r)
+	}
+	c.requestLogger(r).Debug("ServeHTTP", zap.String("uri", r.RequestURI))
+	if len(c.allowedNets) > 0 {
+		if err := c.checkAllowedIP(r); err != nil {
+			return err
+		}
+	}
+	if c.StatusPagePath != "" && r.URL.Path == c.StatusPagePath {
+		return c.serveStatusPage(w, r)
+	}
+	if c.Inspect {
+		return c.serveInspect(w, r)
+	}
+	if c.app.dryRun() {
+		return dryRunError()
+	}
+	var cacheKey string
+	if c.cache != nil {
+		cacheKey = requestCacheKey(r)
+	}
+	if cacheKey != "" {
+		if entry, ok := c.cache.get(cacheKey); ok {
+			entry.serve(w, r)
+			return nil
+		}
+	}
+	if c.rateLimiter != nil {
+		if err := c.checkRateLimit(w); err != nil {
+			return err
+		}
+	}
+	var body *limitedBody
+	if c.MaxRequestBodySize > 0 {
+		var err error
+		if body, err = c.limitRequestBody(w, r); err != nil {
+			return err
+		}
+	}
+	succeeded := false
+	if c.breaker != nil {
+		if !c.breaker.allow() {
+			return caddyhttp.Error(http.StatusServiceUnavailable, fmt.Errorf("circuit breaker open"))
+		}
+		defer func() { c.breaker.record(succeeded) }()
+	}
+	// stdio_mode runs a command per request, so there is no supervised
+	// backend to track.
+	var ps *processState
+	if !c.StdioMode {
+		ps = c.getOrCreateProcessState(c.getProcessKey(r))
+		if err := c.sendSupervisorCommand(ps, supervisorRequestStarted, "request started"); err != nil {
+			return err
+		}
+		defer func() { _ = c.sendSupervisorCommand(ps, supervisorRequestDone, "request done") }()
+
+		if c.reverseProxy == nil {
+			return fmt.Errorf("reverse proxy not initialized")
+		}
+	}
+
+	var lat *requestLatency
+	if c.AccessLogBackendLatency || c.RequestLog != "" {
+		r, lat = withRequestLatency(r)
+	}
+	start := time.Now()
+	if c.AccessLogBackendLatency {
+		defer func() { lat.logLatency(r, time.Since(start)) }()
+	}
+
+	var cw *cachingWriter
+	if cacheKey != "" {
+		cw = &cachingWriter{ResponseWriterWrapper: &caddyhttp.ResponseWriterWrapper{ResponseWriter: w}, limit: c.cache.maxSize}
+		w = cw
 	}
-	defer func() { _ = c.sendSupervisorCommand(ps, supervisorRequestDone, "request done") }()
 
-	if c.reverseProxy == nil {
-		return fmt.Errorf("reverse proxy not initialized")
+	if len(c.StatusOverrides) > 0 {
+		w = &statusOverrideWriter{ResponseWriterWrapper: &caddyhttp.ResponseWriterWrapper{ResponseWriter: w}, overrides: c.StatusOverrides}
 	}
 
-	return c.reverseProxy.ServeHTTP(w, r, next)
+	var rec caddyhttp.ResponseRecorder
+	if c.RequestLog != "" || c.breaker != nil {
+		// Record inside any status override so it reflects what the backend sent.
+		rec = caddyhttp.NewResponseRecorder(w, nil, nil)
+		w = rec
+	}
+	if len(c.ProxyHeadersFromRequest) > 0 {
+		c.stashRequestHeaders(r)
+	}
+	var span trace.Span
+	if c.tracingHeaders() {
+		r, span = startBackendSpan(r)
+	} else {
+		stripTraceHeaders(r)
+	}
+	var err error
+	if c.StdioMode {
+		err = c.serveStdio(w, r)
+	} else {
+		err = c.reverseProxy.ServeHTTP(w, r, next)
+	}
+	err = bodyLimitError(body, err)
+	endBackendSpan(span, err)
+	if err != nil && ps != nil {
+		ps.status.failed(err)
+	}
+	if c.RequestLog != "" {
+		c.logRequest(r, rec, err, lat.backendLatency(time.Since(start)))
+	}
+	succeeded = err == nil && (rec == nil || rec.Status() < http.StatusInternalServerError)
+	if cw != nil && err == nil {
+		if entry := cw.cacheable(cacheKey); entry != nil {
+			c.cache.put(entry)
+		}
+	}
+	return err
 }
 
 func (c *ReverseBin) getProcessKey(r *http.Request) string {
-	if len(c.DynamicProxyDetector) == 0 {
-		return ""
+	if i := c.matchRoute(r); i >= 0 {
+		return strings.Join(append([]string{"route", c.Routes[i].name()}, c.routeExecutable(r, i)...), " ")
+	}
+	args := c.DynamicProxyDetector
+	if len(c.DynamicProxyDetectorHTTP) > 0 {
+		args = c.DynamicProxyDetectorHTTP
+	}
+	if len(args) == 0 {
+		// Without a detector there is a single backend, unless placeholders
+		// in exec, env or socket_template select a different one per request.
+		if !hasPlaceholders(c.Executable) && !hasPlaceholders(c.Envs) && !hasPlaceholders([]string{c.SocketTemplate}) && len(c.EnvFromHeaders) == 0 {
+			return ""
+		}
+		key := append(expandArgs(r, c.Executable), expandArgs(r, c.Envs)...)
+		key = append(key, c.headerEnvs(r)...)
+		if c.SocketTemplate != "" {
+			key = append(key, expandArgs(r, []string{c.SocketTemplate})...)
+		}
+		return strings.Join(key, " ")
 	}
-	repl := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer)
-	var sb strings.Builder
-	for i, arg := range c.DynamicProxyDetector {
-		if i > 0 {
-			sb.WriteByte(' ')
+	return strings.Join(expandArgs(r, args), " ")
+}
+
+// placeholderPattern matches a Caddy placeholder such as {http.request.host}
+// or {env.APP_ENV}. The optional leading $ or \ catches shell parameters
+// like ${PORT} and escaped braces, which placeholderNames skips.
+var placeholderPattern = regexp.MustCompile(`[$\\]?\{([a-zA-Z_][\w-]*\.[^{}\s]+)\}`)
+
+// placeholderNames returns the Caddy placeholders in arg, without braces.
+func placeholderNames(arg string) []string {
+	var names []string
+	for _, m := range placeholderPattern.FindAllStringSubmatch(arg, -1) {
+		if m[0][0] != '{' {
+			continue
+		}
+		names = append(names, m[1])
+	}
+	return names
+}
+
+func hasPlaceholders(args []string) bool {
+	for _, arg := range args {
+		if len(placeholderNames(arg)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSharedSocket rejects configs where requests select separate backends
+// without a detector, through placeholders in exec or env or through
+// env_from_header, but socket_template does not separate their sockets. Such backends would share one socket, and the
+// newest would take over the requests meant for the others. {env.*}
+// placeholders are the same for every request and are ignored.
+func (c *ReverseBin) checkSharedSocket() (directive string, err error) {
+	if c.hasDetector() || c.StdioMode || c.PortDiscoveryPattern != "" || c.StartupAddressPattern != "" {
+		return "", nil
+	}
+	socket := placeholderNames(c.SocketTemplate)
+	for _, d := range []struct {
+		directive string
+		args      []string
+	}{{"exec", c.Executable}, {"env", c.Envs}} {
+		for _, arg := range d.args {
+			for _, name := range placeholderNames(arg) {
+				if !strings.HasPrefix(name, "env.") && !slices.Contains(socket, name) {
+					return d.directive, fmt.Errorf("%s placeholder {%s} starts a backend per value, so socket_template must contain it too; otherwise they share one socket", d.directive, name)
+				}
+			}
 		}
-		sb.WriteString(repl.ReplaceAll(arg, ""))
 	}
-	return sb.String()
+	for _, h := range c.EnvFromHeaders {
+		if !slices.ContainsFunc(socket, func(name string) bool { return strings.EqualFold(name, "http.request.header."+h.Header) }) {
+			return "env_from_header", fmt.Errorf("env_from_header %s starts a backend per value, so socket_template must contain {http.request.header.%s}; otherwise they share one socket", h.Header, h.Header)
+		}
+	}
+	return "", nil
+}
+
+// expandArgs replaces Caddy placeholders in args using r's replacer. Unknown
+// placeholders are left intact, so shell parameters such as ${PORT} and
+// literal braces in values (JSON, templates) survive.
+func expandArgs(r *http.Request, args []string) []string {
+	repl := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer)
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		expanded[i] = repl.ReplaceKnown(arg, "")
+	}
+	return expanded
 }
 
 // GetUpstreams implements reverseproxy.UpstreamSource which allows dynamic selection of backend process
@@ -118,7 +351,8 @@ func (c *ReverseBin) getProcessKey(r *http.Request) string {
 // request that triggers a process start, the request tracking must be initialized here
 // to ensure the idle timer starts correctly after the first request completes.
 func (c *ReverseBin) GetUpstreams(r *http.Request) ([]*reverseproxy.Upstream, error) {
-	c.logger.Debug("GetUpstreams", zap.String("uri", r.RequestURI))
+	logger := c.requestLogger(r)
+	logger.Debug("GetUpstreams", zap.String("uri", r.RequestURI))
 	key := c.getProcessKey(r)
 	ps := c.getOrCreateProcessState(key)
 
@@ -132,27 +366,79 @@ func (c *ReverseBin) GetUpstreams(r *http.Request) ([]*reverseproxy.Upstream, er
 		return nil, err
 	}
 
-	c.logger.Debug("selected upstream", zap.String("dial", dialAddr))
-	return []*reverseproxy.Upstream{{Dial: dialAddr}}, nil
+	if strings.HasPrefix(toAddr, "https://") {
+		caddyhttp.SetVar(r.Context(), upstreamSchemeVar, "https")
+	}
+
+	logger.Debug("selected upstream", zap.String("dial", dialAddr))
+	upstreams := []*reverseproxy.Upstream{{Dial: dialAddr}}
+	if c.ReverseProxyToSecondary != "" {
+		if secondary, err := resolveDialAddress(c.ReverseProxyToSecondary); err == nil {
+			upstreams = append(upstreams, &reverseproxy.Upstream{Dial: secondary})
+		} else {
+			logger.Debug("secondary upstream unavailable", zap.Error(err))
+		}
+	}
+	return upstreams, nil
 }
 
 func (c *ReverseBin) getUpstreamFromSupervisor(r *http.Request, ps *processState) (string, error) {
+	for {
+		result, err := c.askSupervisor(r, ps)
+		if err != nil || !result.startFailed {
+			if result.backend != nil {
+				caddyhttp.SetVar(r.Context(), retryBackendVar, result.backend)
+			}
+			return result.upstream, err
+		}
+		delay, ok := c.startupRetry()
+		if !ok || r.Context().Err() != nil {
+			return "", result.err
+		}
+		c.requestLogger(r).Warn("backend failed to start; retrying for queued request",
+			zap.String("key", ps.key),
+			zap.String("startup_timeout_action", c.StartupTimeoutAction),
+			zap.Duration("delay", delay),
+			zap.Error(result.err))
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return "", result.err
+			case <-c.done():
+				timer.Stop()
+				return "", c.doneErr()
+			}
+		}
+	}
+}
+
+// askSupervisor asks ps's supervisor for an upstream once. The returned
+// error covers only the request or handler going away; launch failures are
+// in the result.
+func (c *ReverseBin) askSupervisor(r *http.Request, ps *processState) (supervisorResult, error) {
 	reply := make(chan supervisorResult, 1)
 	select {
 	case ps.requests <- supervisorRequest{request: r, reply: reply}:
 	case <-r.Context().Done():
-		return "", r.Context().Err()
+		return supervisorResult{}, r.Context().Err()
 	case <-c.done():
-		return "", c.doneErr()
+		return supervisorResult{}, c.doneErr()
 	}
 
 	select {
 	case result := <-reply:
-		return result.upstream, result.err
+		recordStartupLatency(r, result.startup)
+		if !result.startFailed && result.err != nil {
+			return supervisorResult{}, result.err
+		}
+		return result, nil
 	case <-r.Context().Done():
-		return "", r.Context().Err()
+		return supervisorResult{}, r.Context().Err()
 	case <-c.done():
-		return "", c.doneErr()
+		return supervisorResult{}, c.doneErr()
 	}
 }
 
@@ -178,10 +464,31 @@ func resolveDialAddress(toAddr string) (string, error) {
 	if target.Host == "" {
 		return "", fmt.Errorf("invalid reverse_proxy_to address: missing host")
 	}
+	if target.Port() == "" {
+		port := "80"
+		if target.Scheme == "https" {
+			port = "443"
+		}
+		return net.JoinHostPort(target.Hostname(), port), nil
+	}
 	return target.Host, nil
 }
 
+// isAbstractSocket reports whether socketPath names a Linux abstract socket.
+// Go spells those with a leading @; they have no file to stat or remove.
+func isAbstractSocket(socketPath string) bool {
+	return strings.HasPrefix(socketPath, "@")
+}
+
 func isUnixSocketHealthy(socketPath string) bool {
+	if isAbstractSocket(socketPath) {
+		conn, err := net.DialTimeout("unix", socketPath, 100*time.Millisecond)
+		if err != nil {
+			return false
+		}
+		_ = conn.Close()
+		return true
+	}
 	info, err := os.Stat(socketPath)
 	if err != nil {
 		return false
@@ -204,13 +511,28 @@ func processKillPlan(goos string, pid int, sig syscall.Signal) killPlan {
 var sensitiveEnvKeyPattern = regexp.MustCompile(`(?i)(secret|token|password|passwd|pwd|key|private|credential|auth)`)
 
 func sanitizeArgsForLog(args []string) []string {
+	return redactArgs(args, sensitiveEnvKeyPattern)
+}
+
+// sanitizeForLog redacts args and environment entries with
+// redact_env_pattern, or the built-in pattern when it is unset.
+func (c *ReverseBin) sanitizeForLog(args []string) []string {
+	if c.redactPattern != nil {
+		return redactArgs(args, c.redactPattern)
+	}
+	return sanitizeArgsForLog(args)
+}
+
+// redactArgs hides the value of every KEY=value entry whose key matches
+// pattern, leaving args itself untouched.
+func redactArgs(args []string, pattern *regexp.Regexp) []string {
 	out := append([]string(nil), args...)
 	for i, arg := range out {
 		key, _, ok := strings.Cut(arg, "=")
 		if !ok {
 			continue
 		}
-		if sensitiveEnvKeyPattern.MatchString(key) {
+		if pattern.MatchString(key) {
 			out[i] = key + "=<redacted>"
 		}
 	}
@@ -242,11 +564,18 @@ type resolvedConfig struct {
 }
 
 type runningBackend struct {
-	cmd     *exec.Cmd
-	process *os.Process
+	process backendProcess
 	done    chan error
-	cancel  context.CancelFunc
-	config  resolvedConfig
+	// exited is closed once the process has been reaped; unlike done, any
+	// goroutine may check it.
+	exited chan struct{}
+	cancel context.CancelFunc
+	config resolvedConfig
+	// port delivers the port found by port_discovery_pattern
+	port <-chan string
+	// stopping is set once stopBackend has been asked to stop the
+	// process, so its exit is not reported as a crash.
+	stopping atomic.Bool
 }
 
 func (c *ReverseBin) resolveConfig(overrides *DetectorOutput) resolvedConfig {
@@ -286,107 +615,169 @@ func (c *ReverseBin) resolveConfig(overrides *DetectorOutput) resolvedConfig {
 	return cfg
 }
 
-func (c *ReverseBin) launchBackend(ctx context.Context, cfg resolvedConfig, reason string) (*runningBackend, error) {
-	if len(cfg.Executable) == 0 {
-		return nil, fmt.Errorf("exec (executable) is required")
+// lookExecutable reports whether name can be run from dir. os/exec already
+// runs a relative path such as ./main.py from Cmd.Dir, so only this check
+// needs to resolve it, against the absolute dir. Bare command names are
+// looked up in PATH.
+func lookExecutable(name, dir string) error {
+	if dir != "" && !filepath.IsAbs(name) && filepath.Base(name) != name {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		name = filepath.Join(abs, name)
 	}
+	_, err := exec.LookPath(name)
+	return err
+}
 
-	backendCtx, cancel := context.WithCancel(ctx)
-	cmd := exec.CommandContext(backendCtx, cfg.Executable[0], cfg.Executable[1:]...)
+// backendCommand builds the command for cfg with the process group,
+// namespaces, credentials, working directory and environment applied. It
+// is sent SIGTERM when ctx ends.
+func (c *ReverseBin) backendCommand(ctx context.Context, cfg resolvedConfig) (*exec.Cmd, error) {
+	cmd := exec.CommandContext(ctx, cfg.Executable[0], cfg.Executable[1:]...)
 	cmd.Cancel = func() error {
 		return signalProcessGroup(cmd.Process, syscall.SIGTERM)
 	}
 	cmd.WaitDelay = c.terminationGrace()
 	configureBackendProcAttrs(cmd)
+	setProcessNamespaces(cmd, c.cloneflags)
+	setRunAs(cmd, c.runAs)
 	cmd.Dir = cfg.WorkingDirectory
 	if cmd.Dir == "" {
 		cmd.Dir = "."
 	}
 
-	var cmdEnv []string
-	if c.PassAll {
-		cmdEnv = os.Environ()
-	} else {
-		for _, key := range c.PassEnvs {
-			if val, ok := os.LookupEnv(key); ok {
-				cmdEnv = append(cmdEnv, key+"="+val)
-			}
-		}
+	cmdEnv, err := c.backendEnv(cfg)
+	if err != nil {
+		return nil, err
 	}
-	cmdEnv = append(cmdEnv, cfg.Envs...)
 	cmd.Env = cmdEnv
+	return cmd, nil
+}
 
-	stdoutPipe, err := cmd.StdoutPipe()
-	if err != nil {
-		cancel()
-		return nil, err
+func (c *ReverseBin) launchBackend(ctx context.Context, cfg resolvedConfig, reason string) (*runningBackend, error) {
+	if len(cfg.Executable) == 0 {
+		return nil, fmt.Errorf("exec (executable) is required")
 	}
-	stderrPipe, err := cmd.StderrPipe()
+
+	backendCtx, cancel := context.WithCancel(ctx)
+	cmd, err := c.backendCommand(backendCtx, cfg)
 	if err != nil {
 		cancel()
 		return nil, err
 	}
+	if c.activation != nil {
+		c.passActivationSocket(cmd)
+	}
 
 	var wg sync.WaitGroup
 	wg.Add(2)
 
-	if err := cmd.Start(); err != nil {
+	proc, err := backendProcesses.Start(backendCtx, cmd)
+	if err != nil {
 		cancel()
 		c.logger.Error("failed to start proxy subprocess",
 			zap.String("executable", cmd.Path),
-			zap.Strings("args", sanitizeArgsForLog(cmd.Args)),
+			zap.Strings("args", c.sanitizeForLog(cmd.Args)),
 			zap.String("reason", reason),
 			zap.Error(err))
 		return nil, err
 	}
 
-	pid := cmd.Process.Pid
+	pid := proc.Pid()
 	c.logger.Info("started proxy subprocess",
 		zap.Int("pid", pid),
 		zap.String("executable", cmd.Path),
-		zap.Strings("args", sanitizeArgsForLog(cmd.Args)),
+		zap.Strings("args", c.sanitizeForLog(cmd.Args)),
 		zap.String("reason", reason))
+	if err := applyResourceLimits(pid, c.MaxMemory, c.CPUShares); err != nil {
+		c.logger.Warn("failed to apply resource limits to proxy subprocess",
+			zap.Int("pid", pid),
+			zap.Error(err))
+	}
+	if c.CgroupPath != "" {
+		if err := joinCgroup(c.CgroupPath, pid); err != nil {
+			c.logger.Warn("failed to move proxy subprocess into cgroup",
+				zap.Int("pid", pid),
+				zap.String("cgroup_path", c.CgroupPath),
+				zap.Error(err))
+		}
+	}
 
-	logPipe := func(pipe io.ReadCloser, label string) {
+	var ports *portScanner
+	if c.discoversPort(cfg) {
+		_, _, maxBytes := c.discoveryPattern()
+		ports = newPortScanner(c.portPattern, *maxBytes)
+	}
+	logPipe := func(pipe io.Reader, label string, ports *portScanner) {
 		defer wg.Done()
+		defer ports.finish()
 		scanner := bufio.NewScanner(pipe)
 		for scanner.Scan() {
 			c.logger.Info("", zap.Int("pid", pid), zap.String(label, scanner.Text()))
+			ports.scan(scanner.Text())
 		}
 	}
 
-	go logPipe(stdoutPipe, "stdout")
-	go logPipe(stderrPipe, "stderr")
+	go logPipe(proc.Stdout(), "stdout", ports)
+	go logPipe(proc.Stderr(), "stderr", nil)
 
-	done := make(chan error, 1)
+	rb := &runningBackend{
+		process: proc,
+		done:    make(chan error, 1),
+		exited:  make(chan struct{}),
+		cancel:  cancel,
+		config:  cfg,
+	}
+	if ports != nil {
+		rb.port = ports.port
+	}
 	go func() {
-		err := cmd.Wait()
+		err := proc.Wait()
 		wg.Wait()
 		c.logger.Info("proxy subprocess terminated",
 			zap.Int("pid", pid),
 			zap.String("reason", reason),
 			zap.Error(err))
-		done <- err
+		close(rb.exited)
+		// A canceled ctx means the handler is going away, not a crash.
+		if !rb.stopping.Load() && ctx.Err() == nil {
+			c.runLifecycleHook(lifecycleCrash, pid, cfg)
+		}
+		rb.done <- err
 	}()
-
-	return &runningBackend{
-		cmd:     cmd,
-		process: cmd.Process,
-		done:    done,
-		cancel:  cancel,
-		config:  cfg,
-	}, nil
+	c.runLifecycleHook(lifecycleStart, pid, cfg)
+	return rb, nil
 }
 
 func (c *ReverseBin) resolveRequestConfig(r *http.Request, key string) (resolvedConfig, error) {
 	overrides := new(DetectorOutput)
-	if len(c.DynamicProxyDetector) > 0 {
+	if len(c.DynamicProxyDetectorHTTP) > 0 {
+		parsedOverrides, err := c.fetchHTTPDetector(r.Context(), key)
+		if err != nil {
+			return resolvedConfig{}, err
+		}
+		overrides = parsedOverrides
+	} else if isBuiltinDetector(c.DynamicProxyDetector) {
+		parsedOverrides, err := dotenvDetector(key)
+		if err != nil {
+			return resolvedConfig{}, err
+		}
+		overrides = parsedOverrides
+	} else if len(c.DynamicProxyDetector) > 0 && c.DetectorMode == "stream" {
+		parsedOverrides, err := c.streamDetectorOutput(r.Context(), key)
+		if err != nil {
+			return resolvedConfig{}, err
+		}
+		overrides = parsedOverrides
+	} else if len(c.DynamicProxyDetector) > 0 {
 		args := strings.Split(key, " ")
 		if len(args) == 0 || args[0] == "" {
 			return resolvedConfig{}, fmt.Errorf("dynamic proxy detector command is empty")
 		}
 
-		c.logger.Debug("running dynamic proxy detector",
+		c.requestLogger(r).Debug("running dynamic proxy detector",
 			zap.String("command", args[0]),
 			zap.Strings("args", args[1:]))
 
@@ -397,12 +788,13 @@ func (c *ReverseBin) resolveRequestConfig(r *http.Request, key string) (resolved
 		configureDetectorProcAttrs(detectorCmd)
 
 		var outBuf, errBuf bytes.Buffer
+		detectorCmd.Env = c.detectorEnv()
 		detectorCmd.Stdout = &outBuf
 		detectorCmd.Stderr = &errBuf
 
 		err := detectorCmd.Run()
 		if errBuf.Len() > 0 {
-			c.logger.Info("dynamic proxy detector stderr", zap.String("stderr", errBuf.String()))
+			c.requestLogger(r).Info("dynamic proxy detector stderr", zap.String("stderr", errBuf.String()))
 		}
 		if detCtx.Err() == context.DeadlineExceeded {
 			return resolvedConfig{}, fmt.Errorf("dynamic proxy detector timed out")
@@ -418,21 +810,56 @@ func (c *ReverseBin) resolveRequestConfig(r *http.Request, key string) (resolved
 	}
 
 	cfg := c.resolveConfig(overrides)
+	route := c.matchRoute(r)
+	if route >= 0 {
+		cfg.Executable = c.routeExecutable(r, route)
+		cfg.ReverseProxyTo = "unix/" + c.routeSockets[route]
+		cfg.HealthMethod, cfg.HealthPath, cfg.HealthStatus = "", "", 0
+	} else if overrides.Executable == nil || len(*overrides.Executable) == 0 {
+		cfg.Executable = expandArgs(r, cfg.Executable)
+	}
+	if overrides.Envs == nil {
+		cfg.Envs = expandArgs(r, cfg.Envs)
+	}
+	if len(c.EnvFromHeaders) > 0 {
+		cfg.Envs = append(slices.Clone(cfg.Envs), c.headerEnvs(r)...)
+	}
+	if overrides.ReverseProxyTo == nil && c.SocketTemplate != "" && route < 0 {
+		cfg.ReverseProxyTo = expandArgs(r, []string{c.SocketTemplate})[0]
+	}
+	if c.SocketType == socketTypeAbstract && isUnixUpstream(cfg.ReverseProxyTo) && !isAbstractSocket(strings.TrimPrefix(cfg.ReverseProxyTo, "unix/")) {
+		cfg.ReverseProxyTo = "unix/@" + strings.TrimPrefix(cfg.ReverseProxyTo, "unix/")
+	}
 	if len(cfg.Executable) == 0 {
+		if isURLUpstream(cfg.ReverseProxyTo) {
+			// An already running service, e.g. one found in a service
+			// registry: proxy to it without launching anything.
+			return cfg, nil
+		}
 		return resolvedConfig{}, fmt.Errorf("exec (executable) is required")
 	}
-	if !isUnixUpstream(cfg.ReverseProxyTo) && !healthConfigured(cfg.HealthMethod, cfg.HealthPath) {
+	if !isUnixUpstream(cfg.ReverseProxyTo) && !c.discoversPort(cfg) && !healthConfigured(cfg.HealthMethod, cfg.HealthPath) {
 		return resolvedConfig{}, fmt.Errorf("health_check is required for non-unix reverse_proxy_to targets")
 	}
-	if isUnixUpstream(cfg.ReverseProxyTo) {
-		socketPath := strings.TrimPrefix(cfg.ReverseProxyTo, "unix/")
-		if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
-			return resolvedConfig{}, fmt.Errorf("failed to remove pre-existing unix socket %s: %w", socketPath, err)
-		}
-	}
 	return cfg, nil
 }
 
+// removeStaleSocket deletes a unix socket left behind by an earlier backend
+// so readiness is not mistaken from it.
+func removeStaleSocket(cfg resolvedConfig) error {
+	if !isUnixUpstream(cfg.ReverseProxyTo) {
+		return nil
+	}
+	socketPath := strings.TrimPrefix(cfg.ReverseProxyTo, "unix/")
+	if isAbstractSocket(socketPath) {
+		return nil
+	}
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove pre-existing unix socket %s: %w", socketPath, err)
+	}
+	return nil
+}
+
 func (c *ReverseBin) probeHealth(ctx context.Context, cfg resolvedConfig, sourceReq *http.Request) (bool, healthProbeResult) {
 	result := healthProbeResult{
 		method: cfg.HealthMethod,
@@ -440,6 +867,9 @@ func (c *ReverseBin) probeHealth(ctx context.Context, cfg resolvedConfig, source
 		want:   healthWant(cfg.HealthStatus),
 	}
 	if cfg.HealthMethod == "" {
+		if c.portPattern != nil && !isUnixUpstream(cfg.ReverseProxyTo) {
+			return isTCPPortOpen(cfg.ReverseProxyTo), result
+		}
 		if !isUnixUpstream(cfg.ReverseProxyTo) {
 			result.err = fmt.Errorf("health_check is required for non-unix reverse_proxy_to targets")
 			return false, result
@@ -448,7 +878,7 @@ func (c *ReverseBin) probeHealth(ctx context.Context, cfg resolvedConfig, source
 	}
 
 	scheme := "http"
-	if strings.HasPrefix(cfg.ReverseProxyTo, "https://") {
+	if strings.HasPrefix(cfg.ReverseProxyTo, "https://") || c.BackendTLS != nil {
 		scheme = "https"
 	}
 
@@ -469,14 +899,12 @@ func (c *ReverseBin) probeHealth(ctx context.Context, cfg resolvedConfig, source
 	if isUnixUpstream(cfg.ReverseProxyTo) {
 		socketPath := strings.TrimPrefix(cfg.ReverseProxyTo, "unix/")
 		checkURL = fmt.Sprintf("%s://localhost%s", scheme, cfg.HealthPath)
-		client.Transport = &http.Transport{
-			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
-				var d net.Dialer
-				return d.DialContext(ctx, "unix", socketPath)
-			},
-		}
+		client.Transport = c.probeTransport("unix", socketPath)
 	} else {
 		checkURL = fmt.Sprintf("%s://%s%s", scheme, target, cfg.HealthPath)
+		if rt := c.probeTransport("tcp", target); rt != nil {
+			client.Transport = rt
+		}
 	}
 
 	req, err := http.NewRequestWithContext(ctx, cfg.HealthMethod, checkURL, nil)
@@ -534,11 +962,23 @@ func setForwardedHealthHeaders(healthReq *http.Request, sourceReq *http.Request)
 func (c *ReverseBin) waitHealthy(ctx context.Context, rb *runningBackend, cfg resolvedConfig, sourceReq *http.Request) error {
 	var last healthProbeResult
 	tickerInterval := 200 * time.Millisecond
-	if isUnixUpstream(cfg.ReverseProxyTo) && cfg.HealthMethod == "" {
-		tickerInterval = 50 * time.Millisecond
+	// Unix sockets are polled for until the backend binds them, before any
+	// health_check request is attempted.
+	socketPath := ""
+	var socketWait <-chan time.Time
+	if isUnixUpstream(cfg.ReverseProxyTo) {
+		socketPath = strings.TrimPrefix(cfg.ReverseProxyTo, "unix/")
+		tickerInterval = c.socketPollInterval()
+		if c.SocketWaitTimeoutMS > 0 {
+			timer := time.NewTimer(c.socketWaitTimeout())
+			defer timer.Stop()
+			socketWait = timer.C
+		}
 	}
 	ticker := time.NewTicker(tickerInterval)
 	defer ticker.Stop()
+	socketBound := socketPath == ""
+	socketSecured := false
 
 	for {
 		var done <-chan error
@@ -547,7 +987,7 @@ func (c *ReverseBin) waitHealthy(ctx context.Context, rb *runningBackend, cfg re
 		}
 		select {
 		case <-ctx.Done():
-			c.logger.Warn("health timeout",
+			c.requestLogger(sourceReq).Warn("health timeout",
 				zap.String("method", last.method),
 				zap.String("path", last.path),
 				zap.Int("last_status", last.status),
@@ -561,7 +1001,30 @@ func (c *ReverseBin) waitHealthy(ctx context.Context, rb *runningBackend, cfg re
 				rb.done <- err
 			}
 			return fmt.Errorf("reverse proxy process exited during health check: %v", err)
+		case <-socketWait:
+			return fmt.Errorf("backend did not bind unix socket %s within socket_wait_timeout_ms %d", socketPath, c.SocketWaitTimeoutMS)
 		case <-ticker.C:
+			if !socketBound {
+				if !isUnixSocketHealthy(socketPath) {
+					last.err = fmt.Errorf("unix socket %s is not bound yet", socketPath)
+					continue
+				}
+				socketBound = true
+				socketWait = nil
+				if cfg.HealthMethod != "" {
+					ticker.Reset(200 * time.Millisecond)
+				}
+			}
+			if !socketSecured {
+				secured, err := c.secureSocket(cfg)
+				if err != nil {
+					return err
+				}
+				if !secured {
+					continue
+				}
+				socketSecured = true
+			}
 			healthy, result := c.probeHealth(ctx, cfg, sourceReq)
 			if !healthy {
 				last = result
@@ -569,7 +1032,7 @@ func (c *ReverseBin) waitHealthy(ctx context.Context, rb *runningBackend, cfg re
 			}
 			if healthy {
 				if rb != nil && rb.process != nil {
-					c.logger.Info("reverse proxy process healthy", zap.Int("pid", rb.process.Pid), zap.String("address", cfg.ReverseProxyTo))
+					c.requestLogger(sourceReq).Info("reverse proxy process healthy", zap.Int("pid", rb.process.Pid()), zap.String("address", cfg.ReverseProxyTo))
 				}
 				return nil
 			}
@@ -581,12 +1044,16 @@ func (c *ReverseBin) stopBackend(rb *runningBackend, reason string, grace time.D
 	if rb == nil || rb.process == nil {
 		return nil
 	}
+	rb.stopping.Store(true)
 	c.logger.Info("terminating proxy subprocess",
-		zap.Int("pid", rb.process.Pid),
+		zap.Int("pid", rb.process.Pid()),
 		zap.String("reason", reason),
 		zap.Duration("grace", grace))
 
-	_ = signalProcessGroup(rb.process, syscall.SIGTERM)
+	if c.containerName != "" {
+		c.stopContainer(rb, grace)
+	}
+	_ = rb.process.Signal(syscall.SIGTERM)
 	if rb.cancel != nil {
 		rb.cancel()
 	}
@@ -599,14 +1066,14 @@ func (c *ReverseBin) stopBackend(rb *runningBackend, reason string, grace time.D
 		return err
 	case <-timer.C:
 		c.logger.Warn("proxy subprocess did not exit before grace timeout; killing",
-			zap.Int("pid", rb.process.Pid),
+			zap.Int("pid", rb.process.Pid()),
 			zap.String("reason", reason))
-		_ = signalProcessGroup(rb.process, syscall.SIGKILL)
+		_ = rb.process.Signal(syscall.SIGKILL)
 		select {
 		case err := <-rb.done:
 			return err
 		case <-time.After(c.terminationKillWait()):
-			return fmt.Errorf("timeout waiting for process %d after SIGKILL", rb.process.Pid)
+			return fmt.Errorf("timeout waiting for process %d after SIGKILL", rb.process.Pid())
 		}
 	}
 }
@@ -619,6 +1086,13 @@ type supervisorRequest struct {
 type supervisorResult struct {
 	upstream string
 	err      error
+	// backend is the process serving upstream, if this handler started one.
+	backend *runningBackend
+	// startup is how long this request waited for a backend to launch.
+	startup time.Duration
+	// startFailed is set when err is a backend that failed to launch or
+	// become healthy, which startup_timeout_action may retry.
+	startFailed bool
 }
 
 type supervisorCommandKind int
@@ -626,7 +1100,14 @@ type supervisorCommandKind int
 const (
 	supervisorRequestStarted supervisorCommandKind = iota
 	supervisorRequestDone
+	// supervisorStop stops the command's backend right away.
 	supervisorStop
+	// supervisorRestart stops the backend once in-flight requests are done.
+	supervisorRestart
+	// supervisorLivenessFailed stops the command's backend right away.
+	supervisorLivenessFailed
+	// supervisorEvictIdle stops the backend if it is still idle.
+	supervisorEvictIdle
 	supervisorShutdown
 )
 
@@ -634,18 +1115,25 @@ type supervisorCommand struct {
 	kind   supervisorCommandKind
 	reason string
 	reply  chan error
+	// backend is the backend a supervisorStop or supervisorLivenessFailed
+	// refers to; one that has since been replaced is left alone.
+	backend *runningBackend
 }
 
 func (c *ReverseBin) sendSupervisorCommand(ps *processState, kind supervisorCommandKind, reason string) error {
-	reply := make(chan error, 1)
-	cmd := supervisorCommand{kind: kind, reason: reason, reply: reply}
+	return c.sendCommand(ps, supervisorCommand{kind: kind, reason: reason})
+}
+
+// sendCommand delivers cmd to the supervisor of ps and waits for its reply.
+func (c *ReverseBin) sendCommand(ps *processState, cmd supervisorCommand) error {
+	cmd.reply = make(chan error, 1)
 	select {
 	case ps.commands <- cmd:
 	case <-c.done():
 		return c.doneErr()
 	}
 	select {
-	case err := <-reply:
+	case err := <-cmd.reply:
 		return err
 	case <-c.done():
 		return c.doneErr()
@@ -703,8 +1191,20 @@ func (c *ReverseBin) runSupervisor(ps *processState) {
 	var idleTimer *time.Timer
 	var idleC <-chan time.Time
 	activeRequests := int64(0)
+	// restartReason is set while a restart waits for requests to drain;
+	// requests arriving meanwhile are held in pending until it is done.
+	restartReason := ""
+	var pending []supervisorRequest
+	var drainTimer *time.Timer
+	var drainC <-chan time.Time
 	idleTimeout := time.Duration(c.IdleTimeoutMS) * time.Millisecond
 
+	// clearIdle stops the idle timer and takes the backend off the
+	// max_idle_processes list.
+	clearIdle := func() {
+		stopTimer(&idleTimer, &idleC)
+		c.app.markBusy(ps)
+	}
 	startIdleTimer := func() {
 		if backend == nil || activeRequests != 0 {
 			return
@@ -713,74 +1213,229 @@ func (c *ReverseBin) runSupervisor(ps *processState) {
 		idleTimer = time.NewTimer(idleTimeout)
 		idleC = idleTimer.C
 		c.logger.Debug("starting idle timer", zap.String("key", ps.key), zap.Duration("duration", idleTimeout))
+		c.app.markIdle(c, ps)
+	}
+	stopIdle := func(reason string) {
+		clearIdle()
+		if backend != nil {
+			c.runLifecycleHook(lifecycleIdleTimeout, backend.process.Pid(), backend.config)
+		}
+		c.runShutdownCommand(backend)
+		_ = c.stopBackend(backend, reason, c.terminationGrace())
+		backend = nil
 	}
 
 	shutdown := func(reason string) error {
-		stopTimer(&idleTimer, &idleC)
+		clearIdle()
 		err := c.stopBackend(backend, reason, c.terminationGrace())
 		backend = nil
 		return err
 	}
+	// release gives up the backend when this handler instance goes away,
+	// parking it for the next config when hot_config_reload allows.
+	release := func(reason string) error {
+		if c.shouldParkBackend(backend) {
+			clearIdle()
+			c.parkBackend(ps.key, backend)
+			backend = nil
+			return nil
+		}
+		return shutdown(reason)
+	}
 
-	for {
-		select {
-		case req := <-ps.requests:
-			stopTimer(&idleTimer, &idleC)
+	// serveRequest replies to req with the upstream of the running backend,
+	// launching one first if needed.
+	serveRequest := func(req supervisorRequest) {
+		clearIdle()
+		var startup time.Duration
 
-			if backend != nil && backendExited(backend) {
+		if backend != nil && backendExited(backend) {
+			backend = nil
+		}
+		if backend != nil && isUnixUpstream(backend.config.ReverseProxyTo) {
+			socketPath := strings.TrimPrefix(backend.config.ReverseProxyTo, "unix/")
+			if !isUnixSocketHealthy(socketPath) {
+				c.logger.Warn("backend process alive but unix socket unavailable; restarting",
+					zap.String("key", ps.key),
+					zap.Int("pid", backend.process.Pid()),
+					zap.String("socket", socketPath))
+				_ = c.stopBackend(backend, "unix socket unavailable", c.terminationGrace())
 				backend = nil
-			}
-			if backend != nil && isUnixUpstream(backend.config.ReverseProxyTo) {
-				socketPath := strings.TrimPrefix(backend.config.ReverseProxyTo, "unix/")
-				if !isUnixSocketHealthy(socketPath) {
-					c.logger.Warn("backend process alive but unix socket unavailable; restarting",
-						zap.String("key", ps.key),
-						zap.Int("pid", backend.process.Pid),
-						zap.String("socket", socketPath))
-					_ = c.stopBackend(backend, "unix socket unavailable", c.terminationGrace())
-					backend = nil
+				if !isAbstractSocket(socketPath) {
 					_ = os.Remove(socketPath)
 				}
 			}
+		}
 
-			if backend == nil {
-				cfg, err := c.resolveRequestConfig(req.request, ps.key)
-				if err != nil {
-					req.reply <- supervisorResult{err: err}
-					continue
+		if backend == nil {
+			cfg, err := c.resolveRequestConfig(req.request, ps.key)
+			if err != nil {
+				req.reply <- supervisorResult{err: err}
+				return
+			}
+			if len(cfg.Executable) == 0 {
+				req.reply <- supervisorResult{upstream: cfg.ReverseProxyTo}
+				return
+			}
+			if c.HotConfigReload {
+				if rb := c.adoptParkedBackend(ps.key, cfg); rb != nil {
+					backend = rb
+					ps.status.launched(rb)
+					if c.LivenessMethod != "" {
+						go c.monitorLiveness(ps, rb)
+					}
+					req.reply <- supervisorResult{upstream: rb.config.ReverseProxyTo, backend: rb}
+					return
 				}
-				startCtx, cancel := context.WithTimeout(req.request.Context(), c.healthTimeout())
-				rb, err := c.launchBackend(c.moduleContext(), cfg, "request")
-				if err == nil {
-					err = c.waitHealthy(startCtx, rb, cfg, req.request)
+			}
+			if c.SocketPathRotate {
+				if ps.generation > 0 {
+					// The previous generation's backend has stopped,
+					// so nothing listens on its socket any more.
+					_ = removeStaleSocket(rotateSocket(cfg, ps.generation))
 				}
-				cancel()
-				if err != nil {
-					_ = c.stopBackend(rb, "health failed", c.terminationGrace())
+				ps.generation++
+				cfg = rotateSocket(cfg, ps.generation)
+			}
+			// An activated socket belongs to systemd; removing its file
+			// would make it unreachable.
+			if c.activation == nil && (c.SocketCleanupOnStart == nil || *c.SocketCleanupOnStart) {
+				if err := removeStaleSocket(cfg); err != nil {
 					req.reply <- supervisorResult{err: err}
-					continue
+					return
 				}
-				backend = rb
 			}
-			req.reply <- supervisorResult{upstream: backend.config.ReverseProxyTo}
+			startCtx, cancel := context.WithTimeout(req.request.Context(), c.healthTimeout())
+			launched := time.Now()
+			var rb *runningBackend
+			if len(c.StartupCommand) > 0 {
+				err = c.runHookCommand(startCtx, "startup_command", c.StartupCommand, cfg)
+			}
+			if err == nil {
+				rb, err = c.launchBackend(c.backendContext(), cfg, "request")
+			}
+			if err == nil && rb.port != nil {
+				cfg.ReverseProxyTo, err = c.awaitPort(startCtx, rb)
+				rb.config = cfg
+			}
+			if err == nil {
+				err = c.waitHealthy(startCtx, rb, cfg, req.request)
+			}
+			cancel()
+			startup = time.Since(launched)
+			if err != nil {
+				_ = c.stopBackend(rb, "health failed", c.terminationGrace())
+				ps.status.failed(err)
+				req.reply <- supervisorResult{err: err, startup: startup, startFailed: true}
+				return
+			}
+			backend = rb
+			ps.status.launched(rb)
+			c.runLifecycleHook(lifecycleReady, rb.process.Pid(), cfg)
+			if c.activation != nil {
+				c.notifyBackendReady(rb)
+			}
+			if c.LivenessMethod != "" {
+				go c.monitorLiveness(ps, rb)
+			}
+		}
+		req.reply <- supervisorResult{upstream: backend.config.ReverseProxyTo, backend: backend, startup: startup}
+	}
+	// finishDrain stops the backend being restarted once every request in
+	// flight on it is done, or right away if force is set, and then serves
+	// the requests queued meanwhile. Requests that are counted as active
+	// but still waiting for an upstream are in pending, not on the backend.
+	finishDrain := func(force bool) {
+		if restartReason == "" || !force && activeRequests > int64(len(pending)) {
+			return
+		}
+		stopTimer(&drainTimer, &drainC)
+		// The backend's exit status after SIGTERM is not a failure of the
+		// restart.
+		_ = shutdown(restartReason)
+		restartReason = ""
+		queued := pending
+		pending = nil
+		for _, req := range queued {
+			serveRequest(req)
+		}
+	}
+	// failPending answers the queued requests when the supervisor exits.
+	failPending := func(err error) {
+		for _, req := range pending {
+			req.reply <- supervisorResult{err: err}
+		}
+		pending = nil
+	}
+
+	for {
+		select {
+		case req := <-ps.requests:
+			if restartReason != "" {
+				// New requests wait for the restart rather than reaching
+				// the backend being drained.
+				pending = append(pending, req)
+				finishDrain(false)
+				continue
+			}
+			serveRequest(req)
 
 		case cmd := <-ps.commands:
 			var err error
 			switch cmd.kind {
 			case supervisorRequestStarted:
 				activeRequests++
-				stopTimer(&idleTimer, &idleC)
+				ps.status.request()
+				clearIdle()
 			case supervisorRequestDone:
 				if activeRequests > 0 {
 					activeRequests--
 				}
-				if activeRequests == 0 {
+				if restartReason != "" {
+					finishDrain(false)
+				} else if activeRequests == 0 {
 					startIdleTimer()
 				}
 			case supervisorStop:
+				if backend != cmd.backend {
+					break
+				}
 				err = shutdown(cmd.reason)
+				finishDrain(true)
+			case supervisorRestart:
+				if backend == nil || restartReason != "" {
+					break
+				}
+				restartReason = cmd.reason
+				if activeRequests > 0 {
+					c.logger.Info("draining in-flight requests before restart",
+						zap.String("key", ps.key),
+						zap.Int64("active_requests", activeRequests),
+						zap.Duration("timeout", c.restartDrainTimeout()),
+						zap.String("reason", cmd.reason))
+					drainTimer = time.NewTimer(c.restartDrainTimeout())
+					drainC = drainTimer.C
+				}
+				finishDrain(false)
+			case supervisorLivenessFailed:
+				if backend != cmd.backend {
+					break
+				}
+				// A backend failing its liveness check is not expected to
+				// finish its in-flight requests, so they are not drained.
+				_ = shutdown(cmd.reason)
+				finishDrain(true)
+			case supervisorEvictIdle:
+				// A request may have arrived since the backend was picked.
+				if idleC == nil {
+					break
+				}
+				c.logger.Info("max_idle_processes exceeded, terminating least recently used process", zap.String("key", ps.key))
+				stopIdle(cmd.reason)
 			case supervisorShutdown:
-				err = shutdown(cmd.reason)
+				failPending(c.doneErr())
+				stopTimer(&drainTimer, &drainC)
+				err = release(cmd.reason)
 				if cmd.reply != nil {
 					cmd.reply <- err
 				}
@@ -789,16 +1444,29 @@ func (c *ReverseBin) runSupervisor(ps *processState) {
 			if cmd.reply != nil {
 				cmd.reply <- err
 			}
+			if cmd.kind == supervisorRequestDone && c.PerRequestProcess && activeRequests == 0 {
+				// Stopped after replying, so the response is not held up.
+				clearIdle()
+				c.finishPerRequest(ps, backend)
+				return
+			}
 
 		case <-idleC:
 			c.logger.Info("idle timer fired, terminating process", zap.String("key", ps.key))
-			_ = c.stopBackend(backend, "idle timeout", c.terminationGrace())
-			backend = nil
-			idleTimer = nil
-			idleC = nil
+			stopIdle("idle timeout")
+
+		case <-drainC:
+			drainTimer, drainC = nil, nil
+			c.logger.Warn("in-flight requests did not finish before restart_drain_timeout_ms; restarting anyway",
+				zap.String("key", ps.key),
+				zap.Int64("active_requests", activeRequests-int64(len(pending))),
+				zap.String("reason", restartReason))
+			finishDrain(true)
 
 		case <-c.done():
-			_ = shutdown("context done")
+			failPending(c.doneErr())
+			stopTimer(&drainTimer, &drainC)
+			_ = release("context done")
 			return
 		}
 	}
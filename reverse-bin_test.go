@@ -2,56 +2,241 @@ package reversebin
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/caddyserver/caddy/v2"
 	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/headers"
 	"go.uber.org/zap/zaptest"
 )
 
 type reverseBinConfig struct {
-	Executable            []string
-	WorkingDirectory      string
-	Envs                  []string
-	PassEnvs              []string
-	PassAll               bool
-	ReverseProxyTo        string
-	HealthMethod          string
-	HealthPath            string
-	HealthStatus          int
-	DynamicProxyDetector  []string
-	IdleTimeoutMS         int
-	HealthTimeoutMS       int
-	TerminationGraceMS    int
-	TerminationKillWaitMS int
+	Executable               []string
+	WorkingDirectory         string
+	Envs                     []string
+	PassEnvs                 []string
+	PassAll                  bool
+	ReverseProxyTo           string
+	ReverseProxyToSecondary  string
+	HealthMethod             string
+	HealthPath               string
+	HealthStatus             int
+	DynamicProxyDetector     []string
+	DetectorMode             string
+	DetectorEnvs             []string
+	Inspect                  bool
+	HotConfigReload          bool
+	StatusPagePath           string
+	StatusPageSecret         string
+	DecompressResponse       bool
+	RetryOnFailure           int
+	RetryUnsafeMethods       bool
+	RequestLog               string
+	CacheTTLMS               int
+	CacheMaxSize             int64
+	RateLimit                string
+	CircuitBreakerThreshold  int
+	CircuitBreakerOpenMS     int
+	Routes                   []PathRoute
+	StartupCommand           []string
+	ShutdownCommand          []string
+	ShutdownCommandTimeoutMS int
+	CgroupPath               string
+	SocketType               string
+	IdleTimeoutMS            int
+	HealthTimeoutMS          int
+	TerminationGraceMS       int
+	TerminationKillWaitMS    int
+	RelayExpectContinue      bool
+	BackendProto             string
+	DynamicProxyDetectorHTTP []string
+	DetectorHTTPTimeoutMS    int
+	DetectorHTTPUser         string
+	DetectorHTTPPassword     string
+	BackendTLS               *BackendTLS
+	BackendFollowRedirects   bool
+	BackendMaxRedirects      int
+	EnvFiles                 []string
+	AccessLogBackendLatency  bool
+	ProcessNamespaces        []string
+	StatusOverrides          map[int]int
+	SocketTemplate           string
+	SocketMode               string
+	SocketGroup              string
+	SocketCleanupOnStart     *bool
+	SocketActivation         *int
+	PortDiscoveryPattern     string
+	PortDiscoveryMaxBytes    int64
+	WatchFiles               []string
+	RestartSchedule          string
+	RestartDrainTimeoutMS    int
+	TracingHeaders           *bool
+	RequestIDHeader          string
+	ResponseHeaderTimeoutMS  int
+	ResponseIdleTimeoutMS    int
+	ResponseHeaders          *headers.HeaderOps
+	UpstreamHeaders          *headers.HeaderOps
+	ResponseBufferSize       int64
+	RunAs                    string
+	MaxMemory                int64
+	CPUShares                int
+	MaxRequestBodySize       int64
+	LivenessMethod           string
+	LivenessPath             string
+	LivenessStatus           int
+	LivenessIntervalMS       int
+	LivenessFailureThreshold int
+	SocketWaitTimeoutMS      int
+	SocketPollIntervalMS     int
+	ProxyProtocol            string
+	Keepalive                *bool
+	KeepaliveIdleMS          int
+	KeepaliveIntervalMS      int
+	KeepalivePoolSize        int
+	BackendConnectTimeoutMS  int
+	RedactEnvPattern         string
+	StdioMode                bool
+	StdioContentType         string
+	ResponseRewrites         []ResponseRewrite
+	HealthAddr               string
+	DockerImage              string
+	AllowedIPs               []string
+	EnvFromHeaders           []EnvFromHeader
+	ProxyHeadersFromRequest  []string
+	PerRequestProcess        bool
+	SocketPathRotate         bool
+	StartupTimeoutAction     string
+	StartupRetryDelayMS      int
+	StartupAddressPattern    string
+	StartupAddressMaxBytes   int64
+	ErrorBodyFormat          string
+	BackendMaxConns          int
+	LifecycleHook            []string
+	ResponseBufferOverflow   string
 }
 
 func asConfig(c *ReverseBin) reverseBinConfig {
 	return reverseBinConfig{
-		Executable:            c.Executable,
-		WorkingDirectory:      c.WorkingDirectory,
-		Envs:                  c.Envs,
-		PassEnvs:              c.PassEnvs,
-		PassAll:               c.PassAll,
-		ReverseProxyTo:        c.ReverseProxyTo,
-		HealthMethod:          c.HealthMethod,
-		HealthPath:            c.HealthPath,
-		HealthStatus:          c.HealthStatus,
-		DynamicProxyDetector:  c.DynamicProxyDetector,
-		IdleTimeoutMS:         c.IdleTimeoutMS,
-		HealthTimeoutMS:       c.HealthTimeoutMS,
-		TerminationGraceMS:    c.TerminationGraceMS,
-		TerminationKillWaitMS: c.TerminationKillWaitMS,
+		Executable:               c.Executable,
+		WorkingDirectory:         c.WorkingDirectory,
+		Envs:                     c.Envs,
+		PassEnvs:                 c.PassEnvs,
+		PassAll:                  c.PassAll,
+		ReverseProxyTo:           c.ReverseProxyTo,
+		ReverseProxyToSecondary:  c.ReverseProxyToSecondary,
+		HealthMethod:             c.HealthMethod,
+		HealthPath:               c.HealthPath,
+		HealthStatus:             c.HealthStatus,
+		DynamicProxyDetector:     c.DynamicProxyDetector,
+		DetectorMode:             c.DetectorMode,
+		DetectorEnvs:             c.DetectorEnvs,
+		Inspect:                  c.Inspect,
+		HotConfigReload:          c.HotConfigReload,
+		StatusPagePath:           c.StatusPagePath,
+		StatusPageSecret:         c.StatusPageSecret,
+		DecompressResponse:       c.DecompressResponse,
+		RetryOnFailure:           c.RetryOnFailure,
+		RetryUnsafeMethods:       c.RetryUnsafeMethods,
+		RequestLog:               c.RequestLog,
+		CacheTTLMS:               c.CacheTTLMS,
+		CacheMaxSize:             c.CacheMaxSize,
+		RateLimit:                c.RateLimit,
+		CircuitBreakerThreshold:  c.CircuitBreakerThreshold,
+		CircuitBreakerOpenMS:     c.CircuitBreakerOpenDurationMS,
+		Routes:                   c.Routes,
+		StartupCommand:           c.StartupCommand,
+		ShutdownCommand:          c.ShutdownCommand,
+		ShutdownCommandTimeoutMS: c.ShutdownCommandTimeoutMS,
+		CgroupPath:               c.CgroupPath,
+		SocketType:               c.SocketType,
+		IdleTimeoutMS:            c.IdleTimeoutMS,
+		HealthTimeoutMS:          c.HealthTimeoutMS,
+		TerminationGraceMS:       c.TerminationGraceMS,
+		TerminationKillWaitMS:    c.TerminationKillWaitMS,
+		RelayExpectContinue:      c.RelayExpectContinue,
+		BackendProto:             c.BackendProto,
+		DynamicProxyDetectorHTTP: c.DynamicProxyDetectorHTTP,
+		DetectorHTTPTimeoutMS:    c.DetectorHTTPTimeoutMS,
+		DetectorHTTPUser:         c.DetectorHTTPUser,
+		DetectorHTTPPassword:     c.DetectorHTTPPassword,
+		BackendTLS:               c.BackendTLS,
+		BackendFollowRedirects:   c.BackendFollowRedirects,
+		BackendMaxRedirects:      c.BackendMaxRedirects,
+		EnvFiles:                 c.EnvFiles,
+		AccessLogBackendLatency:  c.AccessLogBackendLatency,
+		ProcessNamespaces:        c.ProcessNamespaces,
+		StatusOverrides:          c.StatusOverrides,
+		SocketTemplate:           c.SocketTemplate,
+		SocketMode:               c.SocketMode,
+		SocketGroup:              c.SocketGroup,
+		SocketCleanupOnStart:     c.SocketCleanupOnStart,
+		SocketActivation:         c.SocketActivation,
+		PortDiscoveryPattern:     c.PortDiscoveryPattern,
+		PortDiscoveryMaxBytes:    c.PortDiscoveryMaxBytes,
+		WatchFiles:               c.WatchFiles,
+		RestartSchedule:          c.RestartSchedule,
+		RestartDrainTimeoutMS:    c.RestartDrainTimeoutMS,
+		TracingHeaders:           c.TracingHeaders,
+		RequestIDHeader:          c.RequestIDHeader,
+		ResponseHeaderTimeoutMS:  c.ResponseHeaderTimeoutMS,
+		ResponseIdleTimeoutMS:    c.ResponseIdleTimeoutMS,
+		ResponseHeaders:          c.ResponseHeaders,
+		UpstreamHeaders:          c.UpstreamHeaders,
+		ResponseBufferSize:       c.ResponseBufferSize,
+		RunAs:                    c.RunAs,
+		MaxMemory:                c.MaxMemory,
+		CPUShares:                c.CPUShares,
+		MaxRequestBodySize:       c.MaxRequestBodySize,
+		LivenessMethod:           c.LivenessMethod,
+		LivenessPath:             c.LivenessPath,
+		LivenessStatus:           c.LivenessStatus,
+		LivenessIntervalMS:       c.LivenessIntervalMS,
+		LivenessFailureThreshold: c.LivenessFailureThreshold,
+		SocketWaitTimeoutMS:      c.SocketWaitTimeoutMS,
+		SocketPollIntervalMS:     c.SocketPollIntervalMS,
+		ProxyProtocol:            c.ProxyProtocol,
+		Keepalive:                c.Keepalive,
+		KeepaliveIdleMS:          c.KeepaliveIdleMS,
+		KeepaliveIntervalMS:      c.KeepaliveIntervalMS,
+		KeepalivePoolSize:        c.KeepalivePoolSize,
+		BackendConnectTimeoutMS:  c.BackendConnectTimeoutMS,
+		RedactEnvPattern:         c.RedactEnvPattern,
+		StdioMode:                c.StdioMode,
+		StdioContentType:         c.StdioContentType,
+		ResponseRewrites:         c.ResponseRewrites,
+		HealthAddr:               c.HealthAddr,
+		DockerImage:              c.DockerImage,
+		AllowedIPs:               c.AllowedIPs,
+		EnvFromHeaders:           c.EnvFromHeaders,
+		ProxyHeadersFromRequest:  c.ProxyHeadersFromRequest,
+		PerRequestProcess:        c.PerRequestProcess,
+		SocketPathRotate:         c.SocketPathRotate,
+		StartupTimeoutAction:     c.StartupTimeoutAction,
+		StartupRetryDelayMS:      c.StartupRetryDelayMS,
+		StartupAddressPattern:    c.StartupAddressPattern,
+		StartupAddressMaxBytes:   c.StartupAddressMaxBytes,
+		ErrorBodyFormat:          c.ErrorBodyFormat,
+		BackendMaxConns:          c.BackendMaxConns,
+		LifecycleHook:            c.LifecycleHook,
+		ResponseBufferOverflow:   c.ResponseBufferOverflow,
 	}
 }
 
@@ -103,6 +288,45 @@ func TestSanitizeArgsForLogRedactsSensitiveAssignments(t *testing.T) {
 	}
 }
 
+// TestEnvFromHeaderInjectsSanitizedValues verifies env_from_header values reach the backend config without line breaks and select a backend per value.
+func TestEnvFromHeaderInjectsSanitizedValues(t *testing.T) {
+	c := &ReverseBin{
+		Executable:     []string{"./app"},
+		ReverseProxyTo: "unix/app.sock",
+		Envs:           []string{"TENANT_ID=default"},
+		EnvFromHeaders: []EnvFromHeader{{Header: "X-Tenant-Id", Env: "TENANT_ID"}, {Header: "X-Region", Env: "REGION"}},
+	}
+	newReq := func(tenant string) *http.Request {
+		// This HTTP request tests a client-supplied tenant header.
+		req := httptest.NewRequest(http.MethodGet, "http://app.example/", nil)
+		req.Header.Set("X-Tenant-Id", tenant)
+		return caddyhttp.PrepareRequest(req, caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+	}
+
+	req := newReq("acme\r\nLD_PRELOAD=/tmp/x.so")
+	cfg, err := c.resolveRequestConfig(req, c.getProcessKey(req))
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	want := []string{"TENANT_ID=default", "TENANT_ID=acmeLD_PRELOAD=/tmp/x.so"}
+	if !reflect.DeepEqual(cfg.Envs, want) {
+		t.Fatalf("envs = %q, want %q", cfg.Envs, want)
+	}
+	if c.getProcessKey(newReq("acme")) == c.getProcessKey(newReq("globex")) {
+		t.Fatal("expected different tenants to get different process keys")
+	}
+}
+
+// TestSanitizeForLogUsesRedactEnvPattern verifies redact_env_pattern replaces the built-in pattern.
+func TestSanitizeForLogUsesRedactEnvPattern(t *testing.T) {
+	c := &ReverseBin{redactPattern: regexp.MustCompile(`^DATABASE_URL$`)}
+	got := c.sanitizeForLog([]string{"DATABASE_URL=postgres://u:p@db/app", "API_TOKEN=abc"})
+	want := []string{"DATABASE_URL=<redacted>", "API_TOKEN=abc"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("sanitizeForLog() = %#v, want %#v", got, want)
+	}
+}
+
 // TestProcessKillPlanUnix verifies Unix process groups are targeted by negative PID.
 func TestProcessKillPlanUnix(t *testing.T) {
 	plan := processKillPlan("linux", 1234, syscall.SIGTERM)
@@ -129,6 +353,10 @@ func testStringPtr(s string) *string {
 	return &s
 }
 
+func testBoolPtr(b bool) *bool {
+	return &b
+}
+
 func testIntPtr(i int) *int {
 	return &i
 }
@@ -185,6 +413,21 @@ func TestParseDetectorOutputRejectsInvalidJSON(t *testing.T) {
 			input:   `{"reverseProxyTo":"127.0.0.1:8080"}`,
 			wantErr: `unknown field "reverseProxyTo"`,
 		},
+		{
+			name:    "wrong field type names field and value",
+			input:   `{"executable":"./server"}`,
+			wantErr: `executable must be an array of strings, got "./server"`,
+		},
+		{
+			name:    "unknown field names its value",
+			input:   `{"reverse_proxy_to":"unix//tmp/app.sock","port":8080}`,
+			wantErr: `unknown field "port" with value 8080`,
+		},
+		{
+			name:    "neither executable nor reverse_proxy_to",
+			input:   `{"health_path":"/healthz"}`,
+			wantErr: "at least one of executable or reverse_proxy_to is required",
+		},
 		{
 			name:    "trailing JSON value",
 			input:   `{"reverse_proxy_to":"unix//tmp/app.sock"} {}`,
@@ -274,6 +517,213 @@ func TestParseDetectorOutputAcceptsValidContract(t *testing.T) {
 	}
 }
 
+// TestResolveRequestConfigFetchesHTTPDetector verifies HTTP detector JSON overrides static config like a detector binary would.
+func TestResolveRequestConfigFetchesHTTPDetector(t *testing.T) {
+	detector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// This HTTP request tests the detector receives the expanded path and basic auth credentials.
+		user, pass, ok := r.BasicAuth()
+		if r.Method != http.MethodGet || r.URL.Query().Get("path") != "/app/x" || !ok || user != "rb" || pass != "pw" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = io.WriteString(w, `{"executable":["./server"],"reverse_proxy_to":"127.0.0.1:9000","health_method":"GET","health_path":"/health"}`)
+	}))
+	defer detector.Close()
+
+	rb := &ReverseBin{
+		DynamicProxyDetectorHTTP: []string{http.MethodGet, detector.URL + "/detect?path={http.request.uri.path}"},
+		DetectorHTTPUser:         "rb",
+		DetectorHTTPPassword:     "pw",
+		DetectorHTTPTimeoutMS:    1000,
+		logger:                   zaptest.NewLogger(t),
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/app/x", nil)
+	repl := caddy.NewReplacer()
+	req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, repl))
+	repl.Set("http.request.uri.path", req.URL.Path)
+
+	cfg, err := rb.resolveRequestConfig(req, rb.getProcessKey(req))
+	if err != nil {
+		t.Fatalf("resolveRequestConfig: %v", err)
+	}
+	if got := strings.Join(cfg.Executable, " "); got != "./server" || cfg.ReverseProxyTo != "127.0.0.1:9000" {
+		t.Fatalf("expected detector overrides ./server -> 127.0.0.1:9000, got %q -> %q", got, cfg.ReverseProxyTo)
+	}
+}
+
+// TestResolveRequestConfigReadsStreamDetector verifies a stream detector starts once, skips invalid lines and serves later requests from its last line.
+func TestResolveRequestConfigReadsStreamDetector(t *testing.T) {
+	dir := t.TempDir()
+	starts := filepath.Join(dir, "starts")
+	script := filepath.Join(dir, "detect.sh")
+	body := "#!/bin/sh\necho start >> \"$1\"\necho not-json\necho '{\"executable\":[\"./server\"],\"reverse_proxy_to\":\"unix//tmp/app.sock\"}'\nexec sleep 60\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatalf("write detector: %v", err)
+	}
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+	rb := &ReverseBin{
+		DynamicProxyDetector: []string{script, starts},
+		DetectorMode:         "stream",
+		HealthTimeoutMS:      5000,
+		ctx:                  ctx,
+		logger:               zaptest.NewLogger(t),
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, caddy.NewReplacer()))
+
+	for range 2 {
+		cfg, err := rb.resolveRequestConfig(req, strings.Join(rb.DynamicProxyDetector, " "))
+		if err != nil {
+			t.Fatalf("resolveRequestConfig: %v", err)
+		}
+		if got := strings.Join(cfg.Executable, " "); got != "./server" || cfg.ReverseProxyTo != "unix//tmp/app.sock" {
+			t.Fatalf("expected stream overrides ./server -> unix//tmp/app.sock, got %q -> %q", got, cfg.ReverseProxyTo)
+		}
+	}
+	data, err := os.ReadFile(starts)
+	if err != nil {
+		t.Fatalf("read starts: %v", err)
+	}
+	if string(data) != "start\n" {
+		t.Fatalf("expected detector to start once, got %q", data)
+	}
+}
+
+// TestResolveRequestConfigProxiesDetectorURLWithoutLaunching verifies a detector may point at an already running service by URL without an executable.
+func TestResolveRequestConfigProxiesDetectorURLWithoutLaunching(t *testing.T) {
+	detector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// This HTTP request tests a service-registry style detector that only returns a URL.
+		_, _ = io.WriteString(w, `{"reverse_proxy_to":"https://api.service.consul"}`)
+	}))
+	defer detector.Close()
+
+	rb := &ReverseBin{
+		DynamicProxyDetectorHTTP: []string{http.MethodGet, detector.URL},
+		DetectorHTTPTimeoutMS:    1000,
+		logger:                   zaptest.NewLogger(t),
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, caddy.NewReplacer()))
+
+	cfg, err := rb.resolveRequestConfig(req, rb.getProcessKey(req))
+	if err != nil {
+		t.Fatalf("resolveRequestConfig: %v", err)
+	}
+	if len(cfg.Executable) != 0 || cfg.ReverseProxyTo != "https://api.service.consul" {
+		t.Fatalf("expected no executable and upstream https://api.service.consul, got %q -> %q", cfg.Executable, cfg.ReverseProxyTo)
+	}
+}
+
+// TestBuiltinDotenvDetectorReadsAppEnvFile verifies builtin:dotenv turns EXEC and REVERSE_PROXY_TO from <appdir>/.env into detector output.
+func TestBuiltinDotenvDetectorReadsAppEnvFile(t *testing.T) {
+	appDir := t.TempDir()
+	dotenv := "# app config\nEXEC=./server --port 9000\nREVERSE_PROXY_TO=unix//tmp/app.sock\nOTHER=ignored\n"
+	if err := os.WriteFile(filepath.Join(appDir, ".env"), []byte(dotenv), 0o644); err != nil {
+		t.Fatalf("write .env: %v", err)
+	}
+	rb := &ReverseBin{DynamicProxyDetector: []string{"builtin:dotenv", "{http.request.host}"}, logger: zaptest.NewLogger(t)}
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+	repl := caddy.NewReplacer()
+	repl.Set("http.request.host", appDir)
+	req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, repl))
+
+	cfg, err := rb.resolveRequestConfig(req, rb.getProcessKey(req))
+	if err != nil {
+		t.Fatalf("resolveRequestConfig: %v", err)
+	}
+	if got := strings.Join(cfg.Executable, " "); got != "./server --port 9000" || cfg.ReverseProxyTo != "unix//tmp/app.sock" || cfg.WorkingDirectory != appDir {
+		t.Fatalf("expected ./server --port 9000 -> unix//tmp/app.sock in %s, got %q -> %q in %s", appDir, got, cfg.ReverseProxyTo, cfg.WorkingDirectory)
+	}
+
+	for _, detector := range [][]string{{"builtin:dotenv"}, {"builtin:yaml", appDir}} {
+		ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+		err := (&ReverseBin{DynamicProxyDetector: detector}).Provision(ctx)
+		cancel()
+		if err == nil {
+			t.Fatalf("expected Provision to reject dynamic_proxy_detector %q", detector)
+		}
+	}
+}
+
+// TestDetectorEnvReachesDetectorOnly verifies detector_env is visible to the detector but not to the backend, even with pass_all_env.
+func TestDetectorEnvReachesDetectorOnly(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "detect.sh")
+	body := "#!/bin/sh\necho '{\"executable\":[\"./server\",\"'\"$DB_URL\"'\"],\"reverse_proxy_to\":\"unix//tmp/app.sock\"}'\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatalf("write detector: %v", err)
+	}
+	rb := &ReverseBin{
+		DynamicProxyDetector: []string{script},
+		DetectorEnvs:         []string{"DB_URL=postgres://config"},
+		PassAll:              true,
+		HealthTimeoutMS:      5000,
+		logger:               zaptest.NewLogger(t),
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, caddy.NewReplacer()))
+
+	cfg, err := rb.resolveRequestConfig(req, rb.getProcessKey(req))
+	if err != nil {
+		t.Fatalf("resolveRequestConfig: %v", err)
+	}
+	if got := strings.Join(cfg.Executable, " "); got != "./server postgres://config" {
+		t.Fatalf("expected detector to see DB_URL, got executable %q", got)
+	}
+	env, err := rb.backendEnv(cfg)
+	if err != nil {
+		t.Fatalf("backendEnv: %v", err)
+	}
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "DB_URL=") {
+			t.Fatalf("expected DB_URL to stay out of the backend env, got %q", kv)
+		}
+	}
+}
+
+// TestValidateRejectsDuplicateEnvKeys verifies a repeated env key fails config load instead of silently winning.
+func TestValidateRejectsDuplicateEnvKeys(t *testing.T) {
+	rb := &ReverseBin{Envs: []string{"FOO=bar", "OTHER=x", "FOO=baz"}}
+	err := rb.Validate()
+	if err == nil || !strings.Contains(err.Error(), "env FOO is set more than once") {
+		t.Fatalf("expected duplicate FOO error, got %v", err)
+	}
+}
+
+// TestBackendEnvPrecedence verifies env overrides env_file, which overrides pass_env.
+func TestBackendEnvPrecedence(t *testing.T) {
+	t.Setenv("RB_PRECEDENCE_A", "parent")
+	t.Setenv("RB_PRECEDENCE_B", "parent")
+	dir := t.TempDir()
+	envFile := "# app settings\nRB_PRECEDENCE_B=\"file\"\nRB_PRECEDENCE_C=file\n"
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(envFile), 0o600); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	rb := &ReverseBin{
+		PassEnvs: []string{"RB_PRECEDENCE_A", "RB_PRECEDENCE_B"},
+		EnvFiles: []string{".env"},
+	}
+	env, err := rb.backendEnv(resolvedConfig{WorkingDirectory: dir, Envs: []string{"RB_PRECEDENCE_C=env"}})
+	if err != nil {
+		t.Fatalf("backendEnv: %v", err)
+	}
+
+	cmd := exec.Command("true")
+	cmd.Env = env
+	got := map[string]string{}
+	for _, kv := range cmd.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		got[k] = v
+	}
+	want := map[string]string{"RB_PRECEDENCE_A": "parent", "RB_PRECEDENCE_B": "file", "RB_PRECEDENCE_C": "env"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("%s = %q, want %q (env=%q)", k, got[k], v, env)
+		}
+	}
+}
+
 // TestWaitHealthyStopsOnContextCancel verifies health polling exits when start context ends.
 func TestWaitHealthyStopsOnContextCancel(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -290,6 +740,23 @@ func TestWaitHealthyStopsOnContextCancel(t *testing.T) {
 	}
 }
 
+// TestWaitHealthyStopsAtSocketWaitTimeout verifies socket_wait_timeout_ms fails a backend that never binds its socket before the health timeout.
+func TestWaitHealthyStopsAtSocketWaitTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rb := &ReverseBin{SocketWaitTimeoutMS: 100, SocketPollIntervalMS: 10, logger: zaptest.NewLogger(t)}
+	err := rb.waitHealthy(ctx, nil, resolvedConfig{
+		ReverseProxyTo: "unix/" + filepath.Join(t.TempDir(), "never-bound.sock"),
+		HealthMethod:   "GET",
+		HealthPath:     "/health",
+	}, nil)
+
+	if err == nil || !strings.Contains(err.Error(), "socket_wait_timeout_ms") || ctx.Err() != nil {
+		t.Fatalf("expected a socket_wait_timeout_ms error before the health timeout, got %v", err)
+	}
+}
+
 // TestGetOrCreateProcessStateReusesSupervisor verifies one lifecycle owner per process key.
 func TestGetOrCreateProcessStateReusesSupervisor(t *testing.T) {
 	rb := &ReverseBin{processes: map[string]*processState{}, logger: zaptest.NewLogger(t), ctx: caddy.Context{Context: context.Background()}}
@@ -313,208 +780,1388 @@ func TestReverseBin_UnmarshalCaddyfile(t *testing.T) {
 		wantErr  bool
 	}{
 		{
-			name: "basic executable with args",
+			name: "basic executable with args",
+			input: `reverse-bin {
+  exec /some/file a b c d 1
+  dir /somewhere
+  env foo=bar what=ever
+  pass_env some_env other_env
+  pass_all_env
+}`,
+			expected: reverseBinConfig{
+				Executable:       []string{"/some/file", "a", "b", "c", "d", "1"},
+				WorkingDirectory: "/somewhere",
+				Envs:             []string{"foo=bar", "what=ever"},
+				PassEnvs:         []string{"some_env", "other_env"},
+				PassAll:          true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "repeated env and pass_env accumulate",
+			input: `reverse-bin {
+  exec ./main.py
+  env A=1
+  env B=2 C=3
+  env_file .env
+  env_file secrets.env
+  pass_env HOME
+  pass_env PATH
+}`,
+			expected: reverseBinConfig{
+				Executable: []string{"./main.py"},
+				Envs:       []string{"A=1", "B=2", "C=3"},
+				EnvFiles:   []string{".env", "secrets.env"},
+				PassEnvs:   []string{"HOME", "PATH"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "with reverse_proxy_to",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to 127.0.0.1:8080
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "127.0.0.1:8080",
+			},
+			wantErr: false,
+		},
+		{
+			name: "with reverse_proxy_to port only",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to :8080
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: ":8080",
+			},
+			wantErr: false,
+		},
+		{
+			name: "with reverse_proxy_to unix socket",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix//tmp/app.sock
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix//tmp/app.sock",
+			},
+			wantErr: false,
+		},
+		{
+			name: "with health_check",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to 127.0.0.1:8080
+  health_check GET /health
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "127.0.0.1:8080",
+				HealthMethod:   "GET",
+				HealthPath:     "/health",
+			},
+			wantErr: false,
+		},
+		{
+			name: "with health_check HEAD",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to 127.0.0.1:8080
+  health_check head /healthy
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "127.0.0.1:8080",
+				HealthMethod:   "HEAD",
+				HealthPath:     "/healthy",
+			},
+			wantErr: false,
+		},
+		{
+			name: "health_check rejects null",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix//tmp/app.sock
+  health_check null
+}`,
+			expected: reverseBinConfig{},
+			wantErr:  true,
+		},
+		{
+			name: "with health_check and explicit status",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to 127.0.0.1:8080
+  health_check GET /v2/ 401
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "127.0.0.1:8080",
+				HealthMethod:   "GET",
+				HealthPath:     "/v2/",
+				HealthStatus:   401,
+			},
+			wantErr: false,
+		},
+		{
+			name: "with health_check default status range",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to 127.0.0.1:8080
+  health_check GET /health
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "127.0.0.1:8080",
+				HealthMethod:   "GET",
+				HealthPath:     "/health",
+				HealthStatus:   0,
+			},
+			wantErr: false,
+		},
+		{
+			name: "with health_timeout_ms",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to 127.0.0.1:8080
+  health_check GET /health
+  health_timeout_ms 15000
+}`,
+			expected: reverseBinConfig{
+				Executable:      []string{"./main.py"},
+				ReverseProxyTo:  "127.0.0.1:8080",
+				HealthMethod:    "GET",
+				HealthPath:      "/health",
+				HealthTimeoutMS: 15000,
+			},
+			wantErr: false,
+		},
+		{
+			name: "health_check rejects low explicit status",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to 127.0.0.1:8080
+  health_check GET /v2/ 99
+}`,
+			expected: reverseBinConfig{},
+			wantErr:  true,
+		},
+		{
+			name: "health_check rejects high explicit status",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to 127.0.0.1:8080
+  health_check GET /v2/ 600
+}`,
+			expected: reverseBinConfig{},
+			wantErr:  true,
+		},
+		{
+			name: "with dynamic_proxy_detector",
+			input: `reverse-bin {
+  dynamic_proxy_detector ./discover.py {path}
+}`,
+			expected: reverseBinConfig{
+				DynamicProxyDetector: []string{"./discover.py", "{path}"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "with detector_mode stream",
+			input: `reverse-bin {
+  dynamic_proxy_detector ./discover.py
+  detector_mode stream
+}`,
+			expected: reverseBinConfig{
+				DynamicProxyDetector: []string{"./discover.py"},
+				DetectorMode:         "stream",
+			},
+			wantErr: false,
+		},
+		{
+			name: "with detector_env",
+			input: `reverse-bin {
+  dynamic_proxy_detector ./discover.py
+  detector_env DB_URL=postgres://config
+  detector_env REGION=eu
+}`,
+			expected: reverseBinConfig{
+				DynamicProxyDetector: []string{"./discover.py"},
+				DetectorEnvs:         []string{"DB_URL=postgres://config", "REGION=eu"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "with inspect",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix//tmp/app.sock
+  inspect on
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix//tmp/app.sock",
+				Inspect:        true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "with decompress_response",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix//tmp/app.sock
+  decompress_response on
+}`,
+			expected: reverseBinConfig{
+				Executable:         []string{"./main.py"},
+				ReverseProxyTo:     "unix//tmp/app.sock",
+				DecompressResponse: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "with route",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix//tmp/app.sock
+  route /api/* exec ./api-server --socket {socket}
+  route /static/* exec ./file-server --socket {socket}
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix//tmp/app.sock",
+				Routes: []PathRoute{
+					{Path: "/api/*", Executable: []string{"./api-server", "--socket", "{socket}"}},
+					{Path: "/static/*", Executable: []string{"./file-server", "--socket", "{socket}"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "with content_type_route",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix//tmp/app.sock
+  content_type_route application/json exec ./json-api --socket {socket}
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix//tmp/app.sock",
+				Routes: []PathRoute{
+					{ContentType: "application/json", Executable: []string{"./json-api", "--socket", "{socket}"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "content_type_route requires exec keyword",
+			input: `reverse-bin {
+  content_type_route application/json ./json-api
+}`,
+			expected: reverseBinConfig{},
+			wantErr:  true,
+		},
+		{
+			name: "route requires exec keyword",
+			input: `reverse-bin {
+  route /api/* ./api-server
+}`,
+			expected: reverseBinConfig{},
+			wantErr:  true,
+		},
+		{
+			name: "with startup_command",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix//tmp/app.sock
+  startup_command ./migrate.sh --yes
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix//tmp/app.sock",
+				StartupCommand: []string{"./migrate.sh", "--yes"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "with shutdown_command",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix//tmp/app.sock
+  shutdown_command ./cleanup.sh
+  shutdown_command_timeout_ms 10000
+}`,
+			expected: reverseBinConfig{
+				Executable:               []string{"./main.py"},
+				ReverseProxyTo:           "unix//tmp/app.sock",
+				ShutdownCommand:          []string{"./cleanup.sh"},
+				ShutdownCommandTimeoutMS: 10000,
+			},
+			wantErr: false,
+		},
+		{
+			name: "with cgroup_path",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix//tmp/app.sock
+  cgroup_path /sys/fs/cgroup/reversebin/app
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix//tmp/app.sock",
+				CgroupPath:     "/sys/fs/cgroup/reversebin/app",
+			},
+			wantErr: false,
+		},
+		{
+			name: "with socket_type abstract",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app
+  socket_type abstract
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix/app",
+				SocketType:     "abstract",
+			},
+			wantErr: false,
+		},
+		{
+			name: "with reverse_proxy_to_secondary",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/primary.sock
+  reverse_proxy_to_secondary unix/backup.sock
+}`,
+			expected: reverseBinConfig{
+				Executable:              []string{"./main.py"},
+				ReverseProxyTo:          "unix/primary.sock",
+				ReverseProxyToSecondary: "unix/backup.sock",
+			},
+			wantErr: false,
+		},
+		{
+			name: "with retry_on_failure",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  retry_on_failure 2
+  retry_unsafe_methods
+}`,
+			expected: reverseBinConfig{
+				Executable:         []string{"./main.py"},
+				ReverseProxyTo:     "unix/app.sock",
+				RetryOnFailure:     2,
+				RetryUnsafeMethods: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "retry_on_failure rejects zero",
+			input: `reverse-bin {
+  retry_on_failure 0
+}`,
+			expected: reverseBinConfig{},
+			wantErr:  true,
+		},
+		{
+			name: "with request_log",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  request_log /var/log/reversebin/{http.request.host}.log
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix/app.sock",
+				RequestLog:     "/var/log/reversebin/{http.request.host}.log",
+			},
+			wantErr: false,
+		},
+		{
+			name: "with socket_cleanup_on_start off",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  socket_cleanup_on_start off
+}`,
+			expected: reverseBinConfig{
+				Executable:           []string{"./main.py"},
+				ReverseProxyTo:       "unix/app.sock",
+				SocketCleanupOnStart: testBoolPtr(false),
+			},
+			wantErr: false,
+		},
+		{
+			name: "with response cache",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  cache_ttl_ms 30000
+  cache_max_size 64MB
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix/app.sock",
+				CacheTTLMS:     30000,
+				CacheMaxSize:   64000000,
+			},
+			wantErr: false,
+		},
+		{
+			name: "with rate_limit",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  rate_limit 100/s
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix/app.sock",
+				RateLimit:      "100/s",
+			},
+			wantErr: false,
+		},
+		{
+			name: "rate_limit rejects unknown units",
+			input: `reverse-bin {
+  rate_limit 100/d
+}`,
+			expected: reverseBinConfig{},
+			wantErr:  true,
+		},
+		{
+			name: "with circuit breaker",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  circuit_breaker_threshold 5
+  circuit_breaker_open_duration_ms 30000
+}`,
+			expected: reverseBinConfig{
+				Executable:              []string{"./main.py"},
+				ReverseProxyTo:          "unix/app.sock",
+				CircuitBreakerThreshold: 5,
+				CircuitBreakerOpenMS:    30000,
+			},
+			wantErr: false,
+		},
+		{
+			name: "with backend_tls block",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  backend_tls {
+    ca /etc/app/ca.pem
+    cert /etc/app/client.pem
+    key /etc/app/client.key
+    server_name app.internal
+  }
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix/app.sock",
+				BackendTLS: &BackendTLS{
+					CA:         "/etc/app/ca.pem",
+					Cert:       "/etc/app/client.pem",
+					Key:        "/etc/app/client.key",
+					ServerName: "app.internal",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "backend_tls rejects unknown subdirectives",
+			input: `reverse-bin {
+  backend_tls {
+    insecure
+  }
+}`,
+			expected: reverseBinConfig{},
+			wantErr:  true,
+		},
+		{
+			name: "with status page",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  status_page_path /__reversebin_status
+  status_page_secret s3cret
+}`,
+			expected: reverseBinConfig{
+				Executable:       []string{"./main.py"},
+				ReverseProxyTo:   "unix/app.sock",
+				StatusPagePath:   "/__reversebin_status",
+				StatusPageSecret: "s3cret",
+			},
+			wantErr: false,
+		},
+		{
+			name: "with hot_config_reload",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  hot_config_reload on
+}`,
+			expected: reverseBinConfig{
+				Executable:      []string{"./main.py"},
+				ReverseProxyTo:  "unix/app.sock",
+				HotConfigReload: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "with socket_activation index",
+			input: `reverse-bin {
+  exec ./main.py
+  socket_activation 1
+}`,
+			expected: reverseBinConfig{
+				Executable:       []string{"./main.py"},
+				SocketActivation: testIntPtr(1),
+			},
+			wantErr: false,
+		},
+		{
+			name: "socket_activation rejects negative index",
+			input: `reverse-bin {
+  exec ./main.py
+  socket_activation -1
+}`,
+			wantErr: true,
+		},
+		{
+			name: "with port_discovery_pattern and byte limit",
+			input: `reverse-bin {
+  exec ./server
+  port_discovery_pattern "Listening on :(\d+)" 1KB
+}`,
+			expected: reverseBinConfig{
+				Executable:            []string{"./server"},
+				PortDiscoveryPattern:  `Listening on :(\d+)`,
+				PortDiscoveryMaxBytes: 1000,
+			},
+			wantErr: false,
+		},
+		{
+			name: "with multiple watch_file",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  watch_file ./config.json
+  watch_file ./model.bin
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix/app.sock",
+				WatchFiles:     []string{"./config.json", "./model.bin"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "with restart_schedule",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  restart_schedule "0 3 * * *"
+}`,
+			expected: reverseBinConfig{
+				Executable:      []string{"./main.py"},
+				ReverseProxyTo:  "unix/app.sock",
+				RestartSchedule: "0 3 * * *",
+			},
+			wantErr: false,
+		},
+		{
+			name: "restart_schedule rejects invalid cron expressions",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  restart_schedule "0 25 * * *"
+}`,
+			wantErr: true,
+		},
+		{
+			name: "with restart_drain_timeout_ms",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  watch_file config.json
+  restart_drain_timeout_ms 5000
+}`,
+			expected: reverseBinConfig{
+				Executable:            []string{"./main.py"},
+				ReverseProxyTo:        "unix/app.sock",
+				WatchFiles:            []string{"config.json"},
+				RestartDrainTimeoutMS: 5000,
+			},
+			wantErr: false,
+		},
+		{
+			name: "with tracing_headers off",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  tracing_headers off
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix/app.sock",
+				TracingHeaders: testBoolPtr(false),
+			},
+			wantErr: false,
+		},
+		{
+			name: "with request_id_header",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  request_id_header X-Request-Id
+}`,
+			expected: reverseBinConfig{
+				Executable:      []string{"./main.py"},
+				ReverseProxyTo:  "unix/app.sock",
+				RequestIDHeader: "X-Request-Id",
+			},
+			wantErr: false,
+		},
+		{
+			name: "with response timeouts",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  response_header_timeout_ms 5000
+  response_idle_timeout_ms 10000
+}`,
+			expected: reverseBinConfig{
+				Executable:              []string{"./main.py"},
+				ReverseProxyTo:          "unix/app.sock",
+				ResponseHeaderTimeoutMS: 5000,
+				ResponseIdleTimeoutMS:   10000,
+			},
+			wantErr: false,
+		},
+		{
+			name: "with max_request_body_size",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  max_request_body_size 10MB
+}`,
+			expected: reverseBinConfig{
+				Executable:         []string{"./main.py"},
+				ReverseProxyTo:     "unix/app.sock",
+				MaxRequestBodySize: 10000000,
+			},
+			wantErr: false,
+		},
+		{
+			name: "max_request_body_size rejects zero",
+			input: `reverse-bin {
+  exec ./main.py
+  max_request_body_size 0
+}`,
+			wantErr: true,
+		},
+		{
+			name: "with liveness_check",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  liveness_check get /healthz 204
+  liveness_interval_ms 10000
+  liveness_failure_threshold 5
+}`,
+			expected: reverseBinConfig{
+				Executable:               []string{"./main.py"},
+				ReverseProxyTo:           "unix/app.sock",
+				LivenessMethod:           "GET",
+				LivenessPath:             "/healthz",
+				LivenessStatus:           204,
+				LivenessIntervalMS:       10000,
+				LivenessFailureThreshold: 5,
+			},
+			wantErr: false,
+		},
+		{
+			name: "liveness_failure_threshold rejects zero",
+			input: `reverse-bin {
+  exec ./main.py
+  liveness_failure_threshold 0
+}`,
+			wantErr: true,
+		},
+		{
+			name: "with socket_wait_timeout_ms and socket_poll_interval_ms",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  socket_wait_timeout_ms 10000
+  socket_poll_interval_ms 100
+}`,
+			expected: reverseBinConfig{
+				Executable:           []string{"./main.py"},
+				ReverseProxyTo:       "unix/app.sock",
+				SocketWaitTimeoutMS:  10000,
+				SocketPollIntervalMS: 100,
+			},
+			wantErr: false,
+		},
+		{
+			name: "with proxy_protocol",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  proxy_protocol v2
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix/app.sock",
+				ProxyProtocol:  "v2",
+			},
+			wantErr: false,
+		},
+		{
+			name: "proxy_protocol rejects unknown versions",
+			input: `reverse-bin {
+  exec ./main.py
+  proxy_protocol v3
+}`,
+			wantErr: true,
+		},
+		{
+			name: "with keepalive settings",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  keepalive on
+  keepalive_idle_ms 90000
+  keepalive_interval_ms 15000
+  keepalive_pool_size 10
+}`,
+			expected: reverseBinConfig{
+				Executable:          []string{"./main.py"},
+				ReverseProxyTo:      "unix/app.sock",
+				Keepalive:           testBoolPtr(true),
+				KeepaliveIdleMS:     90000,
+				KeepaliveIntervalMS: 15000,
+				KeepalivePoolSize:   10,
+			},
+			wantErr: false,
+		},
+		{
+			name: "keepalive_pool_size rejects zero",
+			input: `reverse-bin {
+  exec ./main.py
+  keepalive_pool_size 0
+}`,
+			wantErr: true,
+		},
+		{
+			name: "with backend_connect_timeout_ms",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  backend_connect_timeout_ms 2000
+}`,
+			expected: reverseBinConfig{
+				Executable:              []string{"./main.py"},
+				ReverseProxyTo:          "unix/app.sock",
+				BackendConnectTimeoutMS: 2000,
+			},
+			wantErr: false,
+		},
+		{
+			name: "with redact_env_pattern",
+			input: `reverse-bin {
+  exec ./main.py
+  pass_all_env
+  redact_env_pattern "(?i)(secret|password|key|token|database_url)"
+}`,
+			expected: reverseBinConfig{
+				Executable:       []string{"./main.py"},
+				PassAll:          true,
+				RedactEnvPattern: "(?i)(secret|password|key|token|database_url)",
+			},
+			wantErr: false,
+		},
+		{
+			name: "redact_env_pattern rejects invalid regexps",
+			input: `reverse-bin {
+  exec ./main.py
+  redact_env_pattern "(secret"
+}`,
+			wantErr: true,
+		},
+		{
+			name: "with stdio_mode",
+			input: `reverse-bin {
+  exec ./render.sh {path}
+  stdio_mode on
+  stdio_content_type text/html
+}`,
+			expected: reverseBinConfig{
+				Executable:       []string{"./render.sh", "{path}"},
+				StdioMode:        true,
+				StdioContentType: "text/html",
+			},
+			wantErr: false,
+		},
+		{
+			name: "stdio_mode rejects invalid values",
+			input: `reverse-bin {
+  exec ./render.sh
+  stdio_mode maybe
+}`,
+			wantErr: true,
+		},
+		{
+			name: "with response_rewrite",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  response_rewrite "http://internal:8080/" "/"
+  response_rewrite "</body>" "<img src=/px></body>"
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix/app.sock",
+				ResponseRewrites: []ResponseRewrite{
+					{Find: "http://internal:8080/", Replace: "/"},
+					{Find: "</body>", Replace: "<img src=/px></body>"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "response_rewrite rejects invalid regexps",
+			input: `reverse-bin {
+  exec ./main.py
+  response_rewrite "(unclosed" "x"
+}`,
+			wantErr: true,
+		},
+		{
+			name: "with health_addr",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  health_addr :9099
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix/app.sock",
+				HealthAddr:     ":9099",
+			},
+			wantErr: false,
+		},
+		{
+			name: "with docker_image",
+			input: `reverse-bin {
+  docker_image my-app:latest
+  reverse_proxy_to unix//run/apps/app.sock
+}`,
+			expected: reverseBinConfig{
+				ReverseProxyTo: "unix//run/apps/app.sock",
+				DockerImage:    "my-app:latest",
+			},
+			wantErr: false,
+		},
+		{
+			name: "with allowed_ips",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  allowed_ips 10.0.0.0/8 192.168.1.0/24
+  allowed_ips 2001:db8::/32
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix/app.sock",
+				AllowedIPs:     []string{"10.0.0.0/8", "192.168.1.0/24", "2001:db8::/32"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "allowed_ips rejects invalid ranges",
+			input: `reverse-bin {
+  exec ./main.py
+  allowed_ips 10.0.0.0/33
+}`,
+			wantErr: true,
+		},
+		{
+			name: "with env_from_header",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  env_from_header X-Tenant-Id TENANT_ID
+  env_from_header X-Region REGION
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix/app.sock",
+				EnvFromHeaders: []EnvFromHeader{
+					{Header: "X-Tenant-Id", Env: "TENANT_ID"},
+					{Header: "X-Region", Env: "REGION"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "env_from_header requires header and variable",
+			input: `reverse-bin {
+  exec ./main.py
+  env_from_header X-Tenant-Id
+}`,
+			wantErr: true,
+		},
+		{
+			name: "with proxy_headers_from_request",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix/app.sock
+  proxy_headers_from_request X-Real-Ip X-Forwarded-For
+}`,
+			expected: reverseBinConfig{
+				Executable:              []string{"./main.py"},
+				ReverseProxyTo:          "unix/app.sock",
+				ProxyHeadersFromRequest: []string{"X-Real-Ip", "X-Forwarded-For"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "with per_request_process",
+			input: `reverse-bin {
+  exec ./sandbox --socket /run/sandbox/{http.request.uuid}.sock
+  socket_template unix//run/sandbox/{http.request.uuid}.sock
+  per_request_process on
+}`,
+			expected: reverseBinConfig{
+				Executable:        []string{"./sandbox", "--socket", "/run/sandbox/{http.request.uuid}.sock"},
+				SocketTemplate:    "unix//run/sandbox/{http.request.uuid}.sock",
+				PerRequestProcess: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "with socket_path_rotate",
+			input: `reverse-bin {
+  exec ./app
+  reverse_proxy_to unix//run/app.sock
+  socket_path_rotate on
+}`,
+			expected: reverseBinConfig{
+				Executable:       []string{"./app"},
+				ReverseProxyTo:   "unix//run/app.sock",
+				SocketPathRotate: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "with startup_timeout_action retry",
+			input: `reverse-bin {
+  exec ./app
+  reverse_proxy_to unix//run/app.sock
+  startup_timeout_action retry
+  startup_retry_delay_ms 500
+}`,
+			expected: reverseBinConfig{
+				Executable:           []string{"./app"},
+				ReverseProxyTo:       "unix//run/app.sock",
+				StartupTimeoutAction: "retry",
+				StartupRetryDelayMS:  500,
+			},
+			wantErr: false,
+		},
+		{
+			name: "startup_timeout_action rejects unknown actions",
+			input: `reverse-bin {
+  exec ./app
+  startup_timeout_action wait
+}`,
+			wantErr: true,
+		},
+		{
+			name: "with startup_address_pattern",
+			input: `reverse-bin {
+  exec ./app
+  startup_address_pattern "Listening on unix:(.+)" 1KB
+}`,
+			expected: reverseBinConfig{
+				Executable:             []string{"./app"},
+				StartupAddressPattern:  "Listening on unix:(.+)",
+				StartupAddressMaxBytes: 1000,
+			},
+			wantErr: false,
+		},
+		{
+			name: "with error_body_format",
+			input: `reverse-bin {
+  exec ./app
+  reverse_proxy_to unix//run/app.sock
+  error_body_format json
+}`,
+			expected: reverseBinConfig{
+				Executable:      []string{"./app"},
+				ReverseProxyTo:  "unix//run/app.sock",
+				ErrorBodyFormat: "json",
+			},
+			wantErr: false,
+		},
+		{
+			name: "error_body_format rejects unknown formats",
+			input: `reverse-bin {
+  exec ./app
+  error_body_format xml
+}`,
+			wantErr: true,
+		},
+		{
+			name: "with backend_max_conns",
+			input: `reverse-bin {
+  exec ./app
+  reverse_proxy_to unix//run/app.sock
+  keepalive_pool_size 4
+  backend_max_conns 8
+}`,
+			expected: reverseBinConfig{
+				Executable:        []string{"./app"},
+				ReverseProxyTo:    "unix//run/app.sock",
+				KeepalivePoolSize: 4,
+				BackendMaxConns:   8,
+			},
+			wantErr: false,
+		},
+		{
+			name: "with lifecycle_hook",
+			input: `reverse-bin {
+  exec ./app
+  reverse_proxy_to unix//run/app.sock
+  lifecycle_hook ./notify.sh --channel ops
+}`,
+			expected: reverseBinConfig{
+				Executable:     []string{"./app"},
+				ReverseProxyTo: "unix//run/app.sock",
+				LifecycleHook:  []string{"./notify.sh", "--channel", "ops"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "with response_buffer_overflow stream",
+			input: `reverse-bin {
+  exec ./app
+  reverse_proxy_to unix//run/app.sock
+  response_buffer_size 64KB
+  response_buffer_overflow stream
+}`,
+			expected: reverseBinConfig{
+				Executable:             []string{"./app"},
+				ReverseProxyTo:         "unix//run/app.sock",
+				ResponseBufferSize:     64000,
+				ResponseBufferOverflow: "stream",
+			},
+			wantErr: false,
+		},
+		{
+			name: "detector_mode rejects unknown modes",
+			input: `reverse-bin {
+  detector_mode poll
+}`,
+			expected: reverseBinConfig{},
+			wantErr:  true,
+		},
+		{
+			name: "with dynamic_proxy_detector_http",
+			input: `reverse-bin {
+  dynamic_proxy_detector_http get http://config-server/detect?path={path}
+  detector_http_timeout_ms 2000
+  detector_http_basic_auth reverse-bin s3cret
+}`,
+			expected: reverseBinConfig{
+				DynamicProxyDetectorHTTP: []string{"GET", "http://config-server/detect?path={path}"},
+				DetectorHTTPTimeoutMS:    2000,
+				DetectorHTTPUser:         "reverse-bin",
+				DetectorHTTPPassword:     "s3cret",
+			},
+			wantErr: false,
+		},
+		{
+			name: "dynamic_proxy_detector_http requires method and URL",
+			input: `reverse-bin {
+  dynamic_proxy_detector_http http://config-server/detect
+}`,
+			expected: reverseBinConfig{},
+			wantErr:  true,
+		},
+		{
+			name: "full configuration",
+			input: `reverse-bin {
+  exec ./main.py arg1 arg2
+  dir /app
+  env FOO=bar BAZ=qux
+  pass_env HOME PATH
+  pass_all_env
+  reverse_proxy_to 127.0.0.1:3000
+  health_check GET /healthz
+  dynamic_proxy_detector /bin/detect {host} {path}
+  idle_timeout_ms 100
+  health_timeout_ms 15000
+  termination_grace_ms 5000
+  termination_kill_wait_ms 1000
+}`,
+			expected: reverseBinConfig{
+				Executable:            []string{"./main.py", "arg1", "arg2"},
+				WorkingDirectory:      "/app",
+				Envs:                  []string{"FOO=bar", "BAZ=qux"},
+				PassEnvs:              []string{"HOME", "PATH"},
+				PassAll:               true,
+				ReverseProxyTo:        "127.0.0.1:3000",
+				HealthMethod:          "GET",
+				HealthPath:            "/healthz",
+				DynamicProxyDetector:  []string{"/bin/detect", "{host}", "{path}"},
+				IdleTimeoutMS:         100,
+				HealthTimeoutMS:       15000,
+				TerminationGraceMS:    5000,
+				TerminationKillWaitMS: 1000,
+			},
+			wantErr: false,
+		},
+		{
+			name: "with relay_expect_continue",
 			input: `reverse-bin {
-  exec /some/file a b c d 1
-  dir /somewhere
-  env foo=bar what=ever
-  pass_env some_env other_env
-  pass_all_env
+  exec ./main.py
+  reverse_proxy_to unix//tmp/app.sock
+  relay_expect_continue on
 }`,
 			expected: reverseBinConfig{
-				Executable:       []string{"/some/file", "a", "b", "c", "d", "1"},
-				WorkingDirectory: "/somewhere",
-				Envs:             []string{"foo=bar", "what=ever"},
-				PassEnvs:         []string{"some_env", "other_env"},
-				PassAll:          true,
+				Executable:          []string{"./main.py"},
+				ReverseProxyTo:      "unix//tmp/app.sock",
+				RelayExpectContinue: true,
 			},
 			wantErr: false,
 		},
 		{
-			name: "with reverse_proxy_to",
+			name: "with access_log_backend_latency",
 			input: `reverse-bin {
   exec ./main.py
-  reverse_proxy_to 127.0.0.1:8080
+  reverse_proxy_to unix//tmp/app.sock
+  access_log_backend_latency on
 }`,
 			expected: reverseBinConfig{
-				Executable:     []string{"./main.py"},
-				ReverseProxyTo: "127.0.0.1:8080",
+				Executable:              []string{"./main.py"},
+				ReverseProxyTo:          "unix//tmp/app.sock",
+				AccessLogBackendLatency: true,
 			},
 			wantErr: false,
 		},
 		{
-			name: "with reverse_proxy_to port only",
+			name: "with process_namespace",
 			input: `reverse-bin {
   exec ./main.py
-  reverse_proxy_to :8080
+  reverse_proxy_to unix//tmp/app.sock
+  process_namespace pid,net,mnt
 }`,
 			expected: reverseBinConfig{
-				Executable:     []string{"./main.py"},
-				ReverseProxyTo: ":8080",
+				Executable:        []string{"./main.py"},
+				ReverseProxyTo:    "unix//tmp/app.sock",
+				ProcessNamespaces: []string{"pid", "net", "mnt"},
 			},
 			wantErr: false,
 		},
 		{
-			name: "with reverse_proxy_to unix socket",
+			name: "backend_status_override accumulates mappings",
 			input: `reverse-bin {
   exec ./main.py
   reverse_proxy_to unix//tmp/app.sock
+  backend_status_override 404=403 503=529
+  backend_status_override 500=502
 }`,
 			expected: reverseBinConfig{
-				Executable:     []string{"./main.py"},
-				ReverseProxyTo: "unix//tmp/app.sock",
+				Executable:      []string{"./main.py"},
+				ReverseProxyTo:  "unix//tmp/app.sock",
+				StatusOverrides: map[int]int{404: 403, 503: 529, 500: 502},
 			},
 			wantErr: false,
 		},
 		{
-			name: "with health_check",
+			name: "with socket_template",
 			input: `reverse-bin {
   exec ./main.py
-  reverse_proxy_to 127.0.0.1:8080
-  health_check GET /health
+  socket_template unix//run/apps/{http.request.host}.sock
 }`,
 			expected: reverseBinConfig{
 				Executable:     []string{"./main.py"},
-				ReverseProxyTo: "127.0.0.1:8080",
-				HealthMethod:   "GET",
-				HealthPath:     "/health",
+				SocketTemplate: "unix//run/apps/{http.request.host}.sock",
 			},
 			wantErr: false,
 		},
 		{
-			name: "with health_check HEAD",
+			name: "with socket_mode and socket_group",
 			input: `reverse-bin {
   exec ./main.py
-  reverse_proxy_to 127.0.0.1:8080
-  health_check head /healthy
+  reverse_proxy_to unix//tmp/app.sock
+  socket_mode 0660
+  socket_group www-data
 }`,
 			expected: reverseBinConfig{
 				Executable:     []string{"./main.py"},
-				ReverseProxyTo: "127.0.0.1:8080",
-				HealthMethod:   "HEAD",
-				HealthPath:     "/healthy",
+				ReverseProxyTo: "unix//tmp/app.sock",
+				SocketMode:     "0660",
+				SocketGroup:    "www-data",
 			},
 			wantErr: false,
 		},
 		{
-			name: "health_check rejects null",
+			name: "with response header operations",
 			input: `reverse-bin {
   exec ./main.py
   reverse_proxy_to unix//tmp/app.sock
-  health_check null
+  response_header_delete X-Powered-By
+  response_header_set Cache-Control public
+  response_header_add Vary Origin
+  response_header_add Vary Accept
 }`,
-			expected: reverseBinConfig{},
-			wantErr:  true,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix//tmp/app.sock",
+				ResponseHeaders: &headers.HeaderOps{
+					Add:    http.Header{"Vary": []string{"Origin", "Accept"}},
+					Set:    http.Header{"Cache-Control": []string{"public"}},
+					Delete: []string{"X-Powered-By"},
+				},
+			},
+			wantErr: false,
 		},
 		{
-			name: "with health_check and explicit status",
+			name: "with upstream_header_add",
 			input: `reverse-bin {
   exec ./main.py
-  reverse_proxy_to 127.0.0.1:8080
-  health_check GET /v2/ 401
+  reverse_proxy_to unix//tmp/app.sock
+  upstream_header_add X-Backend-Source reverse-bin
+  upstream_header_add X-Request-Host {http.request.host}
 }`,
 			expected: reverseBinConfig{
 				Executable:     []string{"./main.py"},
-				ReverseProxyTo: "127.0.0.1:8080",
-				HealthMethod:   "GET",
-				HealthPath:     "/v2/",
-				HealthStatus:   401,
+				ReverseProxyTo: "unix//tmp/app.sock",
+				UpstreamHeaders: &headers.HeaderOps{Add: http.Header{
+					"X-Backend-Source": []string{"reverse-bin"},
+					"X-Request-Host":   []string{"{http.request.host}"},
+				}},
 			},
 			wantErr: false,
 		},
 		{
-			name: "with health_check default status range",
+			name: "with response_buffer_size",
 			input: `reverse-bin {
   exec ./main.py
-  reverse_proxy_to 127.0.0.1:8080
-  health_check GET /health
+  reverse_proxy_to unix//tmp/app.sock
+  response_buffer_size 64KB
 }`,
 			expected: reverseBinConfig{
-				Executable:     []string{"./main.py"},
-				ReverseProxyTo: "127.0.0.1:8080",
-				HealthMethod:   "GET",
-				HealthPath:     "/health",
-				HealthStatus:   0,
+				Executable:         []string{"./main.py"},
+				ReverseProxyTo:     "unix//tmp/app.sock",
+				ResponseBufferSize: 64000,
 			},
 			wantErr: false,
 		},
 		{
-			name: "with health_timeout_ms",
+			name: "with run_as",
 			input: `reverse-bin {
   exec ./main.py
-  reverse_proxy_to 127.0.0.1:8080
-  health_check GET /health
-  health_timeout_ms 15000
+  reverse_proxy_to unix//tmp/app.sock
+  run_as www-data
 }`,
 			expected: reverseBinConfig{
-				Executable:      []string{"./main.py"},
-				ReverseProxyTo:  "127.0.0.1:8080",
-				HealthMethod:    "GET",
-				HealthPath:      "/health",
-				HealthTimeoutMS: 15000,
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix//tmp/app.sock",
+				RunAs:          "www-data",
 			},
 			wantErr: false,
 		},
 		{
-			name: "health_check rejects low explicit status",
+			name: "with max_memory and cpu_shares",
 			input: `reverse-bin {
   exec ./main.py
-  reverse_proxy_to 127.0.0.1:8080
-  health_check GET /v2/ 99
+  reverse_proxy_to unix//tmp/app.sock
+  max_memory 512MB
+  cpu_shares 512
 }`,
-			expected: reverseBinConfig{},
-			wantErr:  true,
+			expected: reverseBinConfig{
+				Executable:     []string{"./main.py"},
+				ReverseProxyTo: "unix//tmp/app.sock",
+				MaxMemory:      512000000,
+				CPUShares:      512,
+			},
+			wantErr: false,
 		},
 		{
-			name: "health_check rejects high explicit status",
+			name: "relay_expect_continue rejects non on/off",
 			input: `reverse-bin {
   exec ./main.py
-  reverse_proxy_to 127.0.0.1:8080
-  health_check GET /v2/ 600
+  relay_expect_continue yes
 }`,
 			expected: reverseBinConfig{},
 			wantErr:  true,
 		},
 		{
-			name: "with dynamic_proxy_detector",
+			name: "with backend_proto scgi",
 			input: `reverse-bin {
-  dynamic_proxy_detector ./discover.py {path}
+  exec ./trac.py
+  reverse_proxy_to unix//tmp/trac.sock
+  backend_proto scgi
 }`,
 			expected: reverseBinConfig{
-				DynamicProxyDetector: []string{"./discover.py", "{path}"},
+				Executable:     []string{"./trac.py"},
+				ReverseProxyTo: "unix//tmp/trac.sock",
+				BackendProto:   "scgi",
 			},
 			wantErr: false,
 		},
 		{
-			name: "full configuration",
+			name: "with backend_proto fastcgi",
 			input: `reverse-bin {
-  exec ./main.py arg1 arg2
-  dir /app
-  env FOO=bar BAZ=qux
-  pass_env HOME PATH
-  pass_all_env
-  reverse_proxy_to 127.0.0.1:3000
-  health_check GET /healthz
-  dynamic_proxy_detector /bin/detect {host} {path}
-  idle_timeout_ms 100
-  health_timeout_ms 15000
-  termination_grace_ms 5000
-  termination_kill_wait_ms 1000
+  exec php-fpm --nodaemonize
+  dir /srv/php
+  reverse_proxy_to unix//run/php-fpm.sock
+  backend_proto fastcgi
 }`,
 			expected: reverseBinConfig{
-				Executable:            []string{"./main.py", "arg1", "arg2"},
-				WorkingDirectory:      "/app",
-				Envs:                  []string{"FOO=bar", "BAZ=qux"},
-				PassEnvs:              []string{"HOME", "PATH"},
-				PassAll:               true,
-				ReverseProxyTo:        "127.0.0.1:3000",
-				HealthMethod:          "GET",
-				HealthPath:            "/healthz",
-				DynamicProxyDetector:  []string{"/bin/detect", "{host}", "{path}"},
-				IdleTimeoutMS:         100,
-				HealthTimeoutMS:       15000,
-				TerminationGraceMS:    5000,
-				TerminationKillWaitMS: 1000,
+				Executable:       []string{"php-fpm", "--nodaemonize"},
+				WorkingDirectory: "/srv/php",
+				ReverseProxyTo:   "unix//run/php-fpm.sock",
+				BackendProto:     "fastcgi",
+			},
+			wantErr: false,
+		},
+		{
+			name: "backend_proto rejects unknown protocol",
+			input: `reverse-bin {
+  exec ./main.py
+  backend_proto gopher
+}`,
+			expected: reverseBinConfig{},
+			wantErr:  true,
+		},
+		{
+			name: "with backend_follow_redirects",
+			input: `reverse-bin {
+  exec ./main.py
+  reverse_proxy_to unix//tmp/app.sock
+  backend_follow_redirects on
+  max_redirects 3
+}`,
+			expected: reverseBinConfig{
+				Executable:             []string{"./main.py"},
+				ReverseProxyTo:         "unix//tmp/app.sock",
+				BackendFollowRedirects: true,
+				BackendMaxRedirects:    3,
 			},
 			wantErr: false,
 		},
@@ -557,6 +2204,19 @@ func TestReverseBin_UnmarshalCaddyfile(t *testing.T) {
 			if !reflect.DeepEqual(asConfig(&c), tt.expected) {
 				t.Errorf("Parsing yielded invalid result.\nGot:      %#v\nExpected: %#v", asConfig(&c), tt.expected)
 			}
+
+			// The JSON Caddy adapts the Caddyfile to must load back into the same handler.
+			raw, err := json.Marshal(&c)
+			if err != nil {
+				t.Fatalf("marshal JSON: %v", err)
+			}
+			var decoded ReverseBin
+			if err := json.Unmarshal(raw, &decoded); err != nil {
+				t.Fatalf("unmarshal JSON %s: %v", raw, err)
+			}
+			if !reflect.DeepEqual(&decoded, &c) {
+				t.Errorf("JSON round trip changed the config.\nJSON:     %s\nGot:      %#v\nExpected: %#v", raw, &decoded, &c)
+			}
 		})
 	}
 }
@@ -657,6 +2317,126 @@ func TestProbeHealthReportsUnexpectedDefaultStatus(t *testing.T) {
 	}
 }
 
+type readTrackingBody struct {
+	io.Reader
+	read bool
+}
+
+func (b *readTrackingBody) Read(p []byte) (int, error) {
+	b.read = true
+	return b.Reader.Read(p)
+}
+
+// TestNewTransportRelayExpectContinue verifies the body is withheld from a backend that rejects Expect: 100-continue only when relaying is on.
+func TestNewTransportRelayExpectContinue(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// This HTTP request tests a backend refusing the expectation without reading the body.
+		w.WriteHeader(http.StatusExpectationFailed)
+	}))
+	defer backend.Close()
+
+	for _, relay := range []bool{true, false} {
+		t.Run(fmt.Sprintf("relay=%v", relay), func(t *testing.T) {
+			ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+			defer cancel()
+			rb := &ReverseBin{RelayExpectContinue: relay}
+			transport, err := rb.newTransport(ctx)
+			if err != nil {
+				t.Fatalf("newTransport: %v", err)
+			}
+
+			body := &readTrackingBody{Reader: strings.NewReader("upload")}
+			req := httptest.NewRequest(http.MethodPost, backend.URL+"/upload", body)
+			req.RequestURI = ""
+			req.Header.Set("Expect", "100-continue")
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusExpectationFailed {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusExpectationFailed)
+			}
+			if body.read == relay {
+				t.Fatalf("body read = %v with relay_expect_continue=%v", body.read, relay)
+			}
+		})
+	}
+}
+
+// TestRelayExpectContinueSendsInterimResponseFirst verifies a client sending Expect: 100-continue through the relay gets 100 Continue before it sends the body.
+func TestRelayExpectContinueSendsInterimResponseFirst(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// This HTTP request tests the backend accepts the expectation and reads the upload.
+		_, _ = io.Copy(w, r.Body)
+	}))
+	defer backend.Close()
+
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+	rb := &ReverseBin{RelayExpectContinue: true}
+	transport, err := rb.newTransport(ctx)
+	if err != nil {
+		t.Fatalf("newTransport: %v", err)
+	}
+	// The front server passes the client's body straight to the transport,
+	// as the reverse proxy does, so 100 Continue reaches the client when
+	// the transport starts sending it.
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out := r.Clone(r.Context())
+		out.URL.Scheme, out.URL.Host, out.RequestURI = "http", strings.TrimPrefix(backend.URL, "http://"), ""
+		resp, err := transport.RoundTrip(out)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	defer front.Close()
+
+	var got100, sentBefore100 atomic.Bool
+	upload := strings.NewReader("upload")
+	req, err := http.NewRequest(http.MethodPost, front.URL+"/upload", readerFunc(func(p []byte) (int, error) {
+		if !got100.Load() {
+			sentBefore100.Store(true)
+		}
+		return upload.Read(p)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.ContentLength = int64(len("upload"))
+	req.Header.Set("Expect", "100-continue")
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		Got100Continue: func() { got100.Store(true) },
+	}))
+	client := &http.Client{Transport: &http.Transport{ExpectContinueTimeout: 5 * time.Second}}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request through the relay: %v", err)
+	}
+	echoed, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || string(echoed) != "upload" {
+		t.Fatalf("expected 200 echoing the upload, got %d %q", resp.StatusCode, echoed)
+	}
+	if !got100.Load() {
+		t.Fatal("expected the client to receive 100 Continue")
+	}
+	if sentBefore100.Load() {
+		t.Fatal("expected the body to be sent only after 100 Continue")
+	}
+}
+
+// readerFunc adapts a function to io.Reader.
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
+
 func TestResolveDialAddress(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -667,6 +2447,8 @@ func TestResolveDialAddress(t *testing.T) {
 		{name: "IP and port", reverseProxyTo: "127.0.0.1:8080", wantDial: "127.0.0.1:8080"},
 		{name: "port only", reverseProxyTo: ":8080", wantDial: "127.0.0.1:8080"},
 		{name: "with http scheme", reverseProxyTo: "http://127.0.0.1:8080", wantDial: "127.0.0.1:8080"},
+		{name: "with https scheme", reverseProxyTo: "https://10.0.0.5:8443", wantDial: "10.0.0.5:8443"},
+		{name: "URL without port", reverseProxyTo: "https://api.service.consul", wantDial: "api.service.consul:443"},
 		{name: "invalid host", reverseProxyTo: "http://", wantErr: true},
 	}
 
@@ -838,3 +2620,199 @@ type NoOpNextHandler struct{}
 func (n NoOpNextHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
+
+// TestProvisionRejectsMissingWorkingDirectory verifies a dir that is absent or not a directory fails at Provision rather than at spawn time.
+func TestProvisionRejectsMissingWorkingDirectory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	for _, dir := range []string{filepath.Join(t.TempDir(), "missing"), file} {
+		ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+		rb := &ReverseBin{Executable: []string{"./main.py"}, ReverseProxyTo: "unix//tmp/app.sock", WorkingDirectory: dir}
+		err := rb.Provision(ctx)
+		cancel()
+		if err == nil || !strings.HasPrefix(err.Error(), "dir ") && !strings.HasPrefix(err.Error(), "dir: ") {
+			t.Fatalf("Provision with dir %q: err = %v, want dir error", dir, err)
+		}
+	}
+}
+
+// TestExecPlaceholdersSelectBackendPerRequest verifies exec placeholders are expanded per request and key separate backends.
+func TestExecPlaceholdersSelectBackendPerRequest(t *testing.T) {
+	c := &ReverseBin{
+		Executable:     []string{"/srv/{http.request.host}/app", "--env={env.RB_TEST_APP_ENV}"},
+		ReverseProxyTo: "unix/" + filepath.Join(t.TempDir(), "app.sock"),
+		logger:         zaptest.NewLogger(t),
+	}
+	t.Setenv("RB_TEST_APP_ENV", "prod")
+
+	keys := map[string]bool{}
+	for _, host := range []string{"a.example", "b.example"} {
+		// This request tests expansion from the Host header of each request.
+		req := httptest.NewRequest(http.MethodGet, "http://"+host+"/", nil)
+		repl := caddyhttp.NewTestReplacer(req)
+		req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, repl))
+
+		cfg, err := c.resolveRequestConfig(req, c.getProcessKey(req))
+		if err != nil {
+			t.Fatalf("resolveRequestConfig: %v", err)
+		}
+		want := []string{"/srv/" + host + "/app", "--env=prod"}
+		if !reflect.DeepEqual(cfg.Executable, want) {
+			t.Fatalf("executable = %q, want %q", cfg.Executable, want)
+		}
+		keys[c.getProcessKey(req)] = true
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected one process key per host, got %v", keys)
+	}
+}
+
+// TestCheckSharedSocketRequiresSocketTemplate verifies per-request backends must get their own socket_template socket.
+func TestCheckSharedSocketRequiresSocketTemplate(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		rb      *ReverseBin
+		wantErr string
+	}{
+		{
+			name:    "exec placeholder with reverse_proxy_to",
+			rb:      &ReverseBin{Executable: []string{"./app", "--site={http.request.host}"}, ReverseProxyTo: "unix//run/app.sock"},
+			wantErr: "exec placeholder {http.request.host}",
+		},
+		{
+			name:    "exec placeholder missing from socket_template",
+			rb:      &ReverseBin{Executable: []string{"./app", "--site={http.request.host}"}, SocketTemplate: "unix//run/{http.request.uri.path}.sock"},
+			wantErr: "exec placeholder {http.request.host}",
+		},
+		{
+			name: "exec placeholder in socket_template",
+			rb:   &ReverseBin{Executable: []string{"./app", "--site={http.request.host}"}, SocketTemplate: "unix//run/{http.request.host}.sock"},
+		},
+		{
+			name:    "env value placeholder with reverse_proxy_to",
+			rb:      &ReverseBin{Executable: []string{"./app"}, Envs: []string{"TENANT={http.request.host}"}, ReverseProxyTo: "unix//run/app.sock"},
+			wantErr: "env placeholder {http.request.host}",
+		},
+		{
+			name: "env value placeholder in socket_template",
+			rb:   &ReverseBin{Executable: []string{"./app"}, Envs: []string{"TENANT={http.request.host}", `CONFIG={"a":1}`}, SocketTemplate: "unix//run/{http.request.host}.sock"},
+		},
+		{
+			name:    "env_from_header with reverse_proxy_to",
+			rb:      &ReverseBin{Executable: []string{"./app"}, EnvFromHeaders: []EnvFromHeader{{Header: "X-Tenant-Id", Env: "TENANT_ID"}}, ReverseProxyTo: "unix//run/app.sock"},
+			wantErr: "socket_template must contain {http.request.header.X-Tenant-Id}",
+		},
+		{
+			name: "env_from_header header in socket_template",
+			rb:   &ReverseBin{Executable: []string{"./app"}, EnvFromHeaders: []EnvFromHeader{{Header: "X-Tenant-Id", Env: "TENANT_ID"}}, SocketTemplate: "unix//run/{http.request.header.x-tenant-id}.sock"},
+		},
+		{
+			name: "env placeholder is the same for every request",
+			rb:   &ReverseBin{Executable: []string{"./app", "--env={env.APP_ENV}"}, ReverseProxyTo: "unix//run/app.sock"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.rb.checkSharedSocket()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("checkSharedSocket: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+			ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+			defer cancel()
+			if err := tc.rb.Provision(ctx); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected Provision error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestExecShellParametersAreNotPlaceholders verifies shell ${VAR} arguments reach the command intact and keep a single backend.
+func TestExecShellParametersAreNotPlaceholders(t *testing.T) {
+	c := &ReverseBin{
+		Executable:     []string{"sh", "-c", "exec app --port ${PORT} --host {http.request.host}.internal"},
+		ReverseProxyTo: "unix/" + filepath.Join(t.TempDir(), "app.sock"),
+		logger:         zaptest.NewLogger(t),
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://a.example/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, caddyhttp.NewTestReplacer(req)))
+
+	cfg, err := c.resolveRequestConfig(req, c.getProcessKey(req))
+	if err != nil {
+		t.Fatalf("resolveRequestConfig: %v", err)
+	}
+	want := []string{"sh", "-c", "exec app --port ${PORT} --host a.example.internal"}
+	if !reflect.DeepEqual(cfg.Executable, want) {
+		t.Fatalf("executable = %q, want %q", cfg.Executable, want)
+	}
+
+	c.Executable = []string{"sh", "-c", "exec app --port ${PORT}"}
+	if key := c.getProcessKey(req); key != "" {
+		t.Fatalf("expected a single backend for a shell ${PORT} argument, got process key %q", key)
+	}
+}
+
+// TestEnvPlaceholdersExpandPerRequest verifies env values expand request placeholders, keep unknown ones, and key backends per value.
+func TestEnvPlaceholdersExpandPerRequest(t *testing.T) {
+	c := &ReverseBin{
+		Executable:     []string{"./main.py"},
+		Envs:           []string{"TENANT={http.request.host}", `CONFIG={"a":1}`},
+		ReverseProxyTo: "unix/" + filepath.Join(t.TempDir(), "app.sock"),
+		logger:         zaptest.NewLogger(t),
+	}
+
+	keys := map[string]bool{}
+	for _, host := range []string{"a.example", "b.example"} {
+		// This request tests expansion from the Host header of each request.
+		req := httptest.NewRequest(http.MethodGet, "http://"+host+"/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, caddyhttp.NewTestReplacer(req)))
+
+		cfg, err := c.resolveRequestConfig(req, c.getProcessKey(req))
+		if err != nil {
+			t.Fatalf("resolveRequestConfig: %v", err)
+		}
+		want := []string{"TENANT=" + host, `CONFIG={"a":1}`}
+		if !reflect.DeepEqual(cfg.Envs, want) {
+			t.Fatalf("envs = %q, want %q", cfg.Envs, want)
+		}
+		keys[c.getProcessKey(req)] = true
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected one process key per host, got %v", keys)
+	}
+}
+
+// TestSocketTemplateSelectsBackendPerHost verifies socket_template expands to a per-host upstream and process key.
+func TestSocketTemplateSelectsBackendPerHost(t *testing.T) {
+	dir := t.TempDir()
+	c := &ReverseBin{
+		Executable:     []string{"./main.py"},
+		SocketTemplate: "unix/" + dir + "/{http.request.host}.sock",
+		logger:         zaptest.NewLogger(t),
+	}
+
+	keys := map[string]bool{}
+	for _, host := range []string{"a.example", "b.example"} {
+		// This request tests the socket path follows the Host header.
+		req := httptest.NewRequest(http.MethodGet, "http://"+host+"/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, caddyhttp.NewTestReplacer(req)))
+
+		cfg, err := c.resolveRequestConfig(req, c.getProcessKey(req))
+		if err != nil {
+			t.Fatalf("resolveRequestConfig: %v", err)
+		}
+		if want := "unix/" + dir + "/" + host + ".sock"; cfg.ReverseProxyTo != want {
+			t.Fatalf("reverse_proxy_to = %q, want %q", cfg.ReverseProxyTo, want)
+		}
+		keys[c.getProcessKey(req)] = true
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected one process key per host, got %v", keys)
+	}
+}
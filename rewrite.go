@@ -0,0 +1,107 @@
+package reversebin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// ResponseRewrite is one regular expression substitution applied to
+// backend response bodies.
+type ResponseRewrite struct {
+	// Regular expression to find
+	Find string `json:"find"`
+	// Replacement text; $1 or ${name} refer to submatches
+	Replace string `json:"replace"`
+}
+
+// compileResponseRewrites compiles the response_rewrite expressions in order.
+func compileResponseRewrites(rewrites []ResponseRewrite) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, len(rewrites))
+	for i, rw := range rewrites {
+		re, err := regexp.Compile(rw.Find)
+		if err != nil {
+			return nil, fmt.Errorf("response_rewrite %q: %v", rw.Find, err)
+		}
+		res[i] = re
+	}
+	return res, nil
+}
+
+// rewritingTransport reads whole text and JSON backend responses and applies
+// the response_rewrite substitutions to them. Other content types pass
+// through untouched, with a warning the first time each one is seen.
+type rewritingTransport struct {
+	next     http.RoundTripper
+	rewrites []ResponseRewrite
+	patterns []*regexp.Regexp
+	logger   *zap.Logger
+	warned   sync.Map
+}
+
+func (t *rewritingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || !shouldRewriteResponse(req, resp) {
+		return resp, err
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !rewritableMediaType(mediaType) {
+		if _, seen := t.warned.LoadOrStore(mediaType, true); !seen {
+			t.logger.Warn("response_rewrite skipped for non-text content type",
+				zap.String("content_type", mediaType),
+				zap.String("path", req.URL.Path))
+		}
+		return resp, nil
+	}
+	if resp.Header.Get("Content-Encoding") != "" {
+		// Compressed bodies can't be matched; decompress_response decodes
+		// them before they get here.
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading backend response for response_rewrite: %w", err)
+	}
+	for i, re := range t.patterns {
+		body = re.ReplaceAll(body, []byte(t.rewrites[i].Replace))
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.TransferEncoding = nil
+	resp.Header.Del("Transfer-Encoding")
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	// The entity tag described the backend's bytes.
+	resp.Header.Del("ETag")
+	return resp, nil
+}
+
+// shouldRewriteResponse skips responses without a body, partial content and
+// event streams, which must not be read to the end before being sent.
+func shouldRewriteResponse(req *http.Request, resp *http.Response) bool {
+	if req.Method == http.MethodHead {
+		return false
+	}
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+	if resp.StatusCode < 200 {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType != "text/event-stream"
+}
+
+func rewritableMediaType(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "text/") || mediaType == "application/json"
+}
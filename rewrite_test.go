@@ -0,0 +1,56 @@
+package reversebin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// TestRewritingTransportRewritesTextOnly verifies substitutions apply in order to text bodies and leave binary bodies alone.
+func TestRewritingTransportRewritesTextOnly(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// This HTTP request tests a backend serving the same body as HTML or PNG.
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = io.WriteString(w, `<a href="http://internal:8080/x">x</a></body>`)
+	}))
+	defer backend.Close()
+
+	rewrites := []ResponseRewrite{
+		{Find: `http://internal:8080/(\w+)`, Replace: "/app/$1"},
+		{Find: `</body>`, Replace: `<img src="/px"></body>`},
+	}
+	patterns, err := compileResponseRewrites(rewrites)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := &rewritingTransport{next: http.DefaultTransport, rewrites: rewrites, patterns: patterns, logger: zaptest.NewLogger(t)}
+
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		{"text/html; charset=utf-8", `<a href="/app/x">x</a><img src="/px"></body>`},
+		{"image/png", `<a href="http://internal:8080/x">x</a></body>`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, backend.URL+"/?type="+url.QueryEscape(tt.contentType), nil)
+		req.RequestURI = ""
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("%s: RoundTrip: %v", tt.contentType, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != tt.want {
+			t.Fatalf("%s: body = %q, want %q", tt.contentType, body, tt.want)
+		}
+		if resp.ContentLength != int64(len(tt.want)) {
+			t.Fatalf("%s: expected Content-Length %d, got %d", tt.contentType, len(tt.want), resp.ContentLength)
+		}
+	}
+}
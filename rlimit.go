@@ -0,0 +1,11 @@
+package reversebin
+
+import "math"
+
+// cpuSharesNice maps a cgroup-style cpu_shares weight (1024 is the default
+// share) to the nice value with the closest scheduler weight; each nice step
+// changes the weight by about 25%.
+func cpuSharesNice(shares int) int {
+	nice := int(math.Round(math.Log(1024/float64(shares)) / math.Log(1.25)))
+	return max(-20, min(19, nice))
+}
@@ -0,0 +1,25 @@
+//go:build darwin
+
+package reversebin
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// macOS cannot set another process's rlimits, and does not enforce
+// RLIMIT_AS anyway, so only cpu_shares is available.
+func checkResourceLimits(maxMemory int64, cpuShares int) error {
+	if maxMemory > 0 {
+		return fmt.Errorf("max_memory is not supported on macOS")
+	}
+	return nil
+}
+
+func applyResourceLimits(pid int, maxMemory int64, cpuShares int) error {
+	if cpuShares > 0 {
+		return unix.Setpriority(unix.PRIO_PROCESS, pid, cpuSharesNice(cpuShares))
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+//go:build linux
+
+package reversebin
+
+import "golang.org/x/sys/unix"
+
+func checkResourceLimits(maxMemory int64, cpuShares int) error {
+	return nil
+}
+
+// applyResourceLimits limits the address space and CPU priority of a started
+// backend. Descendants it forks afterwards inherit both.
+func applyResourceLimits(pid int, maxMemory int64, cpuShares int) error {
+	if maxMemory > 0 {
+		limit := &unix.Rlimit{Cur: uint64(maxMemory), Max: uint64(maxMemory)}
+		if err := unix.Prlimit(pid, unix.RLIMIT_AS, limit, nil); err != nil {
+			return err
+		}
+	}
+	if cpuShares > 0 {
+		return unix.Setpriority(unix.PRIO_PROCESS, pid, cpuSharesNice(cpuShares))
+	}
+	return nil
+}
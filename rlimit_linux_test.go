@@ -0,0 +1,39 @@
+//go:build linux
+
+package reversebin
+
+import (
+	"os/exec"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// TestApplyResourceLimitsSetsAddressSpaceAndNice verifies max_memory and cpu_shares reach a running child.
+func TestApplyResourceLimitsSetsAddressSpaceAndNice(t *testing.T) {
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer func() { _ = cmd.Process.Kill(); _ = cmd.Wait() }()
+	pid := cmd.Process.Pid
+
+	if err := applyResourceLimits(pid, 512<<20, 512); err != nil {
+		t.Fatalf("applyResourceLimits: %v", err)
+	}
+	var got unix.Rlimit
+	if err := unix.Prlimit(pid, unix.RLIMIT_AS, nil, &got); err != nil {
+		t.Fatalf("prlimit: %v", err)
+	}
+	if got.Cur != 512<<20 {
+		t.Fatalf("RLIMIT_AS = %d, want %d", got.Cur, 512<<20)
+	}
+	// Getpriority returns 20-nice on Linux; cpu_shares 512 is half weight, nice 3.
+	prio, err := unix.Getpriority(unix.PRIO_PROCESS, pid)
+	if err != nil {
+		t.Fatalf("getpriority: %v", err)
+	}
+	if nice := 20 - prio; nice != 3 {
+		t.Fatalf("nice = %d, want 3", nice)
+	}
+}
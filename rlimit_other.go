@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+
+package reversebin
+
+import "fmt"
+
+func checkResourceLimits(maxMemory int64, cpuShares int) error {
+	if maxMemory > 0 || cpuShares > 0 {
+		return fmt.Errorf("max_memory and cpu_shares are only supported on Linux and macOS")
+	}
+	return nil
+}
+
+func applyResourceLimits(pid int, maxMemory int64, cpuShares int) error {
+	return nil
+}
@@ -0,0 +1,126 @@
+package reversebin
+
+import (
+	"fmt"
+	"hash/fnv"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// socketPlaceholder in a route's command expands to that route's socket path.
+const socketPlaceholder = "{socket}"
+
+// PathRoute sends requests under a path prefix, or with a given
+// Content-Type, to a backend of their own.
+type PathRoute struct {
+	// Path prefix, optionally ending in *, such as /api/*
+	Path string `json:"path,omitempty"`
+	// Media type the request's Content-Type must have, such as application/json or text/*
+	ContentType string `json:"contentType,omitempty"`
+	// Command and arguments to launch; {socket} expands to the route's unix socket path
+	Executable []string `json:"executable"`
+}
+
+func (rt PathRoute) prefix() string {
+	return strings.TrimSuffix(rt.Path, "*")
+}
+
+// name identifies the route in errors and process keys.
+func (rt PathRoute) name() string {
+	if rt.Path == "" {
+		return rt.ContentType
+	}
+	return rt.Path
+}
+
+// matchesContentType reports whether r's Content-Type has the route's
+// media type; a type/* route accepts any subtype.
+func (rt PathRoute) matchesContentType(r *http.Request) bool {
+	if rt.ContentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	if major, ok := strings.CutSuffix(rt.ContentType, "/*"); ok {
+		return strings.HasPrefix(mediaType, major+"/")
+	}
+	return mediaType == rt.ContentType
+}
+
+// provisionRoutes checks the routes and assigns each one a unix socket.
+func (c *ReverseBin) provisionRoutes() error {
+	if len(c.Routes) == 0 {
+		return nil
+	}
+	if c.hasDetector() {
+		return fmt.Errorf("route cannot be combined with a dynamic proxy detector")
+	}
+	c.routeSockets = make([]string, len(c.Routes))
+	seen := make(map[string]bool, len(c.Routes))
+	for i := range c.Routes {
+		rt := &c.Routes[i]
+		if rt.Path == "" && rt.ContentType == "" {
+			return fmt.Errorf("route requires a path or content type")
+		}
+		if rt.Path != "" && !strings.HasPrefix(rt.Path, "/") {
+			return fmt.Errorf("route path %q must start with /", rt.Path)
+		}
+		if rt.ContentType != "" {
+			mediaType, _, err := mime.ParseMediaType(rt.ContentType)
+			if err != nil {
+				return fmt.Errorf("route content type %q: %v", rt.ContentType, err)
+			}
+			rt.ContentType = mediaType
+		}
+		id := rt.prefix() + "\x00" + rt.ContentType
+		if seen[id] {
+			return fmt.Errorf("route %s is used more than once", rt.name())
+		}
+		seen[id] = true
+		if len(rt.Executable) == 0 {
+			return fmt.Errorf("route %s requires exec", rt.name())
+		}
+		c.routeSockets[i] = routeSocketPath(c.WorkingDirectory, *rt)
+	}
+	return nil
+}
+
+// routeSocketPath derives a stable socket path for rt, so a backend left
+// over from a previous config is replaced rather than duplicated.
+func routeSocketPath(dir string, rt PathRoute) string {
+	h := fnv.New32a()
+	_, _ = fmt.Fprintf(h, "%s\x00%s\x00%s", dir, rt.name(), strings.Join(rt.Executable, "\x00"))
+	return filepath.Join(os.TempDir(), fmt.Sprintf("reverse-bin-route-%08x.sock", h.Sum32()))
+}
+
+// matchRoute returns the index of the matching route with the longest
+// prefix of r's path, or -1 when the request falls back to the default
+// backend. Content type routes have no prefix, so a matching path route
+// wins over them.
+func (c *ReverseBin) matchRoute(r *http.Request) int {
+	best := -1
+	for i, rt := range c.Routes {
+		if !strings.HasPrefix(r.URL.Path, rt.prefix()) || !rt.matchesContentType(r) {
+			continue
+		}
+		if best < 0 || len(rt.prefix()) > len(c.Routes[best].prefix()) {
+			best = i
+		}
+	}
+	return best
+}
+
+// routeExecutable returns route i's command with {socket} and request
+// placeholders expanded.
+func (c *ReverseBin) routeExecutable(r *http.Request, i int) []string {
+	args := make([]string, len(c.Routes[i].Executable))
+	for j, arg := range c.Routes[i].Executable {
+		args[j] = strings.ReplaceAll(arg, socketPlaceholder, c.routeSockets[i])
+	}
+	return expandArgs(r, args)
+}
@@ -0,0 +1,94 @@
+package reversebin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestRoutesSelectBackendByLongestPrefix verifies each route gets its own command and socket, the longest prefix wins, and other paths fall back.
+func TestRoutesSelectBackendByLongestPrefix(t *testing.T) {
+	rb := &ReverseBin{
+		Executable:     []string{"./app"},
+		ReverseProxyTo: "unix//tmp/app.sock",
+		Routes: []PathRoute{
+			{Path: "/api/*", Executable: []string{"./api-server", "--socket", "{socket}"}},
+			{Path: "/api/admin/*", Executable: []string{"./admin", "--socket={socket}"}},
+		},
+		logger: zaptest.NewLogger(t),
+	}
+	if err := rb.provisionRoutes(); err != nil {
+		t.Fatalf("provisionRoutes: %v", err)
+	}
+
+	tests := []struct {
+		path, wantExec, wantUpstream string
+	}{
+		{path: "/api/users", wantExec: "./api-server --socket " + rb.routeSockets[0], wantUpstream: "unix/" + rb.routeSockets[0]},
+		{path: "/api/admin/x", wantExec: "./admin --socket=" + rb.routeSockets[1], wantUpstream: "unix/" + rb.routeSockets[1]},
+		{path: "/index.html", wantExec: "./app", wantUpstream: "unix//tmp/app.sock"},
+	}
+	keys := map[string]bool{}
+	for _, tt := range tests {
+		// This HTTP request tests which backend the path is routed to.
+		req := httptest.NewRequest(http.MethodGet, "http://localhost"+tt.path, nil)
+		req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, caddy.NewReplacer()))
+		key := rb.getProcessKey(req)
+		cfg, err := rb.resolveRequestConfig(req, key)
+		if err != nil {
+			t.Fatalf("%s: resolveRequestConfig: %v", tt.path, err)
+		}
+		if got := strings.Join(cfg.Executable, " "); got != tt.wantExec || cfg.ReverseProxyTo != tt.wantUpstream {
+			t.Fatalf("%s: expected %q -> %q, got %q -> %q", tt.path, tt.wantExec, tt.wantUpstream, got, cfg.ReverseProxyTo)
+		}
+		keys[key] = true
+	}
+	if len(keys) != len(tests) {
+		t.Fatalf("expected %d distinct process keys, got %v", len(tests), keys)
+	}
+}
+
+// TestContentTypeRoutesSelectBackend verifies requests are routed by media type, path routes take precedence, and other types fall back.
+func TestContentTypeRoutesSelectBackend(t *testing.T) {
+	rb := &ReverseBin{
+		Executable:     []string{"./app"},
+		ReverseProxyTo: "unix//tmp/app.sock",
+		Routes: []PathRoute{
+			{ContentType: "Application/JSON", Executable: []string{"./json-api"}},
+			{ContentType: "text/*", Executable: []string{"./text-api"}},
+			{Path: "/upload/*", Executable: []string{"./uploader"}},
+		},
+		logger: zaptest.NewLogger(t),
+	}
+	if err := rb.provisionRoutes(); err != nil {
+		t.Fatalf("provisionRoutes: %v", err)
+	}
+
+	tests := []struct {
+		path, contentType, wantExec string
+	}{
+		{path: "/items", contentType: "application/json; charset=utf-8", wantExec: "./json-api"},
+		{path: "/items", contentType: "text/csv", wantExec: "./text-api"},
+		{path: "/upload/a", contentType: "application/json", wantExec: "./uploader"},
+		{path: "/items", contentType: "application/x-www-form-urlencoded", wantExec: "./app"},
+		{path: "/items", wantExec: "./app"},
+	}
+	for _, tt := range tests {
+		// This HTTP request tests which backend the content type is routed to.
+		req := httptest.NewRequest(http.MethodPost, "http://localhost"+tt.path, nil)
+		req.Header.Set("Content-Type", tt.contentType)
+		req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, caddy.NewReplacer()))
+		cfg, err := rb.resolveRequestConfig(req, rb.getProcessKey(req))
+		if err != nil {
+			t.Fatalf("%s %s: resolveRequestConfig: %v", tt.path, tt.contentType, err)
+		}
+		if got := strings.Join(cfg.Executable, " "); got != tt.wantExec {
+			t.Fatalf("%s %s: expected %q, got %q", tt.path, tt.contentType, tt.wantExec, got)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package reversebin
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+)
+
+// runAsCredential is the identity the backend is started with under run_as.
+type runAsCredential struct {
+	uid    uint32
+	gid    uint32
+	groups []uint32
+}
+
+// lookupRunAs resolves a run_as user name or uid and checks that Caddy is
+// privileged enough to start processes as that user.
+func lookupRunAs(name string) (*runAsCredential, error) {
+	if runtime.GOOS == "windows" {
+		return nil, fmt.Errorf("run_as is not supported on Windows")
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		u, err = user.LookupId(name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("run_as: %v", err)
+	}
+	cred := &runAsCredential{}
+	if cred.uid, err = parseID(u.Uid); err != nil {
+		return nil, fmt.Errorf("run_as %s: uid: %v", name, err)
+	}
+	if cred.gid, err = parseID(u.Gid); err != nil {
+		return nil, fmt.Errorf("run_as %s: gid: %v", name, err)
+	}
+	gids, err := u.GroupIds()
+	if err != nil {
+		return nil, fmt.Errorf("run_as %s: groups: %v", name, err)
+	}
+	for _, g := range gids {
+		gid, err := parseID(g)
+		if err != nil {
+			return nil, fmt.Errorf("run_as %s: group: %v", name, err)
+		}
+		cred.groups = append(cred.groups, gid)
+	}
+
+	if euid := os.Geteuid(); euid != 0 && uint32(euid) != cred.uid {
+		return nil, fmt.Errorf("run_as %s requires Caddy to run as root (running as uid %d)", name, euid)
+	}
+	return cred, nil
+}
+
+func parseID(id string) (uint32, error) {
+	v, err := strconv.ParseUint(id, 10, 32)
+	return uint32(v), err
+}
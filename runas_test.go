@@ -0,0 +1,27 @@
+//go:build !windows
+
+package reversebin
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+// TestLookupRunAsResolvesCurrentUser verifies run_as accepts a uid and needs no extra privilege to run as oneself.
+func TestLookupRunAsResolvesCurrentUser(t *testing.T) {
+	cred, err := lookupRunAs(strconv.Itoa(os.Getuid()))
+	if err != nil {
+		t.Fatalf("lookupRunAs: %v", err)
+	}
+	if cred.uid != uint32(os.Getuid()) || cred.gid != uint32(os.Getgid()) {
+		t.Fatalf("credential = %d:%d, want %d:%d", cred.uid, cred.gid, os.Getuid(), os.Getgid())
+	}
+}
+
+// TestLookupRunAsRejectsUnknownUser verifies a missing run_as user fails Provision instead of the first spawn.
+func TestLookupRunAsRejectsUnknownUser(t *testing.T) {
+	if _, err := lookupRunAs("reverse-bin-no-such-user"); err == nil {
+		t.Fatal("expected unknown run_as user to be rejected")
+	}
+}
@@ -0,0 +1,221 @@
+package reversebin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+)
+
+// scgiTransport speaks SCGI to the backend instead of HTTP. Requests are
+// framed as a netstring of CGI variables followed by the body; responses are
+// CGI-style header blocks (with an optional Status header) followed by the body.
+type scgiTransport struct {
+	// network and address, when set, override the dial target; otherwise the
+	// address selected by the reverse proxy is used.
+	network string
+	address string
+	// dialTimeout limits connecting to the backend; zero waits indefinitely
+	dialTimeout time.Duration
+}
+
+func (t *scgiTransport) dialTarget(req *http.Request) (string, string) {
+	if t.address != "" {
+		return t.network, t.address
+	}
+	if info, ok := reverseproxy.GetDialInfo(req.Context()); ok {
+		return info.Network, info.Address
+	}
+	return "tcp", req.URL.Host
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *scgiTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, contentLength, err := scgiRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	network, address := t.dialTarget(req)
+	d := net.Dialer{Timeout: t.dialTimeout}
+	conn, err := d.DialContext(req.Context(), network, address)
+	if err != nil {
+		return nil, fmt.Errorf("dialing scgi backend: %w", err)
+	}
+	stop := context.AfterFunc(req.Context(), func() { _ = conn.Close() })
+
+	w := bufio.NewWriter(conn)
+	if _, err := w.Write(scgiHeaderNetstring(req, contentLength)); err == nil && body != nil {
+		_, err = io.Copy(w, body)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		stop()
+		_ = conn.Close()
+		return nil, fmt.Errorf("writing scgi request: %w", err)
+	}
+
+	resp, err := readSCGIResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		stop()
+		_ = conn.Close()
+		return nil, err
+	}
+	resp.Body = &scgiResponseBody{Reader: resp.Body, conn: conn, stop: stop}
+	return resp, nil
+}
+
+// scgiRequestBody returns the request body and its length. SCGI requires
+// CONTENT_LENGTH up front, so bodies of unknown length are buffered.
+func scgiRequestBody(req *http.Request) (io.Reader, int64, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, 0, nil
+	}
+	if req.ContentLength >= 0 {
+		return io.LimitReader(req.Body, req.ContentLength), req.ContentLength, nil
+	}
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading request body: %w", err)
+	}
+	return bytes.NewReader(data), int64(len(data)), nil
+}
+
+func scgiHeaderNetstring(req *http.Request, contentLength int64) []byte {
+	var headers bytes.Buffer
+	add := func(key, value string) {
+		headers.WriteString(key)
+		headers.WriteByte(0)
+		headers.WriteString(value)
+		headers.WriteByte(0)
+	}
+
+	// CONTENT_LENGTH must come first, followed by SCGI=1.
+	add("CONTENT_LENGTH", strconv.FormatInt(contentLength, 10))
+	add("SCGI", "1")
+	add("REQUEST_METHOD", req.Method)
+	add("REQUEST_URI", req.URL.RequestURI())
+	add("PATH_INFO", req.URL.Path)
+	add("QUERY_STRING", req.URL.RawQuery)
+	add("SERVER_PROTOCOL", req.Proto)
+	host, port, err := net.SplitHostPort(req.Host)
+	if err != nil {
+		host = req.Host
+	}
+	if port == "" {
+		port = "80"
+		if req.TLS != nil {
+			port = "443"
+		}
+	}
+	add("SERVER_NAME", host)
+	add("SERVER_PORT", port)
+	if remoteHost, remotePort, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		add("REMOTE_ADDR", remoteHost)
+		add("REMOTE_PORT", remotePort)
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "" {
+		add("CONTENT_TYPE", ct)
+	}
+	// REMOTE_USER is the user an authentication handler such as basic_auth
+	// verified, and AUTH_TYPE the scheme of the credentials it checked.
+	if repl, ok := req.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer); ok {
+		if user, _ := repl.GetString("http.auth.user.id"); user != "" {
+			add("REMOTE_USER", user)
+			if scheme, _, _ := strings.Cut(req.Header.Get("Authorization"), " "); scheme != "" {
+				add("AUTH_TYPE", scheme)
+			}
+		}
+	}
+	add("HTTP_HOST", req.Host)
+	for key, values := range req.Header {
+		if key == "Content-Type" || key == "Content-Length" || key == "Host" {
+			continue
+		}
+		add("HTTP_"+strings.ToUpper(strings.ReplaceAll(key, "-", "_")), strings.Join(values, ", "))
+	}
+
+	out := make([]byte, 0, headers.Len()+16)
+	out = strconv.AppendInt(out, int64(headers.Len()), 10)
+	out = append(out, ':')
+	out = append(out, headers.Bytes()...)
+	return append(out, ',')
+}
+
+// readSCGIResponse parses a CGI-style response. Backends that write a full
+// HTTP status line are accepted as well.
+func readSCGIResponse(br *bufio.Reader, req *http.Request) (*http.Response, error) {
+	if peek, err := br.Peek(5); err == nil && string(peek) == "HTTP/" {
+		resp, err := http.ReadResponse(br, req)
+		if err != nil {
+			return nil, fmt.Errorf("reading scgi response: %w", err)
+		}
+		return resp, nil
+	}
+
+	mimeHeader, err := textproto.NewReader(br).ReadMIMEHeader()
+	if err != nil {
+		return nil, fmt.Errorf("reading scgi response headers: %w", err)
+	}
+	header := http.Header(mimeHeader)
+
+	status := http.StatusOK
+	if s := header.Get("Status"); s != "" {
+		code, _, _ := strings.Cut(s, " ")
+		status, err = strconv.Atoi(code)
+		if err != nil || status < 100 || status > 599 {
+			return nil, fmt.Errorf("invalid scgi response status %q", s)
+		}
+		header.Del("Status")
+	} else if header.Get("Location") != "" {
+		status = http.StatusFound
+	}
+
+	contentLength := int64(-1)
+	if cl := header.Get("Content-Length"); cl != "" {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil && n >= 0 {
+			contentLength = n
+		}
+	}
+	var body io.Reader = br
+	if contentLength >= 0 {
+		body = io.LimitReader(br, contentLength)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(body),
+		ContentLength: contentLength,
+		Request:       req,
+	}, nil
+}
+
+type scgiResponseBody struct {
+	io.Reader
+	conn net.Conn
+	stop func() bool
+}
+
+func (b *scgiResponseBody) Close() error {
+	b.stop()
+	return b.conn.Close()
+}
+
+var _ http.RoundTripper = (*scgiTransport)(nil)
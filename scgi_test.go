@@ -0,0 +1,264 @@
+package reversebin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+)
+
+// readSCGIRequest decodes one SCGI request: its netstring headers and the
+// CONTENT_LENGTH bytes of body after them.
+func readSCGIRequest(br *bufio.Reader) (map[string]string, []byte, error) {
+	lenStr, err := br.ReadString(':')
+	if err != nil {
+		return nil, nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSuffix(lenStr, ":"))
+	block := make([]byte, n+1) // headers plus trailing ','
+	if _, err := io.ReadFull(br, block); err != nil {
+		return nil, nil, err
+	}
+	fields := bytes.Split(block[:n], []byte{0})
+	env := map[string]string{}
+	for i := 0; i+1 < len(fields); i += 2 {
+		env[string(fields[i])] = string(fields[i+1])
+	}
+	size, _ := strconv.Atoi(env["CONTENT_LENGTH"])
+	body := make([]byte, size)
+	if _, err := io.ReadFull(br, body); err != nil {
+		return nil, nil, err
+	}
+	return env, body, nil
+}
+
+// serveOneSCGI accepts a single SCGI request, decodes its netstring headers,
+// and answers with a CGI-style response describing what it received.
+func serveOneSCGI(t *testing.T, ln net.Listener) {
+	t.Helper()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		env, body, err := readSCGIRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(conn, "Status: 201 Created\r\nContent-Type: text/plain\r\n\r\n%s %s %s %s body=%s",
+			env["SCGI"], env["REQUEST_METHOD"], env["REQUEST_URI"], env["HTTP_X_TRACE"], body)
+	}()
+}
+
+// TestSCGITransportRoundTrip verifies requests are framed as SCGI and CGI-style responses are parsed back into HTTP.
+func TestSCGITransportRoundTrip(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "scgi.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	serveOneSCGI(t, ln)
+
+	// This HTTP request tests method, URI, headers, and body all cross the SCGI framing.
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/app?x=1", strings.NewReader("hello"))
+	req.Header.Set("X-Trace", "abc")
+	resp, err := (&scgiTransport{network: "unix", address: sock}).RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	defer resp.Body.Close()
+	got, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/plain" {
+		t.Fatalf("content-type = %q, want text/plain", ct)
+	}
+	if resp.Header.Get("Status") != "" {
+		t.Fatalf("Status pseudo-header must not be forwarded, got %q", resp.Header.Get("Status"))
+	}
+	if want := "1 POST /app?x=1 abc body=hello"; string(got) != want {
+		t.Fatalf("body = %q, want %q", got, want)
+	}
+}
+
+// TestSCGITransportPassesMultipartUpload verifies a multipart/form-data upload reaches the backend intact, with CONTENT_TYPE carrying the boundary and the exact CONTENT_LENGTH.
+func TestSCGITransportPassesMultipartUpload(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "scgi.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	var upload bytes.Buffer
+	mw := multipart.NewWriter(&upload)
+	_ = mw.WriteField("title", "report")
+	fw, _ := mw.CreateFormFile("file", "data.bin")
+	fileData := bytes.Repeat([]byte{0, 1, 2, 0xff, '\r', '\n', '-', '-'}, 8192)
+	_, _ = fw.Write(fileData)
+	_ = mw.Close()
+
+	type received struct {
+		env  map[string]string
+		body []byte
+	}
+	got := make(chan received, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		env, body, err := readSCGIRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		got <- received{env, body}
+		fmt.Fprint(conn, "Status: 204 No Content\r\n\r\n")
+	}()
+
+	// This HTTP request tests a file upload crossing the SCGI framing.
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/upload", bytes.NewReader(upload.Bytes()))
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := (&scgiTransport{network: "unix", address: sock}).RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	resp.Body.Close()
+	r := <-got
+
+	if ct := r.env["CONTENT_TYPE"]; ct != mw.FormDataContentType() {
+		t.Fatalf("CONTENT_TYPE = %q, want %q", ct, mw.FormDataContentType())
+	}
+	if cl := r.env["CONTENT_LENGTH"]; cl != strconv.Itoa(upload.Len()) {
+		t.Fatalf("CONTENT_LENGTH = %q, want %d", cl, upload.Len())
+	}
+	_, params, err := mime.ParseMediaType(r.env["CONTENT_TYPE"])
+	if err != nil {
+		t.Fatalf("CONTENT_TYPE: %v", err)
+	}
+	form, err := multipart.NewReader(bytes.NewReader(r.body), params["boundary"]).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("backend could not parse the upload: %v", err)
+	}
+	defer func() { _ = form.RemoveAll() }()
+	if title := form.Value["title"]; len(title) != 1 || title[0] != "report" {
+		t.Fatalf("title = %q, want [report]", title)
+	}
+	if len(form.File["file"]) != 1 {
+		t.Fatalf("expected one uploaded file, got %d", len(form.File["file"]))
+	}
+	f, err := form.File["file"][0].Open()
+	if err != nil {
+		t.Fatalf("open upload: %v", err)
+	}
+	defer f.Close()
+	data, _ := io.ReadAll(f)
+	if form.File["file"][0].Filename != "data.bin" || !bytes.Equal(data, fileData) {
+		t.Fatalf("expected data.bin with %d bytes intact, got %s with %d bytes", len(fileData), form.File["file"][0].Filename, len(data))
+	}
+}
+
+// scgiParams decodes the header netstring sent for req into a map.
+func scgiParams(t *testing.T, req *http.Request) map[string]string {
+	t.Helper()
+	raw := scgiHeaderNetstring(req, 0)
+	lenStr, rest, _ := bytes.Cut(raw, []byte{':'})
+	n, err := strconv.Atoi(string(lenStr))
+	if err != nil {
+		t.Fatalf("netstring length %q: %v", lenStr, err)
+	}
+	fields := bytes.Split(rest[:n], []byte{0})
+	params := map[string]string{}
+	for i := 0; i+1 < len(fields); i += 2 {
+		params[string(fields[i])] = string(fields[i+1])
+	}
+	return params
+}
+
+// TestSCGIHeadersIncludeRemoteAddr verifies REMOTE_ADDR and REMOTE_PORT are split from the client address, including bracketed IPv6.
+func TestSCGIHeadersIncludeRemoteAddr(t *testing.T) {
+	for remote, want := range map[string][2]string{
+		"192.0.2.7:51234":     {"192.0.2.7", "51234"},
+		"[2001:db8::1]:51234": {"2001:db8::1", "51234"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+		req.RemoteAddr = remote
+		params := scgiParams(t, req)
+		if params["REMOTE_ADDR"] != want[0] || params["REMOTE_PORT"] != want[1] {
+			t.Fatalf("RemoteAddr %s: got REMOTE_ADDR=%q REMOTE_PORT=%q, want %q %q", remote, params["REMOTE_ADDR"], params["REMOTE_PORT"], want[0], want[1])
+		}
+	}
+}
+
+// TestSCGIHeadersIncludeServerVars verifies SERVER_NAME, SERVER_PORT and SERVER_PROTOCOL, with the port defaulting by scheme.
+func TestSCGIHeadersIncludeServerVars(t *testing.T) {
+	tests := []struct {
+		url, name, port string
+	}{
+		{url: "http://app.example:8080/", name: "app.example", port: "8080"},
+		{url: "http://app.example/", name: "app.example", port: "80"},
+		{url: "https://app.example/", name: "app.example", port: "443"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+		req.Proto = "HTTP/2.0"
+		params := scgiParams(t, req)
+		if params["SERVER_NAME"] != tt.name || params["SERVER_PORT"] != tt.port || params["SERVER_PROTOCOL"] != "HTTP/2.0" {
+			t.Fatalf("%s: got SERVER_NAME=%q SERVER_PORT=%q SERVER_PROTOCOL=%q, want %q %q HTTP/2.0",
+				tt.url, params["SERVER_NAME"], params["SERVER_PORT"], params["SERVER_PROTOCOL"], tt.name, tt.port)
+		}
+	}
+}
+
+// TestSCGIHeadersIncludeAuth verifies REMOTE_USER and AUTH_TYPE are set only for a user an auth handler verified, and the Authorization header is passed as HTTP_AUTHORIZATION either way.
+func TestSCGIHeadersIncludeAuth(t *testing.T) {
+	tests := []struct {
+		name, authorization, user string
+		wantUser, wantType        string
+	}{
+		{name: "basic auth", authorization: "Basic YWxpY2U6c2VjcmV0", user: "alice", wantUser: "alice", wantType: "Basic"},
+		{name: "bearer token", authorization: "Bearer eyJhbGciOi", user: "svc-42", wantUser: "svc-42", wantType: "Bearer"},
+		{name: "unauthenticated", authorization: "Basic bWFsbG9yeTp4"},
+	}
+	for _, tt := range tests {
+		// This HTTP request tests what the backend learns about the client's credentials.
+		req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+		req.Header.Set("Authorization", tt.authorization)
+		repl := caddy.NewReplacer()
+		if tt.user != "" {
+			repl.Set("http.auth.user.id", tt.user)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, repl))
+		params := scgiParams(t, req)
+
+		// Unauthenticated requests must not carry the variables at all.
+		user, userSet := params["REMOTE_USER"]
+		if user != tt.wantUser || userSet != (tt.wantUser != "") {
+			t.Fatalf("%s: REMOTE_USER = %q (set %v), want %q", tt.name, user, userSet, tt.wantUser)
+		}
+		authType, authTypeSet := params["AUTH_TYPE"]
+		if authType != tt.wantType || authTypeSet != (tt.wantType != "") {
+			t.Fatalf("%s: AUTH_TYPE = %q (set %v), want %q", tt.name, authType, authTypeSet, tt.wantType)
+		}
+		if params["HTTP_AUTHORIZATION"] != tt.authorization {
+			t.Fatalf("%s: HTTP_AUTHORIZATION = %q, want %q", tt.name, params["HTTP_AUTHORIZATION"], tt.authorization)
+		}
+	}
+}
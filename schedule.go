@@ -0,0 +1,49 @@
+package reversebin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// parseRestartSchedule parses a restart_schedule cron expression: five
+// fields, or a descriptor such as @daily, optionally prefixed with
+// CRON_TZ=<zone>.
+func parseRestartSchedule(spec string) (cron.Schedule, error) {
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("restart_schedule %q: %v", spec, err)
+	}
+	return sched, nil
+}
+
+// startRestartSchedule restarts the backends at every time restart_schedule
+// names, letting in-flight requests drain first.
+func (c *ReverseBin) startRestartSchedule() error {
+	if c.RestartSchedule == "" {
+		return nil
+	}
+	sched, err := parseRestartSchedule(c.RestartSchedule)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			next := sched.Next(time.Now())
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-timer.C:
+				c.logger.Info("scheduled restart, restarting backends",
+					zap.String("trigger", "restart_schedule"),
+					zap.String("schedule", c.RestartSchedule))
+				c.restartBackends("restart_schedule " + c.RestartSchedule)
+			case <-c.done():
+				timer.Stop()
+				return
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,153 @@
+package reversebin
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/caddyserver/caddy/v2/notify"
+	"go.uber.org/zap"
+)
+
+// listenFDsStart is the first file descriptor systemd passes to a
+// socket-activated service.
+const listenFDsStart = 3
+
+// activationFDs holds the sockets inherited from systemd. They are shared by
+// every config instance and never closed: an *os.File closes its descriptor
+// when garbage collected, and systemd will not pass it again.
+var (
+	activationMu  sync.Mutex
+	activationFDs = map[int]*os.File{}
+)
+
+// activationSocket is a listening socket systemd handed to Caddy.
+type activationSocket struct {
+	file *os.File
+	// name is the socket unit's FileDescriptorName, if any
+	name string
+	// upstream is the socket's address in reverse_proxy_to form
+	upstream string
+}
+
+// listenFDCount returns how many sockets systemd passed to this process,
+// following sd_listen_fds(3): LISTEN_PID must name this process.
+func listenFDCount(getenv func(string) string, pid int) (int, error) {
+	fds := getenv("LISTEN_FDS")
+	if fds == "" {
+		return 0, fmt.Errorf("LISTEN_FDS is not set; is Caddy started by a systemd socket unit?")
+	}
+	if listenPID := getenv("LISTEN_PID"); listenPID != strconv.Itoa(pid) {
+		return 0, fmt.Errorf("LISTEN_PID %q does not match Caddy's pid %d", listenPID, pid)
+	}
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid LISTEN_FDS %q", fds)
+	}
+	return n, nil
+}
+
+// inheritActivationSocket claims socket index (file descriptor 3+index)
+// from the sockets systemd passed to Caddy.
+func inheritActivationSocket(index int) (*activationSocket, error) {
+	n, err := listenFDCount(os.Getenv, os.Getpid())
+	if err != nil {
+		return nil, fmt.Errorf("socket_activation: %v", err)
+	}
+	if index >= n {
+		return nil, fmt.Errorf("socket_activation: index %d out of range, systemd passed %d sockets", index, n)
+	}
+	fd := listenFDsStart + index
+
+	activationMu.Lock()
+	f, ok := activationFDs[fd]
+	if !ok {
+		// Keep the socket from leaking into detectors and hooks; the
+		// backend receives it through ExtraFiles.
+		syscall.CloseOnExec(fd)
+		f = os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		activationFDs[fd] = f
+	}
+	activationMu.Unlock()
+
+	upstream, err := socketUpstream(f)
+	if err != nil {
+		return nil, fmt.Errorf("socket_activation: fd %d: %v", fd, err)
+	}
+	var name string
+	if names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":"); index < len(names) {
+		name = names[index]
+	}
+	return &activationSocket{file: f, name: name, upstream: upstream}, nil
+}
+
+// socketUpstream returns the reverse_proxy_to address of the socket f is
+// bound to.
+func socketUpstream(f *os.File) (string, error) {
+	sa, err := syscall.Getsockname(int(f.Fd()))
+	if err != nil {
+		return "", err
+	}
+	switch sa := sa.(type) {
+	case *syscall.SockaddrUnix:
+		if sa.Name == "" {
+			return "", fmt.Errorf("unix socket is not bound to a path")
+		}
+		return "unix/" + sa.Name, nil
+	case *syscall.SockaddrInet4:
+		ip := net.IP(sa.Addr[:])
+		if ip.IsUnspecified() {
+			ip = net.IPv4(127, 0, 0, 1)
+		}
+		return net.JoinHostPort(ip.String(), strconv.Itoa(sa.Port)), nil
+	case *syscall.SockaddrInet6:
+		ip := net.IP(sa.Addr[:])
+		if ip.IsUnspecified() {
+			ip = net.IPv6loopback
+		}
+		return net.JoinHostPort(ip.String(), strconv.Itoa(sa.Port)), nil
+	}
+	return "", fmt.Errorf("unsupported socket address %T", sa)
+}
+
+// activationEnv describes the passed socket to the backend: the systemd
+// variables, apart from LISTEN_PID, plus its address in the same variables
+// the backend would otherwise be configured with.
+func (s *activationSocket) activationEnv() []string {
+	env := []string{"LISTEN_FDS=1"}
+	if s.name != "" {
+		env = append(env, "LISTEN_FDNAMES="+s.name)
+	}
+	if path, ok := strings.CutPrefix(s.upstream, "unix/"); ok {
+		return append(env, "REVERSE_BIN_SOCKET_PATH="+path)
+	}
+	host, port, _ := net.SplitHostPort(s.upstream)
+	return append(env, "REVERSE_BIN_HOST="+host, "REVERSE_BIN_PORT="+port)
+}
+
+// passActivationSocket hands the inherited socket to the backend as file
+// descriptor 3. LISTEN_PID must hold the backend's own pid, which is only
+// known after fork, so the command is started through sh, which exports its
+// pid and then execs the backend in place.
+func (c *ReverseBin) passActivationSocket(cmd *exec.Cmd) {
+	cmd.ExtraFiles = []*os.File{c.activation.file}
+	cmd.Env = append(cmd.Env, c.activation.activationEnv()...)
+	args := append([]string{"/bin/sh", "-c", `LISTEN_PID=$$; export LISTEN_PID; exec "$0" "$@"`, cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = "/bin/sh"
+	cmd.Args = args
+}
+
+// notifyBackendReady sends sd_notify READY=1 once a socket-activated backend
+// has passed its readiness check.
+func (c *ReverseBin) notifyBackendReady(rb *runningBackend) {
+	if err := notify.Ready(); err != nil {
+		c.logger.Warn("failed to notify systemd of backend readiness",
+			zap.Int("pid", rb.process.Pid()),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,83 @@
+package reversebin
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestListenFDCountChecksListenPID verifies sockets are only claimed when systemd addressed them to this process.
+func TestListenFDCountChecksListenPID(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+	if n, err := listenFDCount(env(map[string]string{"LISTEN_FDS": "2", "LISTEN_PID": "42"}), 42); err != nil || n != 2 {
+		t.Fatalf("expected 2 sockets, got %d, %v", n, err)
+	}
+	if _, err := listenFDCount(env(map[string]string{"LISTEN_FDS": "2", "LISTEN_PID": "7"}), 42); err == nil {
+		t.Fatal("expected an error for another process's LISTEN_PID")
+	}
+	if _, err := listenFDCount(env(nil), 42); err == nil {
+		t.Fatal("expected an error without LISTEN_FDS")
+	}
+}
+
+// TestSocketUpstreamReportsBoundAddress verifies inherited sockets map to reverse_proxy_to addresses, wildcards to loopback.
+func TestSocketUpstreamReportsBoundAddress(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	unixLn, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	defer unixLn.Close()
+	tcpLn, err := net.Listen("tcp4", "0.0.0.0:0")
+	if err != nil {
+		t.Fatalf("listen tcp: %v", err)
+	}
+	defer tcpLn.Close()
+
+	for ln, want := range map[net.Listener]string{
+		unixLn: "unix/" + sock,
+		tcpLn:  fmt.Sprintf("127.0.0.1:%d", tcpLn.Addr().(*net.TCPAddr).Port),
+	} {
+		f, err := ln.(interface{ File() (*os.File, error) }).File()
+		if err != nil {
+			t.Fatalf("listener file: %v", err)
+		}
+		got, err := socketUpstream(f)
+		f.Close()
+		if err != nil || got != want {
+			t.Fatalf("expected %q, got %q, %v", want, got, err)
+		}
+	}
+}
+
+// TestPassActivationSocketExportsBackendPID verifies the backend gets the socket as fd 3 with LISTEN_PID set to its own pid.
+func TestPassActivationSocketExportsBackendPID(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	f, err := ln.(*net.UnixListener).File()
+	if err != nil {
+		t.Fatalf("listener file: %v", err)
+	}
+	defer f.Close()
+	c := &ReverseBin{activation: &activationSocket{file: f, upstream: "unix/" + sock}}
+
+	cmd := exec.Command("/bin/sh", "-c", `test "$LISTEN_PID" = "$$" && test -S /dev/fd/3 && echo "$LISTEN_FDS $REVERSE_BIN_SOCKET_PATH"`)
+	c.passActivationSocket(cmd)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("backend: %v", err)
+	}
+	if got, want := strings.TrimSpace(string(out)), "1 "+sock; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,81 @@
+package reversebin
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	socketTypeFilesystem = "filesystem"
+	socketTypeAbstract   = "abstract"
+)
+
+// provisionSocketPermissions parses socket_mode and socket_group.
+func (c *ReverseBin) provisionSocketPermissions() error {
+	switch c.SocketType {
+	case "", socketTypeFilesystem:
+	case socketTypeAbstract:
+		if runtime.GOOS != "linux" {
+			return fmt.Errorf("socket_type abstract is only supported on Linux")
+		}
+		if c.SocketMode != "" || c.SocketGroup != "" {
+			return fmt.Errorf("socket_mode and socket_group do not apply to abstract sockets")
+		}
+	default:
+		return fmt.Errorf("socket_type must be filesystem or abstract, got %q", c.SocketType)
+	}
+	if c.SocketMode != "" {
+		mode, err := strconv.ParseUint(c.SocketMode, 8, 32)
+		if err != nil || mode > 0o777 {
+			return fmt.Errorf("socket_mode must be octal permission bits such as 0660, got %q", c.SocketMode)
+		}
+		c.socketMode = os.FileMode(mode)
+	}
+	if c.SocketGroup != "" {
+		group, err := user.LookupGroup(c.SocketGroup)
+		if err != nil {
+			group, err = user.LookupGroupId(c.SocketGroup)
+		}
+		if err != nil {
+			return fmt.Errorf("socket_group: %v", err)
+		}
+		gid, err := strconv.Atoi(group.Gid)
+		if err != nil {
+			return fmt.Errorf("socket_group %s has non-numeric gid %q", c.SocketGroup, group.Gid)
+		}
+		c.socketGID = gid
+	}
+	return nil
+}
+
+// secureSocket applies socket_mode and socket_group to a backend's unix
+// socket. It reports false until the backend has created the socket, so the
+// socket is restricted before it is first considered ready.
+func (c *ReverseBin) secureSocket(cfg resolvedConfig) (bool, error) {
+	if (c.SocketMode == "" && c.SocketGroup == "") || !isUnixUpstream(cfg.ReverseProxyTo) {
+		return true, nil
+	}
+	socketPath := strings.TrimPrefix(cfg.ReverseProxyTo, "unix/")
+	if isAbstractSocket(socketPath) {
+		// Abstract sockets have no file permissions to set.
+		return true, nil
+	}
+	if !isUnixSocketHealthy(socketPath) {
+		return false, nil
+	}
+	if c.SocketMode != "" {
+		if err := os.Chmod(socketPath, c.socketMode); err != nil {
+			return false, fmt.Errorf("socket_mode: %v", err)
+		}
+	}
+	if c.SocketGroup != "" {
+		if err := os.Chown(socketPath, -1, c.socketGID); err != nil {
+			return false, fmt.Errorf("socket_group: %v", err)
+		}
+	}
+	return true, nil
+}
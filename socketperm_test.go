@@ -0,0 +1,85 @@
+package reversebin
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestWaitHealthyAppliesSocketPermissions verifies socket_mode and socket_group are applied before the socket is reported ready.
+func TestWaitHealthyAppliesSocketPermissions(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	rb := &ReverseBin{SocketMode: "0600", SocketGroup: strconv.Itoa(os.Getgid()), logger: zaptest.NewLogger(t)}
+	if err := rb.provisionSocketPermissions(); err != nil {
+		t.Fatalf("provisionSocketPermissions: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := rb.waitHealthy(ctx, nil, resolvedConfig{ReverseProxyTo: "unix/" + sock}, nil); err != nil {
+		t.Fatalf("waitHealthy: %v", err)
+	}
+
+	info, err := os.Stat(sock)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("socket mode = %#o, want 0600", perm)
+	}
+}
+
+// TestProvisionSocketPermissionsRejectsInvalidMode verifies socket_mode must be octal permission bits.
+func TestProvisionSocketPermissionsRejectsInvalidMode(t *testing.T) {
+	for _, mode := range []string{"rw", "0800", "01777"} {
+		if err := (&ReverseBin{SocketMode: mode}).provisionSocketPermissions(); err == nil {
+			t.Fatalf("socket_mode %q accepted, want error", mode)
+		}
+	}
+}
+
+// TestAbstractSocketTypeRewritesAndProbesAddress verifies socket_type abstract turns the upstream into an abstract address that is probed by connecting.
+func TestAbstractSocketTypeRewritesAndProbesAddress(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("abstract sockets are Linux only")
+	}
+	name := fmt.Sprintf("reverse-bin-test-%d", os.Getpid())
+	rb := &ReverseBin{Executable: []string{"./app"}, ReverseProxyTo: "unix/" + name, SocketType: socketTypeAbstract, logger: zaptest.NewLogger(t)}
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, caddy.NewReplacer()))
+
+	cfg, err := rb.resolveRequestConfig(req, rb.getProcessKey(req))
+	if err != nil {
+		t.Fatalf("resolveRequestConfig: %v", err)
+	}
+	if cfg.ReverseProxyTo != "unix/@"+name {
+		t.Fatalf("expected upstream unix/@%s, got %q", name, cfg.ReverseProxyTo)
+	}
+	if isUnixSocketHealthy("@" + name) {
+		t.Fatalf("expected abstract socket @%s to be unhealthy before listening", name)
+	}
+	ln, err := net.Listen("unix", "@"+name)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	if !isUnixSocketHealthy("@" + name) {
+		t.Fatalf("expected abstract socket @%s to be healthy while listening", name)
+	}
+}
@@ -0,0 +1,45 @@
+package reversebin
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// socketEnv tells a backend under socket_path_rotate which socket to
+// listen on, since the path changes with every launch.
+const socketEnv = "REVERSE_BIN_SOCKET"
+
+// provisionSocketRotate checks socket_path_rotate against settings that
+// pin the socket path.
+func (c *ReverseBin) provisionSocketRotate() error {
+	if !c.SocketPathRotate {
+		return nil
+	}
+	if c.SocketActivation != nil || len(c.Routes) > 0 || c.HotConfigReload || c.PerRequestProcess {
+		return fmt.Errorf("socket_path_rotate cannot be combined with socket_activation, route, hot_config_reload or per_request_process")
+	}
+	return nil
+}
+
+// rotatedSocketPath inserts generation before the socket's extension:
+// app.sock becomes app.3.sock.
+func rotatedSocketPath(path string, generation int) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + strconv.Itoa(generation) + ext
+}
+
+// rotateSocket moves cfg's unix socket to the given generation and passes
+// the new path to the backend in REVERSE_BIN_SOCKET. Other upstreams are
+// left alone.
+func rotateSocket(cfg resolvedConfig, generation int) resolvedConfig {
+	if !isUnixUpstream(cfg.ReverseProxyTo) {
+		return cfg
+	}
+	path := rotatedSocketPath(strings.TrimPrefix(cfg.ReverseProxyTo, "unix/"), generation)
+	cfg.ReverseProxyTo = "unix/" + path
+	cfg.Envs = append(slices.Clone(cfg.Envs), socketEnv+"="+path)
+	return cfg
+}
@@ -0,0 +1,79 @@
+package reversebin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestRotatedSocketPath verifies the generation goes before the socket's extension.
+func TestRotatedSocketPath(t *testing.T) {
+	for _, tc := range []struct{ path, want string }{
+		{"/run/app.sock", "/run/app.3.sock"},
+		{"/run/app", "/run/app.3"},
+	} {
+		if got := rotatedSocketPath(tc.path, 3); got != tc.want {
+			t.Errorf("rotatedSocketPath(%q, 3) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+// TestSocketPathRotateUsesNewSocketAfterRestart verifies each launch listens on the next generation's socket and the previous one is cleaned up.
+func TestSocketPathRotateUsesNewSocketAfterRestart(t *testing.T) {
+	f := useMockProcesses(t, "", http.NotFoundHandler())
+	dir, err := os.MkdirTemp("", "rb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	sock := filepath.Join(dir, "app.sock")
+	c := &ReverseBin{
+		Executable:            []string{"./app"},
+		ReverseProxyTo:        "unix/" + sock,
+		SocketPathRotate:      true,
+		IdleTimeoutMS:         60000,
+		HealthTimeoutMS:       2000,
+		TerminationGraceMS:    1000,
+		TerminationKillWaitMS: 1000,
+		processes:             map[string]*processState{},
+		logger:                zaptest.NewLogger(t),
+	}
+	t.Cleanup(func() { _ = c.Cleanup() })
+	if err := c.provisionSocketRotate(); err != nil {
+		t.Fatalf("provision: %v", err)
+	}
+
+	for gen, want := range []string{"app.1.sock", "app.2.sock"} {
+		// This HTTP request tests which socket the proxy is sent to after each launch.
+		req := caddyhttp.PrepareRequest(httptest.NewRequest(http.MethodGet, "http://app.example/", nil), caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+		upstreams, err := c.GetUpstreams(req)
+		if err != nil {
+			t.Fatalf("generation %d: %v", gen+1, err)
+		}
+		if got := upstreams[0].Dial; got != "unix/"+filepath.Join(dir, want) {
+			t.Fatalf("generation %d: expected upstream on %s, got %s", gen+1, want, got)
+		}
+		ps := c.getOrCreateProcessState(c.getProcessKey(req))
+		if err := c.sendSupervisorCommand(ps, supervisorRestart, "test restart"); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-f.started[gen].exited:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("generation %d: expected backend to stop on restart", gen+1)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app.1.sock")); !os.IsNotExist(err) {
+		t.Fatalf("expected app.1.sock to be removed, got %v", err)
+	}
+	if n := f.starts.Load(); n != 2 {
+		t.Fatalf("expected 2 launches, got %d", n)
+	}
+}
@@ -0,0 +1,51 @@
+package reversebin
+
+import (
+	"fmt"
+	"time"
+)
+
+// startup_timeout_action values.
+const (
+	// startupActionFail fails the request as soon as its backend fails to
+	// start.
+	startupActionFail = "503"
+	// startupActionQueue relaunches the backend straight away, holding the
+	// request until one starts or the client gives up.
+	startupActionQueue = "queue"
+	// startupActionRetry is like startupActionQueue but waits
+	// startup_retry_delay_ms between launches.
+	startupActionRetry = "retry"
+)
+
+const defaultStartupRetryDelay = time.Second
+
+// provisionStartupAction checks startup_timeout_action and
+// startup_retry_delay_ms.
+func (c *ReverseBin) provisionStartupAction() error {
+	switch c.StartupTimeoutAction {
+	case "", startupActionFail, startupActionQueue:
+		if c.StartupRetryDelayMS > 0 {
+			return fmt.Errorf("startup_retry_delay_ms requires startup_timeout_action retry")
+		}
+	case startupActionRetry:
+	default:
+		return fmt.Errorf("startup_timeout_action must be 503, queue or retry, got %q", c.StartupTimeoutAction)
+	}
+	return nil
+}
+
+// startupRetry reports whether a request whose backend failed to start
+// should ask for another launch, and how long to wait first.
+func (c *ReverseBin) startupRetry() (time.Duration, bool) {
+	switch c.StartupTimeoutAction {
+	case startupActionQueue:
+		return 0, true
+	case startupActionRetry:
+		if c.StartupRetryDelayMS > 0 {
+			return time.Duration(c.StartupRetryDelayMS) * time.Millisecond, true
+		}
+		return defaultStartupRetryDelay, true
+	}
+	return 0, false
+}
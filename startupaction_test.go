@@ -0,0 +1,89 @@
+package reversebin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// failingProcessFactory fails the first fails launches, then hands over to next.
+type failingProcessFactory struct {
+	fails    int32
+	attempts atomic.Int32
+	next     processFactory
+}
+
+func (f *failingProcessFactory) Start(ctx context.Context, cmd *exec.Cmd) (backendProcess, error) {
+	if f.attempts.Add(1) <= f.fails {
+		return nil, errors.New("exec failed")
+	}
+	return f.next.Start(ctx, cmd)
+}
+
+// TestStartupTimeoutAction verifies a request whose backend fails to start is failed by default and waits for a relaunch with queue or retry.
+func TestStartupTimeoutAction(t *testing.T) {
+	for _, tc := range []struct {
+		action  string
+		delayMS int
+		wantErr bool
+	}{
+		{"", 0, true},
+		{startupActionFail, 0, true},
+		{startupActionQueue, 0, false},
+		{startupActionRetry, 10, false},
+	} {
+		t.Run("action="+tc.action, func(t *testing.T) {
+			sock := filepath.Join(t.TempDir(), "app.sock")
+			mock := useMockProcesses(t, sock, http.NotFoundHandler())
+			f := &failingProcessFactory{fails: 2, next: mock}
+			backendProcesses = f
+			c := &ReverseBin{
+				Executable:            []string{"./app"},
+				ReverseProxyTo:        "unix/" + sock,
+				StartupTimeoutAction:  tc.action,
+				StartupRetryDelayMS:   tc.delayMS,
+				IdleTimeoutMS:         60000,
+				HealthTimeoutMS:       2000,
+				TerminationGraceMS:    1000,
+				TerminationKillWaitMS: 1000,
+				processes:             map[string]*processState{},
+				logger:                zaptest.NewLogger(t),
+			}
+			t.Cleanup(func() { _ = c.Cleanup() })
+			if err := c.provisionStartupAction(); err != nil {
+				t.Fatalf("provision: %v", err)
+			}
+
+			// This HTTP request tests whether the request outlives its backend's failed launches.
+			req := caddyhttp.PrepareRequest(httptest.NewRequest(http.MethodGet, "http://app.example/", nil), caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+			upstreams, err := c.GetUpstreams(req)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected launch error, got upstreams %v", upstreams)
+				}
+				if n := f.attempts.Load(); n != 1 {
+					t.Fatalf("expected 1 launch attempt, got %d", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected the third launch to serve the request, got %v", err)
+			}
+			if upstreams[0].Dial != "unix/"+sock {
+				t.Fatalf("expected upstream unix/%s, got %s", sock, upstreams[0].Dial)
+			}
+			if n := f.attempts.Load(); n != 3 {
+				t.Fatalf("expected 3 launch attempts, got %d", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package reversebin
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// statusPageSecretHeader carries status_page_secret on status page requests.
+const statusPageSecretHeader = "X-Reverse-Bin-Status-Secret"
+
+// processStatus is what the status page shows about one process key. The
+// supervisor and request goroutines update it; the status page reads it.
+type processStatus struct {
+	mu          sync.Mutex
+	backend     *runningBackend
+	startedAt   time.Time
+	requests    int64
+	lastError   string
+	lastErrorAt time.Time
+}
+
+func (s *processStatus) launched(rb *runningBackend) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backend = rb
+	s.startedAt = time.Now()
+}
+
+func (s *processStatus) request() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+}
+
+func (s *processStatus) served() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *processStatus) failed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastError = err.Error()
+	s.lastErrorAt = time.Now()
+}
+
+// statusRow is one process on the status page.
+type statusRow struct {
+	Key         string
+	State       string
+	PID         int
+	StartedAt   string
+	Requests    int64
+	LastError   string
+	LastErrorAt string
+	Upstream    string
+}
+
+func (s *processStatus) row(key string) statusRow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	row := statusRow{Key: key, State: "stopped", Requests: s.requests, LastError: s.lastError}
+	if !s.lastErrorAt.IsZero() {
+		row.LastErrorAt = s.lastErrorAt.Format(time.RFC3339)
+	}
+	if rb := s.backend; rb != nil {
+		row.PID = rb.process.Pid()
+		row.StartedAt = s.startedAt.Format(time.RFC3339)
+		row.Upstream = rb.config.ReverseProxyTo
+		select {
+		case <-rb.exited:
+			row.State = "exited"
+		default:
+			row.State = "running"
+		}
+	}
+	return row
+}
+
+var statusPageTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>reverse-bin status</title></head>
+<body>
+<h1>reverse-bin status</h1>
+<p>Generated {{.Now}}</p>
+<table border="1" cellpadding="4">
+<tr><th>Process key</th><th>State</th><th>PID</th><th>Started</th><th>Requests</th><th>Upstream</th><th>Last error</th></tr>
+{{range .Rows}}<tr><td>{{.Key}}</td><td>{{.State}}</td><td>{{if .PID}}{{.PID}}{{end}}</td><td>{{.StartedAt}}</td><td>{{.Requests}}</td><td>{{.Upstream}}</td><td>{{if .LastError}}{{.LastErrorAt}}: {{.LastError}}{{end}}</td></tr>
+{{else}}<tr><td colspan="7">No backend has been requested yet.</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// serveStatusPage answers r with an HTML table of every process this
+// handler manages, once r presents status_page_secret.
+func (c *ReverseBin) serveStatusPage(w http.ResponseWriter, r *http.Request) error {
+	secret := r.Header.Get(statusPageSecretHeader)
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(c.StatusPageSecret)) != 1 {
+		return caddyhttp.Error(http.StatusForbidden, fmt.Errorf("missing or wrong %s header", statusPageSecretHeader))
+	}
+
+	c.mu.Lock()
+	rows := make([]statusRow, 0, len(c.processes))
+	for key, ps := range c.processes {
+		rows = append(rows, ps.status.row(key))
+	}
+	c.mu.Unlock()
+	slices.SortFunc(rows, func(a, b statusRow) int { return strings.Compare(a.Key, b.Key) })
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	return statusPageTemplate.Execute(w, struct {
+		Now  string
+		Rows []statusRow
+	}{Now: time.Now().Format(time.RFC3339), Rows: rows})
+}
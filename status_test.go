@@ -0,0 +1,78 @@
+package reversebin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestServeStatusPageRequiresSecret verifies the status page lists process state only for requests carrying status_page_secret.
+func TestServeStatusPageRequiresSecret(t *testing.T) {
+	ps := &processState{key: "app"}
+	ps.status.launched(&runningBackend{
+		process: &mockProcess{pid: 4242},
+		exited:  make(chan struct{}),
+		config:  resolvedConfig{ReverseProxyTo: "unix//tmp/app.sock"},
+	})
+	ps.status.request()
+	ps.status.failed(errors.New("backend <crashed>"))
+	c := &ReverseBin{
+		StatusPagePath:   "/__reversebin_status",
+		StatusPageSecret: "s3cret",
+		processes:        map[string]*processState{"app": ps},
+		logger:           zaptest.NewLogger(t),
+	}
+
+	// This HTTP request tests a status page request without the secret header.
+	req := httptest.NewRequest(http.MethodGet, "http://app.example/__reversebin_status", nil)
+	err := c.ServeHTTP(httptest.NewRecorder(), req, nil)
+	var herr caddyhttp.HandlerError
+	if !errors.As(err, &herr) || herr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 without the secret, got %v", err)
+	}
+
+	// This HTTP request tests a status page request with the secret header.
+	req = httptest.NewRequest(http.MethodGet, "http://app.example/__reversebin_status", nil)
+	req.Header.Set(statusPageSecretHeader, "s3cret")
+	rec := httptest.NewRecorder()
+	if err := c.ServeHTTP(rec, req, nil); err != nil {
+		t.Fatalf("ServeHTTP: %v", err)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"<td>app</td><td>running</td><td>4242</td>", "<td>1</td><td>unix//tmp/app.sock</td>", "backend &lt;crashed&gt;"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected status page to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+// TestServeStatusPageHonoursAllowedIPs verifies clients outside allowed_ips get 403 from the status page even with the secret.
+func TestServeStatusPageHonoursAllowedIPs(t *testing.T) {
+	c := &ReverseBin{
+		StatusPagePath:   "/__reversebin_status",
+		StatusPageSecret: "s3cret",
+		allowedNets:      []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		processes:        map[string]*processState{"app": {key: "app"}},
+		logger:           zaptest.NewLogger(t),
+	}
+
+	// This HTTP request tests a status page request with the secret from outside allowed_ips.
+	req := httptest.NewRequest(http.MethodGet, "http://app.example/__reversebin_status", nil)
+	req.RemoteAddr = "203.0.113.9:5000"
+	req.Header.Set(statusPageSecretHeader, "s3cret")
+	rec := httptest.NewRecorder()
+	err := c.ServeHTTP(rec, req, nil)
+	var herr caddyhttp.HandlerError
+	if !errors.As(err, &herr) || herr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 outside allowed_ips, got %v", err)
+	}
+	if strings.Contains(rec.Body.String(), "<td>app</td>") {
+		t.Fatalf("expected no process state in the response, got:\n%s", rec.Body.String())
+	}
+}
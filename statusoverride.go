@@ -0,0 +1,40 @@
+package reversebin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// parseStatusOverride parses one backend_status_override mapping, FROM=TO.
+func parseStatusOverride(mapping string) (int, int, error) {
+	fromStr, toStr, ok := strings.Cut(mapping, "=")
+	if !ok {
+		return 0, 0, fmt.Errorf("mapping %q must be FROM=TO", mapping)
+	}
+	from, err := strconv.Atoi(fromStr)
+	if err != nil || from < 200 || from > 599 {
+		return 0, 0, fmt.Errorf("invalid backend status %q", fromStr)
+	}
+	to, err := strconv.Atoi(toStr)
+	if err != nil || to < 200 || to > 599 {
+		return 0, 0, fmt.Errorf("invalid client status %q", toStr)
+	}
+	return from, to, nil
+}
+
+// statusOverrideWriter remaps the backend's final status code as the reverse
+// proxy writes it to the client. Headers and body pass through unchanged.
+type statusOverrideWriter struct {
+	*caddyhttp.ResponseWriterWrapper
+	overrides map[int]int
+}
+
+func (w *statusOverrideWriter) WriteHeader(status int) {
+	if to, ok := w.overrides[status]; ok {
+		status = to
+	}
+	w.ResponseWriterWrapper.WriteHeader(status)
+}
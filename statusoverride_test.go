@@ -0,0 +1,33 @@
+package reversebin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// TestStatusOverrideWriterRemapsBackendStatus verifies mapped statuses are rewritten and others pass through.
+func TestStatusOverrideWriterRemapsBackendStatus(t *testing.T) {
+	for backend, want := range map[int]int{http.StatusNotFound: http.StatusForbidden, http.StatusOK: http.StatusOK} {
+		rec := httptest.NewRecorder()
+		w := &statusOverrideWriter{
+			ResponseWriterWrapper: &caddyhttp.ResponseWriterWrapper{ResponseWriter: rec},
+			overrides:             map[int]int{http.StatusNotFound: http.StatusForbidden},
+		}
+		w.WriteHeader(backend)
+		if rec.Code != want {
+			t.Fatalf("backend %d: client status = %d, want %d", backend, rec.Code, want)
+		}
+	}
+}
+
+// TestParseStatusOverrideRejectsMalformedMappings verifies mappings need two valid status codes.
+func TestParseStatusOverrideRejectsMalformedMappings(t *testing.T) {
+	for _, m := range []string{"404", "404=abc", "99=403", "404=600"} {
+		if _, _, err := parseStatusOverride(m); err == nil {
+			t.Fatalf("parseStatusOverride(%q) succeeded, want error", m)
+		}
+	}
+}
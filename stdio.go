@@ -0,0 +1,136 @@
+package reversebin
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap"
+)
+
+const defaultStdioContentType = "application/octet-stream"
+
+// provisionStdio checks stdio_mode, which runs exec once per request
+// instead of supervising a server, so there is no upstream to configure.
+func (c *ReverseBin) provisionStdio() error {
+	if !c.StdioMode {
+		if c.StdioContentType != "" {
+			return fmt.Errorf("stdio_content_type requires stdio_mode")
+		}
+		return nil
+	}
+	if c.hasDetector() || c.ReverseProxyTo != "" || c.SocketTemplate != "" || len(c.Routes) > 0 || c.SocketActivation != nil || c.PortDiscoveryPattern != "" || c.StartupAddressPattern != "" {
+		return fmt.Errorf("stdio_mode cannot be combined with reverse_proxy_to, socket_template, route, socket_activation, port_discovery_pattern, startup_address_pattern or a detector")
+	}
+	if len(c.Executable) == 0 {
+		return fmt.Errorf("stdio_mode requires exec")
+	}
+	if names := c.stdioProxyOnlyDirectives(); len(names) > 0 {
+		return fmt.Errorf("stdio_mode cannot be combined with %s, which only apply to proxied requests", strings.Join(names, ", "))
+	}
+	if c.StdioContentType == "" {
+		c.StdioContentType = defaultStdioContentType
+	}
+	return nil
+}
+
+// stdioProxyOnlyDirectives lists the directives set on c that act in the
+// reverse proxy or its transport, which stdio_mode does not use.
+func (c *ReverseBin) stdioProxyOnlyDirectives() []string {
+	var names []string
+	add := func(set bool, name string) {
+		if set {
+			names = append(names, name)
+		}
+	}
+	add(c.BackendProto != "" && c.BackendProto != backendProtoHTTP, "backend_proto")
+	add(c.ResponseHeaderTimeoutMS > 0, "response_header_timeout_ms")
+	add(c.ResponseHeaders != nil, "response_header_add/set/delete")
+	add(c.UpstreamHeaders != nil, "upstream_header_add")
+	add(len(c.ResponseRewrites) > 0, "response_rewrite")
+	add(c.DecompressResponse, "decompress_response")
+	add(c.ResponseBufferSize > 0, "response_buffer_size")
+	add(len(c.ProxyHeadersFromRequest) > 0, "proxy_headers_from_request")
+	return names
+}
+
+// flushWriter flushes after every write so output such as server-sent
+// events reaches the client as the command produces it.
+type flushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if err := f.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return n, err
+	}
+	return n, nil
+}
+
+// serveStdio runs exec for r with the request body on stdin and sends its
+// stdout as the response body. A command that fails before writing any
+// output gets 502; once output has been sent the status can no longer
+// change, so later failures are only logged.
+func (c *ReverseBin) serveStdio(w http.ResponseWriter, r *http.Request) error {
+	cfg := c.resolveConfig(nil)
+	cfg.Executable = expandArgs(r, cfg.Executable)
+	cfg.Envs = append(expandArgs(r, cfg.Envs), c.headerEnvs(r)...)
+	logger := c.requestLogger(r)
+
+	cmd, err := c.backendCommand(r.Context(), cfg)
+	if err != nil {
+		return caddyhttp.Error(http.StatusInternalServerError, err)
+	}
+	cmd.Stdin = r.Body
+	proc, err := backendProcesses.Start(r.Context(), cmd)
+	if err != nil {
+		logger.Error("failed to start stdio command",
+			zap.String("executable", cmd.Path),
+			zap.Strings("args", c.sanitizeForLog(cmd.Args)),
+			zap.Error(err))
+		return caddyhttp.Error(http.StatusBadGateway, err)
+	}
+	pid := proc.Pid()
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		scanner := bufio.NewScanner(proc.Stderr())
+		for scanner.Scan() {
+			logger.Info("", zap.Int("pid", pid), zap.String("stderr", scanner.Text()))
+		}
+	}()
+	wait := func() error {
+		err := proc.Wait()
+		<-stderrDone
+		return err
+	}
+
+	stdout := bufio.NewReader(proc.Stdout())
+	if _, err := stdout.Peek(1); err != nil {
+		if waitErr := wait(); waitErr != nil {
+			return caddyhttp.Error(http.StatusBadGateway, fmt.Errorf("stdio command failed: %v", waitErr))
+		}
+		w.Header().Set("Content-Type", c.StdioContentType)
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	w.Header().Set("Content-Type", c.StdioContentType)
+	w.WriteHeader(http.StatusOK)
+	_, copyErr := io.Copy(flushWriter{w: w, rc: http.NewResponseController(w)}, stdout)
+	if err := wait(); err != nil || copyErr != nil {
+		logger.Warn("stdio command failed after sending output",
+			zap.Int("pid", pid),
+			zap.NamedError("copy_error", copyErr),
+			zap.Error(err))
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package reversebin
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestStdioModePipesBodyThroughCommand verifies the request body reaches stdin and stdout becomes the response.
+func TestStdioModePipesBodyThroughCommand(t *testing.T) {
+	c := &ReverseBin{
+		Executable: []string{"/bin/sh", "-c", "printf '%s:' \"$1\"; tr a-z A-Z", "sh", "{http.request.uri.path}"},
+		StdioMode:  true,
+		processes:  map[string]*processState{},
+		logger:     zaptest.NewLogger(t),
+	}
+	if err := c.provisionStdio(); err != nil {
+		t.Fatalf("provision: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	// This HTTP request tests that the body is transformed by the command and the path placeholder expands.
+	req := caddyhttp.PrepareRequest(httptest.NewRequest(http.MethodPost, "http://app.example/upper", strings.NewReader("hello")), caddy.NewReplacer(), rec, &caddyhttp.Server{})
+	if err := c.ServeHTTP(rec, req, nil); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	if rec.Code != http.StatusOK || rec.Body.String() != "/upper:HELLO" {
+		t.Fatalf("expected 200 /upper:HELLO, got %d %q", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != defaultStdioContentType {
+		t.Fatalf("expected default content type, got %q", ct)
+	}
+}
+
+// TestStdioModeStreamsLargeBodyToStdin verifies a body far larger than any in-memory buffer reaches stdin intact, with a Content-Length and chunked.
+func TestStdioModeStreamsLargeBodyToStdin(t *testing.T) {
+	body := bytes.Repeat([]byte("0123456789abcdef"), 256*1024) // 4 MiB
+	for _, chunked := range []bool{false, true} {
+		out := filepath.Join(t.TempDir(), "stdin")
+		c := &ReverseBin{
+			Executable: []string{"/bin/sh", "-c", `cat > "$1"`, "sh", out},
+			StdioMode:  true,
+			processes:  map[string]*processState{},
+			logger:     zaptest.NewLogger(t),
+		}
+		if err := c.provisionStdio(); err != nil {
+			t.Fatalf("provision: %v", err)
+		}
+
+		rec := httptest.NewRecorder()
+		// This HTTP request tests that a large upload is passed to the command unchanged.
+		req := httptest.NewRequest(http.MethodPost, "http://app.example/upload", bytes.NewReader(body))
+		if chunked {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.ContentLength = -1
+			req.TransferEncoding = []string{"chunked"}
+		}
+		req = caddyhttp.PrepareRequest(req, caddy.NewReplacer(), rec, &caddyhttp.Server{})
+		if err := c.ServeHTTP(rec, req, nil); err != nil {
+			t.Fatalf("chunked=%v: serve: %v", chunked, err)
+		}
+		if rec.Code != http.StatusOK {
+			t.Fatalf("chunked=%v: expected 200, got %d", chunked, rec.Code)
+		}
+		got, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatalf("chunked=%v: %v", chunked, err)
+		}
+		if !bytes.Equal(got, body) {
+			t.Fatalf("chunked=%v: expected the %d-byte body on stdin, got %d bytes that differ", chunked, len(body), len(got))
+		}
+	}
+}
+
+// TestStdioModeFailureWithoutOutputIs502 verifies a command that exits non-zero before writing gets 502.
+func TestStdioModeFailureWithoutOutputIs502(t *testing.T) {
+	c := &ReverseBin{
+		Executable:       []string{"/bin/sh", "-c", "echo broken >&2; exit 3"},
+		StdioMode:        true,
+		StdioContentType: "text/plain",
+		processes:        map[string]*processState{},
+		logger:           zaptest.NewLogger(t),
+	}
+
+	rec := httptest.NewRecorder()
+	// This HTTP request tests the failing command path.
+	req := caddyhttp.PrepareRequest(httptest.NewRequest(http.MethodGet, "http://app.example/", nil), caddy.NewReplacer(), rec, &caddyhttp.Server{})
+	err := c.ServeHTTP(rec, req, nil)
+	var herr caddyhttp.HandlerError
+	if !errors.As(err, &herr) || herr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %v", err)
+	}
+}
+
+// TestStdioModeStreamsOutput verifies output reaches the client while the command is still running.
+func TestStdioModeStreamsOutput(t *testing.T) {
+	release := filepath.Join(t.TempDir(), "release")
+	c := &ReverseBin{
+		Executable:       []string{"/bin/sh", "-c", "echo first; while [ ! -e \"$1\" ]; do sleep 0.05; done; echo second", "sh", release},
+		StdioMode:        true,
+		StdioContentType: "text/event-stream",
+		processes:        map[string]*processState{},
+		logger:           zaptest.NewLogger(t),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = caddyhttp.PrepareRequest(r, caddy.NewReplacer(), w, &caddyhttp.Server{})
+		if err := c.ServeHTTP(w, r, nil); err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	// This HTTP request tests that the first line arrives before the command exits.
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+	lines := bufio.NewReader(resp.Body)
+	if line, err := lines.ReadString('\n'); err != nil || line != "first\n" {
+		t.Fatalf("expected the first line while running, got %q, %v", line, err)
+	}
+	if err := os.WriteFile(release, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := lines.ReadString('\n'); err != nil || line != "second\n" {
+		t.Fatalf("expected the second line after release, got %q, %v", line, err)
+	}
+}
+
+// TestStdioModeFillsResponseCache verifies stdio_mode responses are stored in the response cache, so a repeated GET does not run the command again.
+func TestStdioModeFillsResponseCache(t *testing.T) {
+	runs := filepath.Join(t.TempDir(), "runs")
+	c := &ReverseBin{
+		Executable: []string{"/bin/sh", "-c", `echo run >> "$1"; printf cached`, "sh", runs},
+		StdioMode:  true,
+		cache:      newResponseCache(time.Minute, defaultCacheMaxSize),
+		processes:  map[string]*processState{},
+		logger:     zaptest.NewLogger(t),
+	}
+	if err := c.provisionStdio(); err != nil {
+		t.Fatalf("provision: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		// This HTTP request tests the second GET is answered from the cache.
+		req := caddyhttp.PrepareRequest(httptest.NewRequest(http.MethodGet, "http://app.example/report", nil), caddy.NewReplacer(), rec, &caddyhttp.Server{})
+		if err := c.ServeHTTP(rec, req, nil); err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+		if rec.Code != http.StatusOK || rec.Body.String() != "cached" {
+			t.Fatalf("request %d: expected 200 cached, got %d %q", i+1, rec.Code, rec.Body.String())
+		}
+	}
+	data, err := os.ReadFile(runs)
+	if err != nil || string(data) != "run\n" {
+		t.Fatalf("expected the command to run once, got %q (%v)", data, err)
+	}
+}
+
+// TestStdioModeRejectsProxyOnlyDirectives verifies directives that only apply to proxied requests cannot be combined with stdio_mode.
+func TestStdioModeRejectsProxyOnlyDirectives(t *testing.T) {
+	c := &ReverseBin{
+		Executable:         []string{"/bin/cat"},
+		StdioMode:          true,
+		DecompressResponse: true,
+		ResponseRewrites:   []ResponseRewrite{{Find: "a", Replace: "b"}},
+	}
+	err := c.provisionStdio()
+	if err == nil || !strings.Contains(err.Error(), "response_rewrite, decompress_response") {
+		t.Fatalf("expected response_rewrite and decompress_response to be rejected, got %v", err)
+	}
+}
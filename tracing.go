@@ -0,0 +1,57 @@
+package reversebin
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName names the tracer backend spans are created with.
+const tracerName = "github.com/tarasglek/caddy-reverse-bin"
+
+// traceContext writes and strips the W3C traceparent and tracestate headers.
+var traceContext = propagation.TraceContext{}
+
+// tracingHeaders reports whether tracing_headers is on, the default.
+func (c *ReverseBin) tracingHeaders() bool {
+	return c.TracingHeaders == nil || *c.TracingHeaders
+}
+
+// startBackendSpan starts a span for the call to the backend under the span
+// Caddy's tracing handler opened for r, which continues the client's
+// traceparent when it sent one, and injects it into the upstream headers.
+// Without tracing in Caddy there is no span and r is returned unchanged.
+func startBackendSpan(r *http.Request) (*http.Request, trace.Span) {
+	parent := trace.SpanFromContext(r.Context())
+	if !parent.SpanContext().IsValid() {
+		return r, nil
+	}
+	ctx, span := parent.TracerProvider().Tracer(tracerName).Start(r.Context(), "reverse-bin backend",
+		trace.WithSpanKind(trace.SpanKindClient))
+	r = r.WithContext(ctx)
+	traceContext.Inject(ctx, propagation.HeaderCarrier(r.Header))
+	return r, span
+}
+
+// endBackendSpan ends span, marking it failed when the backend could not
+// answer.
+func endBackendSpan(span trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// stripTraceHeaders keeps trace context from reaching the backend when
+// tracing_headers is off.
+func stripTraceHeaders(r *http.Request) {
+	for _, name := range traceContext.Fields() {
+		r.Header.Del(name)
+	}
+}
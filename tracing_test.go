@@ -0,0 +1,46 @@
+package reversebin
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestStartBackendSpanInjectsTraceparent verifies the upstream request carries the active trace as a W3C traceparent.
+func TestStartBackendSpanInjectsTraceparent(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+	r := httptest.NewRequest("GET", "/", nil)
+	r = r.WithContext(trace.ContextWithSpanContext(context.Background(), sc))
+
+	r, span := startBackendSpan(r)
+	endBackendSpan(span, nil)
+	if got, want := r.Header.Get("traceparent"), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"; got != want {
+		t.Fatalf("expected traceparent %q, got %q", want, got)
+	}
+}
+
+// TestStartBackendSpanWithoutTracingLeavesHeaders verifies nothing is injected when Caddy tracing is off.
+func TestStartBackendSpanWithoutTracingLeavesHeaders(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r, span := startBackendSpan(r)
+	if span != nil || r.Header.Get("traceparent") != "" {
+		t.Fatalf("expected no span and no traceparent, got %v and %q", span, r.Header.Get("traceparent"))
+	}
+}
+
+// TestStripTraceHeaders verifies tracing_headers off removes client trace context.
+func TestStripTraceHeaders(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.Header.Set("tracestate", "vendor=value")
+	stripTraceHeaders(r)
+	if len(r.Header) != 0 {
+		t.Fatalf("expected trace headers removed, got %v", r.Header)
+	}
+}
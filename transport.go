@@ -0,0 +1,321 @@
+package reversebin
+
+import (
+	"context"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy/fastcgi"
+)
+
+const (
+	defaultExpectContinueTimeoutMS = 1000
+	defaultBackendMaxRedirects     = 10
+	// defaultBackendConnectTimeoutMS matches the dial timeout of Caddy's
+	// HTTP and FastCGI transports.
+	defaultBackendConnectTimeoutMS = 3000
+)
+
+const (
+	backendProtoHTTP    = "http"
+	backendProtoSCGI    = "scgi"
+	backendProtoFastCGI = "fastcgi"
+)
+
+var backendProtos = []string{backendProtoHTTP, backendProtoSCGI, backendProtoFastCGI}
+
+func validBackendProto(proto string) bool {
+	return slices.Contains(backendProtos, proto)
+}
+
+func validProxyProtocol(version string) bool {
+	return version == "v1" || version == "v2"
+}
+
+// newTransport builds the round tripper used to reach backends from the
+// transport-related reverse-bin settings.
+func (c *ReverseBin) newTransport(ctx caddy.Context) (http.RoundTripper, error) {
+	rt, err := c.newProtoTransport(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Health probes use the bare protocol transport, without the wrappers
+	// below that act on proxied requests.
+	c.protoTransport = rt
+	if len(c.ProxyHeadersFromRequest) > 0 {
+		rt = &requestHeaderTransport{next: rt, names: c.ProxyHeadersFromRequest}
+	}
+	var headerTimeout time.Duration
+	if c.BackendProto != backendProtoHTTP {
+		headerTimeout = c.responseHeaderTimeout()
+	}
+	if headerTimeout > 0 || c.ResponseIdleTimeoutMS > 0 {
+		rt = &responseTimeoutTransport{next: rt, header: headerTimeout, idle: c.responseIdleTimeout()}
+	}
+	if c.RetryOnFailure > 0 {
+		rt = &retryingTransport{next: rt, attempts: c.RetryOnFailure, unsafe: c.RetryUnsafeMethods, restart: c.restartBackend}
+	}
+	if c.BackendFollowRedirects {
+		rt = &redirectFollowingTransport{next: rt, max: c.BackendMaxRedirects}
+	}
+	if c.DecompressResponse {
+		rt = &decompressingTransport{next: rt}
+	}
+	if len(c.ResponseRewrites) > 0 {
+		patterns, err := compileResponseRewrites(c.ResponseRewrites)
+		if err != nil {
+			return nil, err
+		}
+		rt = &rewritingTransport{next: rt, rewrites: c.ResponseRewrites, patterns: patterns, logger: c.logger}
+	}
+	if c.ResponseBufferSize > 0 {
+		rt = &bufferingTransport{next: rt, limit: c.ResponseBufferSize, stream: c.ResponseBufferOverflow == bufferOverflowStream}
+	}
+	return &responsePlaceholderTransport{next: rt, proxyProtocol: c.ProxyProtocol != ""}, nil
+}
+
+// responseHeaderPlaceholderPrefix prefixes the placeholders that expose
+// backend response headers to later handlers, such as log or rewrite.
+const responseHeaderPlaceholderPrefix = "http.reverse_bin.response.header."
+
+// responsePlaceholderTransport publishes the backend's response headers as
+// {http.reverse_bin.response.header.*} placeholders, named by the canonical
+// header key like reverse_proxy's {http.reverse_proxy.header.*}.
+type responsePlaceholderTransport struct {
+	next          http.RoundTripper
+	proxyProtocol bool
+}
+
+// ProxyProtocolEnabled tells reverse_proxy to keep pooled backend
+// connections per client, since each one starts with that client's PROXY
+// protocol header.
+func (t *responsePlaceholderTransport) ProxyProtocolEnabled() bool {
+	return t.proxyProtocol
+}
+
+func (t *responsePlaceholderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if repl, ok := req.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer); ok {
+		for field, values := range resp.Header {
+			repl.Set(responseHeaderPlaceholderPrefix+field, strings.Join(values, ","))
+		}
+	}
+	return resp, nil
+}
+
+func (c *ReverseBin) newProtoTransport(ctx caddy.Context) (http.RoundTripper, error) {
+	switch c.BackendProto {
+	case backendProtoSCGI:
+		return &scgiTransport{dialTimeout: c.backendConnectTimeout()}, nil
+	case backendProtoFastCGI:
+		// FastCGI scripts resolve against the app directory when one is
+		// configured, otherwise against the site root like php_fastcgi.
+		ft := &fastcgi.Transport{Root: c.WorkingDirectory, DialTimeout: caddy.Duration(c.backendConnectTimeout())}
+		if err := ft.Provision(ctx); err != nil {
+			return nil, err
+		}
+		return ft, nil
+	}
+	t := &reverseproxy.HTTPTransport{DialTimeout: caddy.Duration(c.backendConnectTimeout())}
+	if c.RelayExpectContinue {
+		// Wait for the backend's interim 100 (or final 417) before sending the
+		// body, so the client only sees 100 Continue once the backend agreed.
+		t.ExpectContinueTimeout = caddy.Duration(defaultExpectContinueTimeoutMS * time.Millisecond)
+	}
+	t.ResponseHeaderTimeout = caddy.Duration(c.responseHeaderTimeout())
+	t.ProxyProtocol = c.ProxyProtocol
+	// Zero values take HTTPTransport's defaults.
+	t.KeepAlive = &reverseproxy.KeepAlive{
+		Enabled:             c.Keepalive,
+		IdleConnTimeout:     caddy.Duration(time.Duration(c.KeepaliveIdleMS) * time.Millisecond),
+		ProbeInterval:       caddy.Duration(time.Duration(c.KeepaliveIntervalMS) * time.Millisecond),
+		MaxIdleConnsPerHost: c.KeepalivePoolSize,
+	}
+	t.MaxConnsPerHost = c.BackendMaxConns
+	if c.BackendTLS != nil {
+		t.TLS = c.BackendTLS.transportTLS()
+	}
+	if err := t.Provision(ctx); err != nil {
+		return nil, err
+	}
+	return &upstreamSchemeTransport{next: t}, nil
+}
+
+// upstreamSchemeVar is the request variable GetUpstreams sets when the
+// selected upstream is an https:// URL.
+const upstreamSchemeVar = "reverse_bin.upstream_scheme"
+
+// upstreamSchemeTransport switches a request to HTTPS when its upstream asked
+// for it. reverseproxy.HTTPTransport keeps a scheme that is already set, and
+// the underlying http.Transport performs the TLS handshake itself.
+type upstreamSchemeTransport struct {
+	next http.RoundTripper
+}
+
+func (t *upstreamSchemeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if scheme, _ := caddyhttp.GetVar(req.Context(), upstreamSchemeVar).(string); scheme != "" {
+		req.URL.Scheme = scheme
+	}
+	return t.next.RoundTrip(req)
+}
+
+// probeTransport returns the round tripper health probes use to reach
+// network/address with the configured backend protocol. A nil result means
+// the default HTTP transport.
+func (c *ReverseBin) probeTransport(network, address string) http.RoundTripper {
+	switch c.BackendProto {
+	case backendProtoSCGI:
+		return &scgiTransport{network: network, address: address, dialTimeout: c.backendConnectTimeout()}
+	case backendProtoFastCGI:
+		if c.protoTransport != nil {
+			return &probeDialTransport{
+				next: c.protoTransport,
+				info: reverseproxy.DialInfo{Network: network, Address: address},
+			}
+		}
+	}
+	if (c.BackendTLS != nil || c.ProxyProtocol != "") && c.protoTransport != nil {
+		return &probeDialTransport{
+			next:          c.protoTransport,
+			info:          reverseproxy.DialInfo{Network: network, Address: address},
+			proxyProtocol: c.ProxyProtocol != "",
+		}
+	}
+	if network == "unix" {
+		return &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", address)
+			},
+		}
+	}
+	return nil
+}
+
+// Request variables Caddy's reverse proxy sets for its transports. Caddy
+// does not export them; TestProbeDialTransportSetsCaddyDialInfo and the
+// probe tests fail if an upgrade renames or retypes them.
+const (
+	dialInfoVarKey          = "reverse_proxy.dial_info"
+	proxyProtocolInfoVarKey = "reverse_proxy.proxy_protocol_info"
+)
+
+// probeDialTransport supplies the dial target a Caddy transport module
+// expects when it is used outside of the reverse proxy handler. The probe
+// context derives from the triggering request, so the server is already set.
+type probeDialTransport struct {
+	next http.RoundTripper
+	info reverseproxy.DialInfo
+	// proxyProtocol sends probes with a PROXY header naming loopback as
+	// the client, since Caddy itself makes them.
+	proxyProtocol bool
+}
+
+func (t *probeDialTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	vars := map[string]any{}
+	if existing, ok := req.Context().Value(caddyhttp.VarsCtxKey).(map[string]any); ok {
+		maps.Copy(vars, existing)
+	}
+	// reverseproxy.GetDialInfo reads this request variable.
+	vars[dialInfoVarKey] = t.info
+	if t.proxyProtocol {
+		// HTTPTransport reads the PROXY header's source from this variable.
+		vars[proxyProtocolInfoVarKey] = reverseproxy.ProxyProtocolInfo{AddrPort: netip.AddrPortFrom(netip.AddrFrom4([4]byte{127, 0, 0, 1}), 0)}
+	}
+	ctx := context.WithValue(req.Context(), caddyhttp.VarsCtxKey, vars)
+	// The probe, not the triggering request, is what the backend should see.
+	ctx = context.WithValue(ctx, caddyhttp.OriginalRequestCtxKey, *req)
+	if _, ok := ctx.Value(caddy.ReplacerCtxKey).(*caddy.Replacer); !ok {
+		ctx = context.WithValue(ctx, caddy.ReplacerCtxKey, caddy.NewReplacer())
+	}
+	return t.next.RoundTrip(req.WithContext(ctx))
+}
+
+// redirectFollowingTransport follows redirects that point back at the same
+// backend, by its dial address or the request's Host. Redirects to other
+// hosts are passed through to the client.
+type redirectFollowingTransport struct {
+	next http.RoundTripper
+	max  int
+}
+
+func (t *redirectFollowingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	for i := 0; err == nil && i < t.max; i++ {
+		next := redirectRequest(req, resp)
+		if next == nil {
+			return resp, nil
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		req = next
+		resp, err = t.next.RoundTrip(req)
+	}
+	return resp, err
+}
+
+// redirectRequest returns the request to send for resp's redirect, or nil
+// when resp should be handed to the client as is.
+func redirectRequest(req *http.Request, resp *http.Response) *http.Request {
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return nil
+	}
+	loc := resp.Header.Get("Location")
+	if loc == "" {
+		return nil
+	}
+	target, err := req.URL.Parse(loc)
+	if err != nil {
+		return nil
+	}
+	switch {
+	case target.Host == req.URL.Host:
+	case req.Host != "" && target.Host == req.Host:
+		// The backend redirected to the client-facing Host, which is
+		// still itself; keep dialling it the same way.
+		target.Scheme, target.Host = req.URL.Scheme, req.URL.Host
+	default:
+		return nil
+	}
+
+	next := req.Clone(req.Context())
+	next.URL = target
+	if resp.StatusCode == http.StatusTemporaryRedirect || resp.StatusCode == http.StatusPermanentRedirect {
+		// Method and body must be preserved; only replay bodies we can rewind.
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return nil
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil
+			}
+			next.Body = body
+		}
+		return next
+	}
+	if next.Method != http.MethodHead {
+		next.Method = http.MethodGet
+	}
+	next.Body = http.NoBody
+	next.GetBody = nil
+	next.ContentLength = 0
+	next.Header.Del("Content-Type")
+	next.Header.Del("Content-Length")
+	return next
+}
@@ -0,0 +1,472 @@
+package reversebin
+
+import (
+	"bufio"
+	"context"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/fcgi"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy/fastcgi"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestProbeHealthSpeaksFastCGI verifies readiness probes reach a FastCGI backend through the configured transport.
+func TestProbeHealthSpeaksFastCGI(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "fcgi.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		_ = fcgi.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// This FastCGI request tests the health probe method and path survive translation.
+			if r.Method != http.MethodGet || r.URL.Path != "/health" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	}()
+
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+	rb := &ReverseBin{BackendProto: backendProtoFastCGI, WorkingDirectory: t.TempDir(), logger: zaptest.NewLogger(t)}
+	if rb.transport, err = rb.newTransport(ctx); err != nil {
+		t.Fatalf("newTransport: %v", err)
+	}
+
+	// Probes run under the triggering request's context, which Caddy has prepared.
+	sourceReq := httptest.NewRequest(http.MethodGet, "http://app.example/", nil)
+	sourceReq = caddyhttp.PrepareRequest(sourceReq, caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+	ok, result := rb.probeHealth(sourceReq.Context(), resolvedConfig{
+		ReverseProxyTo: "unix/" + sock,
+		HealthMethod:   http.MethodGet,
+		HealthPath:     "/health",
+		HealthStatus:   http.StatusNoContent,
+	}, sourceReq)
+	if result.err != nil {
+		t.Fatalf("probeHealth returned error: %v", result.err)
+	}
+	if !ok || result.status != http.StatusNoContent {
+		t.Fatalf("expected healthy 204 over FastCGI, got ok=%v status=%d", ok, result.status)
+	}
+}
+
+// TestProbeDialTransportSetsCaddyDialInfo verifies Caddy transports see the probe's dial target through reverseproxy.GetDialInfo.
+func TestProbeDialTransportSetsCaddyDialInfo(t *testing.T) {
+	want := reverseproxy.DialInfo{Network: "unix", Address: "/run/app.sock"}
+	var got reverseproxy.DialInfo
+	var ok bool
+	pt := &probeDialTransport{
+		next: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			got, ok = reverseproxy.GetDialInfo(req.Context())
+			return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody}, nil
+		}),
+		info: want,
+	}
+	// This HTTP request tests the dial target reaches the wrapped transport.
+	req := httptest.NewRequest(http.MethodGet, "http://app.example/health", nil)
+	if _, err := pt.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	if !ok || got != want {
+		t.Fatalf("expected GetDialInfo to return %+v, got %+v (ok=%v); has Caddy renamed %q?", want, got, ok, dialInfoVarKey)
+	}
+}
+
+// TestProbeHealthSendsProxyProtocolHeader verifies health probes start with a PROXY v1 header naming loopback when proxy_protocol is set.
+func TestProbeHealthSendsProxyProtocolHeader(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	header := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		line, _ := br.ReadString('\n')
+		header <- line
+		// This HTTP request tests the probe follows the PROXY header on the same connection.
+		if _, err := http.ReadRequest(br); err == nil {
+			_, _ = io.WriteString(conn, "HTTP/1.1 204 No Content\r\n\r\n")
+		}
+	}()
+
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+	rb := &ReverseBin{BackendProto: backendProtoHTTP, ProxyProtocol: "v1", logger: zaptest.NewLogger(t)}
+	if rb.transport, err = rb.newTransport(ctx); err != nil {
+		t.Fatalf("newTransport: %v", err)
+	}
+	ok, result := rb.probeHealth(context.Background(), resolvedConfig{
+		ReverseProxyTo: "unix/" + sock,
+		HealthMethod:   http.MethodGet,
+		HealthPath:     "/health",
+	}, nil)
+	if !ok {
+		t.Fatalf("expected healthy probe, got status=%d err=%v", result.status, result.err)
+	}
+	if got, want := <-header, "PROXY TCP4 127.0.0.1 0.0.0.0 0 0\r\n"; got != want {
+		t.Fatalf("expected PROXY header %q, got %q", want, got)
+	}
+}
+
+// TestKeepaliveOffOpensConnectionPerRequest verifies backend connections are pooled by default and not with keepalive off.
+func TestKeepaliveOffOpensConnectionPerRequest(t *testing.T) {
+	for _, tt := range []struct {
+		keepalive *bool
+		wantConns int32
+	}{
+		{keepalive: nil, wantConns: 1},
+		{keepalive: testBoolPtr(false), wantConns: 2},
+	} {
+		var conns atomic.Int32
+		backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		backend.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				conns.Add(1)
+			}
+		}
+		backend.Start()
+
+		ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+		rb := &ReverseBin{BackendProto: backendProtoHTTP, Keepalive: tt.keepalive, logger: zaptest.NewLogger(t)}
+		if _, err := rb.newTransport(ctx); err != nil {
+			t.Fatalf("newTransport: %v", err)
+		}
+		for range 2 {
+			// This HTTP request tests whether the previous request's connection is reused.
+			req := httptest.NewRequest(http.MethodGet, backend.URL, nil)
+			req = caddyhttp.PrepareRequest(req, caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+			req.RequestURI = ""
+			resp, err := rb.protoTransport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip: %v", err)
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		cancel()
+		backend.Close()
+		if got := conns.Load(); got != tt.wantConns {
+			t.Fatalf("keepalive %v: expected %d connections, got %d", tt.keepalive, tt.wantConns, got)
+		}
+	}
+}
+
+// TestBackendMaxConnsLimitsConcurrentConnections verifies concurrent requests beyond backend_max_conns wait for a pooled connection instead of dialing more.
+func TestBackendMaxConnsLimitsConcurrentConnections(t *testing.T) {
+	var conns atomic.Int32
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	backend.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	backend.Start()
+	defer backend.Close()
+
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+	rb := &ReverseBin{BackendProto: backendProtoHTTP, BackendMaxConns: 1, logger: zaptest.NewLogger(t)}
+	if _, err := rb.newTransport(ctx); err != nil {
+		t.Fatalf("newTransport: %v", err)
+	}
+	errs := make(chan error, 3)
+	for range 3 {
+		go func() {
+			// This HTTP request tests that it shares the single allowed connection with the others.
+			req := httptest.NewRequest(http.MethodGet, backend.URL, nil)
+			req = caddyhttp.PrepareRequest(req, caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+			req.RequestURI = ""
+			resp, err := rb.protoTransport.RoundTrip(req)
+			if err == nil {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+			errs <- err
+		}()
+	}
+	for range 3 {
+		if err := <-errs; err != nil {
+			t.Fatalf("RoundTrip: %v", err)
+		}
+	}
+	if got := conns.Load(); got != 1 {
+		t.Fatalf("expected 1 backend connection, got %d", got)
+	}
+}
+
+// TestBackendConnectTimeoutReachesTransports verifies backend_connect_timeout_ms becomes the dial timeout of each backend protocol.
+func TestBackendConnectTimeoutReachesTransports(t *testing.T) {
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+	for _, proto := range backendProtos {
+		rb := &ReverseBin{BackendProto: proto, BackendConnectTimeoutMS: 250, logger: zaptest.NewLogger(t)}
+		if _, err := rb.newTransport(ctx); err != nil {
+			t.Fatalf("%s: newTransport: %v", proto, err)
+		}
+		var got time.Duration
+		switch rt := rb.protoTransport.(type) {
+		case *upstreamSchemeTransport:
+			got = time.Duration(rt.next.(*reverseproxy.HTTPTransport).DialTimeout)
+		case *fastcgi.Transport:
+			got = time.Duration(rt.DialTimeout)
+		case *scgiTransport:
+			got = rt.dialTimeout
+		}
+		if got != 250*time.Millisecond {
+			t.Fatalf("%s: expected a 250ms dial timeout, got %v", proto, got)
+		}
+	}
+}
+
+// TestUpstreamSchemeTransportSwitchesToHTTPS verifies requests whose upstream was an https:// URL are sent over TLS.
+func TestUpstreamSchemeTransportSwitchesToHTTPS(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// This HTTPS request tests the proxied request arrived over TLS.
+		if r.TLS == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer backend.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "http://app.example/", nil)
+	req = caddyhttp.PrepareRequest(req, caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+	caddyhttp.SetVar(req.Context(), upstreamSchemeVar, "https")
+	// Mirror what reverseproxy hands a transport: the dial address, no scheme.
+	req.URL.Scheme = ""
+	req.URL.Host = backend.Listener.Addr().String()
+	req.RequestURI = ""
+
+	rt := &upstreamSchemeTransport{next: backend.Client().Transport}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204 from TLS backend, got %d", resp.StatusCode)
+	}
+}
+
+// TestProbeHealthSpeaksTLSOverUnixSocket verifies backend_tls wraps unix socket connections in TLS, trusting the configured CA.
+func TestProbeHealthSpeaksTLSOverUnixSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "tls.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// This HTTPS request tests the health probe arrived over TLS.
+		if r.TLS == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	backend.Listener = ln
+	backend.StartTLS()
+	defer backend.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: backend.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatalf("write CA: %v", err)
+	}
+
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+	// httptest's certificate is valid for example.com.
+	rb := &ReverseBin{BackendProto: backendProtoHTTP, BackendTLS: &BackendTLS{CA: caFile, ServerName: "example.com"}, logger: zaptest.NewLogger(t)}
+	if rb.transport, err = rb.newTransport(ctx); err != nil {
+		t.Fatalf("newTransport: %v", err)
+	}
+
+	sourceReq := httptest.NewRequest(http.MethodGet, "http://app.example/", nil)
+	sourceReq = caddyhttp.PrepareRequest(sourceReq, caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+	ok, result := rb.probeHealth(sourceReq.Context(), resolvedConfig{
+		ReverseProxyTo: "unix/" + sock,
+		HealthMethod:   http.MethodGet,
+		HealthPath:     "/health",
+		HealthStatus:   http.StatusNoContent,
+	}, sourceReq)
+	if result.err != nil {
+		t.Fatalf("probeHealth returned error: %v", result.err)
+	}
+	if !ok || result.status != http.StatusNoContent {
+		t.Fatalf("expected healthy 204 over TLS, got ok=%v status=%d", ok, result.status)
+	}
+}
+
+// TestResponsePlaceholderTransportExposesHeaders verifies backend response headers become placeholders for later handlers.
+func TestResponsePlaceholderTransportExposesHeaders(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// This HTTP request tests headers set by the backend, including a repeated one.
+		w.Header().Set("X-User-Id", "42")
+		w.Header().Add("X-Role", "admin")
+		w.Header().Add("X-Role", "dev")
+	}))
+	defer backend.Close()
+
+	repl := caddy.NewReplacer()
+	req := httptest.NewRequest(http.MethodGet, backend.URL, nil)
+	req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, repl))
+	req.RequestURI = ""
+
+	resp, err := (&responsePlaceholderTransport{next: http.DefaultTransport}).RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	resp.Body.Close()
+	if got := repl.ReplaceAll("{http.reverse_bin.response.header.X-User-Id} {http.reverse_bin.response.header.X-Role}", ""); got != "42 admin,dev" {
+		t.Fatalf("expected placeholders to expand to %q, got %q", "42 admin,dev", got)
+	}
+}
+
+// TestNewTransportStreamsRequestBody verifies a large chunked upload reaches the backend while the client is still sending, with no temp file.
+func TestNewTransportStreamsRequestBody(t *testing.T) {
+	const chunk = 1 << 20
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	firstChunk := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// This HTTP request tests the first MiB arrives before the client has finished the body.
+		if _, err := io.ReadFull(r.Body, make([]byte, chunk)); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		close(firstChunk)
+		rest, _ := io.Copy(io.Discard, r.Body)
+		fmt.Fprintf(w, "%d", chunk+rest)
+	}))
+	defer backend.Close()
+
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+	transport, err := (&ReverseBin{}).newTransport(ctx)
+	if err != nil {
+		t.Fatalf("newTransport: %v", err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = pw.Write(make([]byte, chunk))
+		select {
+		case <-firstChunk:
+			_, _ = pw.Write(make([]byte, chunk))
+			_ = pw.Close()
+		case <-time.After(5 * time.Second):
+			_ = pw.CloseWithError(fmt.Errorf("backend did not receive the first chunk while the upload was open"))
+		}
+	}()
+	req := httptest.NewRequest(http.MethodPost, backend.URL+"/upload", pr)
+	req.RequestURI = ""
+	req.ContentLength = -1
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	defer resp.Body.Close()
+	got, _ := io.ReadAll(resp.Body)
+	if string(got) != fmt.Sprint(2*chunk) {
+		t.Fatalf("expected backend to receive %d bytes, got %q", 2*chunk, got)
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Fatalf("expected no temp files, found %d", len(entries))
+	}
+}
+
+// TestNewTransportBackendFollowRedirects verifies internal backend redirects are followed only when enabled.
+func TestNewTransportBackendFollowRedirects(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// This HTTP request tests a backend that redirects /start to its own /final path.
+		if r.URL.Path == "/start" {
+			http.Redirect(w, r, "/final", http.StatusFound)
+			return
+		}
+		_, _ = io.WriteString(w, "final")
+	}))
+	defer backend.Close()
+
+	for _, tc := range []struct {
+		follow     bool
+		wantStatus int
+	}{
+		{follow: false, wantStatus: http.StatusFound},
+		{follow: true, wantStatus: http.StatusOK},
+	} {
+		t.Run(fmt.Sprintf("follow=%v", tc.follow), func(t *testing.T) {
+			ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+			defer cancel()
+			rb := &ReverseBin{BackendFollowRedirects: tc.follow, BackendMaxRedirects: 1}
+			transport, err := rb.newTransport(ctx)
+			if err != nil {
+				t.Fatalf("newTransport: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, backend.URL+"/start", nil)
+			req.RequestURI = ""
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tc.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tc.wantStatus)
+			}
+		})
+	}
+}
+
+// TestRedirectRequestKeepsExternalRedirects verifies redirects to other hosts always reach the client.
+func TestRedirectRequestKeepsExternalRedirects(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1:9000/login", nil)
+	resp := &http.Response{StatusCode: http.StatusFound, Header: http.Header{"Location": {"https://sso.example/auth"}}}
+
+	if next := redirectRequest(req, resp); next != nil {
+		t.Fatalf("expected external redirect to pass through, got follow-up to %s", next.URL)
+	}
+}
+
+// TestRedirectRequestFollowsPublicHost verifies a redirect to the client-facing Host is followed on the same backend connection target.
+func TestRedirectRequestFollowsPublicHost(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1:9000/login", nil)
+	req.Host = "app.example"
+	resp := &http.Response{StatusCode: http.StatusFound, Header: http.Header{"Location": {"https://app.example/home?x=1"}}}
+
+	next := redirectRequest(req, resp)
+	if next == nil {
+		t.Fatal("expected a redirect to the public Host to be followed")
+	}
+	if got := next.URL.String(); got != "http://127.0.0.1:9000/home?x=1" {
+		t.Fatalf("expected follow-up to the backend at /home?x=1, got %s", got)
+	}
+	if next.Host != "app.example" {
+		t.Fatalf("expected Host app.example to be kept, got %q", next.Host)
+	}
+}
@@ -0,0 +1,129 @@
+package reversebin
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// watchDebounce collapses the burst of events one save produces, such as
+// an editor's write-then-rename, into a single restart.
+const watchDebounce = 100 * time.Millisecond
+
+// defaultRestartDrainTimeout bounds how long a restart waits for in-flight
+// requests when restart_drain_timeout_ms is not set.
+const defaultRestartDrainTimeout = 30 * time.Second
+
+// restartDrainTimeout is how long a restart from watch_file or
+// restart_schedule waits for in-flight requests before stopping the backend
+// anyway.
+func (c *ReverseBin) restartDrainTimeout() time.Duration {
+	if c.RestartDrainTimeoutMS > 0 {
+		return time.Duration(c.RestartDrainTimeoutMS) * time.Millisecond
+	}
+	return defaultRestartDrainTimeout
+}
+
+// watchedPaths resolves watch_file paths against the working directory, as
+// env_file paths are.
+func (c *ReverseBin) watchedPaths() map[string]bool {
+	paths := make(map[string]bool, len(c.WatchFiles))
+	for _, path := range c.WatchFiles {
+		if !filepath.IsAbs(path) && c.WorkingDirectory != "" {
+			path = filepath.Join(c.WorkingDirectory, path)
+		}
+		abs, err := filepath.Abs(path)
+		if err == nil {
+			path = abs
+		}
+		paths[path] = true
+	}
+	return paths
+}
+
+// startFileWatcher restarts the backends whenever a watch_file changes.
+// It watches each file's directory rather than the file itself, so files
+// that editors replace by renaming over them keep being watched.
+func (c *ReverseBin) startFileWatcher() error {
+	if len(c.WatchFiles) == 0 {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("watch_file: %v", err)
+	}
+	paths := c.watchedPaths()
+	dirs := map[string]bool{}
+	for path := range paths {
+		dir := filepath.Dir(path)
+		if dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return fmt.Errorf("watch_file %s: %v", path, err)
+		}
+	}
+	go c.runFileWatcher(watcher, paths)
+	return nil
+}
+
+func (c *ReverseBin) runFileWatcher(watcher *fsnotify.Watcher, paths map[string]bool) {
+	defer watcher.Close()
+	var debounce *time.Timer
+	var debounceC <-chan time.Time
+	var changed string
+	for {
+		select {
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !paths[filepath.Clean(ev.Name)] || ev.Op == fsnotify.Chmod {
+				continue
+			}
+			changed = ev.Name
+			if debounce != nil {
+				debounce.Stop()
+			}
+			debounce = time.NewTimer(watchDebounce)
+			debounceC = debounce.C
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			c.logger.Warn("watch_file watcher error", zap.Error(err))
+		case <-debounceC:
+			debounce, debounceC = nil, nil
+			c.logger.Info("watched file changed, restarting backends",
+				zap.String("trigger", "watch_file"),
+				zap.String("file", changed))
+			c.restartBackends("watch_file " + changed + " changed")
+		case <-c.done():
+			if debounce != nil {
+				debounce.Stop()
+			}
+			return
+		}
+	}
+}
+
+// restartBackends asks every backend of this handler to restart once its
+// in-flight requests have finished.
+func (c *ReverseBin) restartBackends(reason string) {
+	c.mu.Lock()
+	states := make([]*processState, 0, len(c.processes))
+	for _, ps := range c.processes {
+		states = append(states, ps)
+	}
+	c.mu.Unlock()
+	for _, ps := range states {
+		if err := c.sendSupervisorCommand(ps, supervisorRestart, reason); err != nil {
+			c.logger.Warn("failed to restart backend", zap.String("key", ps.key), zap.Error(err))
+		}
+	}
+}
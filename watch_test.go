@@ -0,0 +1,142 @@
+package reversebin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// drainTestHandler returns a handler with a mock backend on sock, and a
+// function that starts a request the way ServeHTTP does: it is counted as
+// active, then asks for an upstream. The returned channel gets the
+// GetUpstreams error; calling done finishes the request.
+func drainTestHandler(t *testing.T, sock string) (*ReverseBin, func() (<-chan error, func())) {
+	t.Helper()
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	c := &ReverseBin{
+		Executable:            []string{"./app"},
+		ReverseProxyTo:        "unix/" + sock,
+		IdleTimeoutMS:         60000,
+		HealthTimeoutMS:       2000,
+		TerminationGraceMS:    1000,
+		TerminationKillWaitMS: 1000,
+		ctx:                   ctx,
+		processes:             map[string]*processState{},
+		logger:                zaptest.NewLogger(t),
+	}
+	t.Cleanup(func() {
+		_ = c.Cleanup()
+		cancel()
+	})
+	start := func() (<-chan error, func()) {
+		t.Helper()
+		// This HTTP request tests one request's trip through the supervisor, as ServeHTTP drives it.
+		req := caddyhttp.PrepareRequest(httptest.NewRequest(http.MethodGet, "http://app.example/", nil), caddy.NewReplacer(), httptest.NewRecorder(), &caddyhttp.Server{})
+		ps := c.getOrCreateProcessState(c.getProcessKey(req))
+		if err := c.sendSupervisorCommand(ps, supervisorRequestStarted, "request started"); err != nil {
+			t.Fatal(err)
+		}
+		upstream := make(chan error, 1)
+		go func() {
+			_, err := c.GetUpstreams(req)
+			upstream <- err
+		}()
+		return upstream, func() { _ = c.sendSupervisorCommand(ps, supervisorRequestDone, "request done") }
+	}
+	return c, start
+}
+
+// TestWatchFileRestartDrainsInFlightRequests verifies a watch_file restart keeps the old backend until its in-flight request finishes, and holds new requests for the fresh backend meanwhile.
+func TestWatchFileRestartDrainsInFlightRequests(t *testing.T) {
+	dir := t.TempDir()
+	sock := filepath.Join(dir, "app.sock")
+	config := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(config, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f := useMockProcesses(t, sock, http.NotFoundHandler())
+	c, start := drainTestHandler(t, sock)
+	c.WatchFiles = []string{config}
+	if err := c.startFileWatcher(); err != nil {
+		t.Fatalf("startFileWatcher: %v", err)
+	}
+
+	first, finishFirst := start()
+	if err := <-first; err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	if err := os.WriteFile(config, []byte(`{"v":2}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	second, finishSecond := start()
+	defer finishSecond()
+	select {
+	case err := <-second:
+		t.Fatalf("expected the second request to wait for the restart, got upstream (err %v)", err)
+	case <-f.started[0].exited:
+		t.Fatal("expected the backend to keep serving its in-flight request")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	finishFirst()
+	select {
+	case <-f.started[0].exited:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the backend to stop once its in-flight request finished")
+	}
+	select {
+	case err := <-second:
+		if err != nil {
+			t.Fatalf("second request: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the second request to get the fresh backend")
+	}
+	if n := f.starts.Load(); n != 2 {
+		t.Fatalf("expected 2 launches, got %d", n)
+	}
+}
+
+// TestRestartStopsBackendAfterDrainTimeout verifies a request that never finishes holds a restart only for restart_drain_timeout_ms.
+func TestRestartStopsBackendAfterDrainTimeout(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	f := useMockProcesses(t, sock, http.NotFoundHandler())
+	c, start := drainTestHandler(t, sock)
+	c.RestartDrainTimeoutMS = 200
+
+	first, finishFirst := start()
+	defer finishFirst()
+	if err := <-first; err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	c.restartBackends("test restart")
+
+	second, finishSecond := start()
+	defer finishSecond()
+	select {
+	case err := <-second:
+		if err != nil {
+			t.Fatalf("second request: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the second request to get a fresh backend after the drain timeout")
+	}
+	select {
+	case <-f.started[0].exited:
+	default:
+		t.Fatal("expected the old backend to be stopped after the drain timeout")
+	}
+	if n := f.starts.Load(); n != 2 {
+		t.Fatalf("expected 2 launches, got %d", n)
+	}
+}